@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCafesWithFreeTextBusinessHours(t *testing.T) {
+	s := newTestServer(t)
+
+	owner := s.createUser("owner", models.RoleOwner)
+	cafe := s.createCafe("Kopi Lama", owner)
+	s.createMenu(cafe, "Latte", 25000)
+
+	// Saved before business hours were structured
+	err := s.db.Model(&models.Cafe{}).Where("id = ?", cafe.ID).UpdateColumn("business_hours", "07:00-22:00").Error
+	if err != nil {
+		t.Fatalf("store free text hours: %v", err)
+	}
+
+	res := s.request("GET", "/api/v1/cafes", "", nil)
+	expectStatus(t, res, fiber.StatusOK, "list cafes")
+
+	res = s.request("GET", "/api/v1/cafes/"+cafe.ID, "", nil)
+	expectStatus(t, res, fiber.StatusOK, "get cafe")
+}
+
+func TestPaymentExpirySettings(t *testing.T) {
+	s := newTestServer(t)
+
+	owner := s.createUser("owner", models.RoleOwner)
+	cafe := s.createCafe("Kopi Test", owner)
+	token := s.login(owner)
+
+	tests := []struct {
+		name   string
+		expiry fiber.Map
+		status int
+	}{
+		{"unknown method", fiber.Map{"qris": 30}, fiber.StatusBadRequest},
+		{"negative minutes", fiber.Map{"transfer": -5}, fiber.StatusBadRequest},
+		{"too long", fiber.Map{"transfer": 7*24*60 + 1}, fiber.StatusBadRequest},
+		{"known method", fiber.Map{"transfer": 60}, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		res := s.request("PUT", "/api/v1/owner/cafe", token, fiber.Map{"payment_expiry_minutes": tt.expiry})
+		expectStatus(t, res, tt.status, tt.name)
+	}
+
+	var updated models.Cafe
+	s.db.First(&updated, "id = ?", cafe.ID)
+	if expiry := updated.GetSettings().PaymentExpiryMinutes; len(expiry) != 1 || expiry["transfer"] != 60 {
+		t.Errorf("payment expiry = %v, want transfer 60 only", expiry)
+	}
+}
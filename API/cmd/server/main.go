@@ -2,11 +2,14 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"siipcoffe-api/internal/config"
 	"siipcoffe-api/internal/database"
 	"siipcoffe-api/internal/handlers"
 	"siipcoffe-api/internal/middleware"
+	"siipcoffe-api/internal/models"
+	"siipcoffe-api/pkg/gateway"
 	"siipcoffe-api/pkg/gemini"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,6 +17,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/websocket/v2"
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -24,6 +28,9 @@ func main() {
 
 	// Initialize configuration
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
 
 	// Initialize database
 	db, err := database.Initialize(cfg)
@@ -37,6 +44,45 @@ func main() {
 		log.Fatal("Failed to initialize Gemini:", err)
 	}
 
+	// Initialize payment providers
+	paymentProviders := newPaymentProviders(cfg)
+
+	// Expire unpaid payments in the background
+	sweepInterval, err := time.ParseDuration(cfg.PaymentSweepInterval)
+	if err != nil {
+		log.Fatal("Invalid PAYMENT_SWEEP_INTERVAL:", err)
+	}
+	handlers.NewPaymentSweeper(db, sweepInterval).Start()
+
+	app := newApp(cfg, db, geminiClient, paymentProviders)
+
+	// Start server
+	port := cfg.Port
+	if port == "" {
+		port = "8080"
+	}
+
+	log.Printf("🚀 SiipCoffee API server starting on port %s", port)
+	log.Fatal(app.Listen(":" + port))
+}
+
+// newPaymentProviders registers the payment providers. The fake provider hands
+// out signed "paid" webhooks, so it is only registered on explicit opt-in.
+func newPaymentProviders(cfg *config.Config) *gateway.Registry {
+	providers := gateway.NewRegistry(
+		gateway.NewCryptoProvider(cfg.PaymentWebhookSecret),
+		gateway.NewCashProvider(cfg.PaymentWebhookSecret),
+		gateway.NewBankTransferProvider(cfg.PaymentWebhookSecret),
+	)
+	if cfg.PaymentFakeProvider {
+		log.Println("Fake payment provider enabled, do not use in production")
+		providers.Register(gateway.NewFakeProvider(cfg.PaymentWebhookSecret))
+	}
+	return providers
+}
+
+// newApp sets up the Fiber app with its middleware and all API routes
+func newApp(cfg *config.Config, db *gorm.DB, geminiClient *gemini.Client, paymentProviders *gateway.Registry) *fiber.App {
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
 		ErrorHandler: handlers.ErrorHandler,
@@ -47,19 +93,27 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, Idempotency-Key, X-Cafe-ID, X-Table-Session",
+		ExposeHeaders: "Idempotent-Replayed",
 	}))
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(db, cfg)
 	menuHandler := handlers.NewMenuHandler(db)
-	orderHandler := handlers.NewOrderHandler(db, geminiClient, cfg)
-	paymentHandler := handlers.NewPaymentHandler(db, cfg)
+	orderEvents := handlers.NewOrderEventHub(db)
+	orderHandler := handlers.NewOrderHandler(db, geminiClient, cfg, orderEvents)
+	paymentHandler := handlers.NewPaymentHandler(db, cfg, paymentProviders)
 	chatHandler := handlers.NewChatHandler(db, geminiClient, cfg)
 	cafeHandler := handlers.NewCafeHandler(db)
 	userHandler := handlers.NewUserHandler(db)
 	inventoryHandler := handlers.NewInventoryHandler(db)
 	loyaltyHandler := handlers.NewLoyaltyHandler(db)
+	kitchenHandler := handlers.NewKitchenHandler(db, orderEvents)
+	staffHandler := handlers.NewStaffHandler(db, cfg)
+	organizationHandler := handlers.NewOrganizationHandler(db)
+	adminHandler := handlers.NewAdminHandler(db)
+	reviewHandler := handlers.NewReviewHandler(db)
+	tableHandler := handlers.NewTableHandler(db, cfg)
 
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -76,17 +130,30 @@ func main() {
 	auth := api.Group("/auth")
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/login", authHandler.Login)
-	auth.Post("/refresh", authHandler.RefreshToken)
+	auth.Post("/refresh", middleware.Authenticate(cfg.JWTSecret), middleware.LoadUser(db), authHandler.RefreshToken)
 
 	// Cafe routes (public)
 	cafes := api.Group("/cafes")
 	cafes.Get("/", cafeHandler.GetAllCafes)
+	cafes.Get("/features", cafeHandler.GetCafeFeatures)
 	cafes.Get("/:id", cafeHandler.GetCafeByID)
 	cafes.Get("/:id/reviews", cafeHandler.GetCafeReviews)
-	cafes.Post("/:id/reviews", middleware.Authenticate(cfg.JWTSecret), middleware.RequireRole("customer"), cafeHandler.AddCafeReview)
+	cafes.Post("/:id/reviews", middleware.Authenticate(cfg.JWTSecret), middleware.LoadUser(db), middleware.RequireRole("customer"), cafeHandler.AddCafeReview)
+
+	// Menu search across cafes (public)
+	api.Get("/menu/search", menuHandler.SearchMenus)
+
+	// Table QR ordering (public, guests use the session token from scanning)
+	tables := api.Group("/tables")
+	tables.Post("/scan", tableHandler.ScanTable)
+	tables.Get("/session", tableHandler.GetTab)
+	tables.Post("/session/orders", middleware.OptionalAuthenticate(cfg.JWTSecret), orderHandler.CreateTableOrder)
+
+	// Payment provider webhooks (public, verified by signature)
+	api.Post("/payment/webhook/:provider", paymentHandler.HandleWebhook)
 
 	// Protected routes
-	protected := api.Group("/", middleware.Authenticate(cfg.JWTSecret))
+	protected := api.Group("/", middleware.Authenticate(cfg.JWTSecret), middleware.LoadUser(db))
 
 	// User Profile routes
 	user := protected.Group("/user")
@@ -103,35 +170,96 @@ func main() {
 	user.Put("/notifications/:id/read", userHandler.MarkNotificationAsRead)
 	user.Delete("/account", userHandler.DeleteAccount)
 
+	// Routes that act on one cafe resolve it once, see middleware.ResolveCafe
+	resolveCafe := middleware.ResolveCafe(db)
+
 	// Owner cafe management
 	owner := protected.Group("/owner", middleware.RequireRole("owner"))
 	owner.Post("/cafe", cafeHandler.CreateCafe)
-	owner.Get("/cafe", cafeHandler.GetMyCafe)
-	owner.Put("/cafe", cafeHandler.UpdateCafe)
-	owner.Put("/cafe/toggle-status", cafeHandler.ToggleCafeStatus)
-	owner.Get("/cafe/analytics", cafeHandler.GetCafeAnalytics)
+	owner.Get("/cafe", resolveCafe, cafeHandler.GetMyCafe)
+	owner.Put("/cafe", resolveCafe, cafeHandler.UpdateCafe)
+	owner.Put("/cafe/toggle-status", resolveCafe, cafeHandler.ToggleCafeStatus)
+	owner.Get("/cafe/analytics", resolveCafe, cafeHandler.GetCafeAnalytics)
+	owner.Get("/staff", resolveCafe, staffHandler.ListStaff)
+	owner.Post("/staff/invitations", resolveCafe, staffHandler.InviteStaff)
+	owner.Delete("/staff/invitations/:id", resolveCafe, staffHandler.RevokeInvitation)
+	owner.Put("/staff/:id", resolveCafe, staffHandler.UpdateStaff)
+	owner.Put("/reviews/:id/reply", resolveCafe, reviewHandler.ReplyToReview)
+	owner.Get("/menu/export", resolveCafe, menuHandler.ExportMenu)
+	owner.Post("/menu/import", resolveCafe, menuHandler.ImportMenu)
+	owner.Get("/menu/categories", resolveCafe, menuHandler.ListMenuCategories)
+	owner.Post("/menu/categories", resolveCafe, menuHandler.CreateMenuCategory)
+	owner.Put("/menu/categories/:categoryId", resolveCafe, menuHandler.UpdateMenuCategory)
+	owner.Delete("/menu/categories/:categoryId", resolveCafe, menuHandler.DeleteMenuCategory)
+
+	// Owner tables, their QR codes and open tabs
+	owner.Get("/tables", resolveCafe, tableHandler.ListTables)
+	owner.Post("/tables", resolveCafe, tableHandler.CreateTable)
+	owner.Put("/tables/:id", resolveCafe, tableHandler.UpdateTable)
+	owner.Delete("/tables/:id", resolveCafe, tableHandler.DeleteTable)
+	owner.Get("/tables/:id/qr", resolveCafe, tableHandler.GetTableQR)
+	owner.Post("/tables/:id/qr/rotate", resolveCafe, tableHandler.RotateTableQR)
+	owner.Get("/tabs", resolveCafe, tableHandler.ListOpenTabs)
+	owner.Post("/tabs/:id/close", resolveCafe, tableHandler.CloseTab)
+
+	// Owner organization with its branches and shared catalogue
+	owner.Get("/branches", organizationHandler.ListBranches)
+	owner.Post("/organization", organizationHandler.CreateOrganization)
+	owner.Get("/organization", organizationHandler.GetOrganization)
+	owner.Put("/organization", organizationHandler.UpdateOrganization)
+	owner.Get("/organization/reports", organizationHandler.GetConsolidatedReport)
+	owner.Get("/catalog", organizationHandler.GetCatalog)
+	owner.Post("/catalog", organizationHandler.CreateCatalogItem)
+	owner.Put("/catalog/:id", organizationHandler.UpdateCatalogItem)
+	owner.Delete("/catalog/:id", organizationHandler.DeleteCatalogItem)
+	owner.Put("/catalog/:id/branches/:cafeId", organizationHandler.SetBranchOverride)
+
+	// Review reports
+	reviews := protected.Group("/reviews")
+	reviews.Post("/:id/report", reviewHandler.ReportReview)
+
+	// Staff invitations for the logged in user
+	staff := protected.Group("/staff")
+	staff.Get("/invitations", staffHandler.GetMyInvitations)
+	staff.Post("/invitations/:id/accept", staffHandler.AcceptInvitation)
 
 	// Menu routes (updated with cafe context)
 	menu := protected.Group("/menu")
 	menu.Get("/cafe/:cafeId", menuHandler.GetAllMenus)
+	menu.Get("/cafe/:cafeId/categories", menuHandler.GetCategories)
 	menu.Get("/:id", menuHandler.GetMenuByID)
-	menu.Post("/", middleware.RequireRole("owner"), menuHandler.CreateMenu)
-	menu.Put("/:id", middleware.RequireRole("owner"), menuHandler.UpdateMenu)
-	menu.Delete("/:id", middleware.RequireRole("owner"), menuHandler.DeleteMenu)
+	menu.Post("/", middleware.RequireRole("owner"), resolveCafe, menuHandler.CreateMenu)
+	menu.Put("/:id", middleware.RequireRole("owner"), resolveCafe, menuHandler.UpdateMenu)
+	menu.Delete("/:id", middleware.RequireRole("owner"), resolveCafe, menuHandler.DeleteMenu)
+	menu.Post("/:id/option-groups", middleware.RequireRole("owner"), resolveCafe, menuHandler.CreateOptionGroup)
+	menu.Put("/:id/option-groups/:groupId", middleware.RequireRole("owner"), resolveCafe, menuHandler.UpdateOptionGroup)
+	menu.Delete("/:id/option-groups/:groupId", middleware.RequireRole("owner"), resolveCafe, menuHandler.DeleteOptionGroup)
+	menu.Get("/:id/recipe", middleware.RequireRole("owner"), resolveCafe, menuHandler.GetRecipe)
+	menu.Put("/:id/recipe", middleware.RequireRole("owner"), resolveCafe, menuHandler.UpdateRecipe)
 
 	// Order routes
 	orders := protected.Group("/orders")
 	orders.Get("/", orderHandler.GetUserOrders)
+	orders.Get("/stream", orderHandler.StreamOrders)
+	orders.Get("/cafe", middleware.RequireCafeRole(db, models.StaffRoles...), resolveCafe, orderHandler.GetCafeOrders)
 	orders.Get("/:id", orderHandler.GetOrderByID)
-	orders.Post("/", orderHandler.CreateOrder)
-	orders.Put("/:id/status", middleware.RequireRole("owner"), orderHandler.UpdateOrderStatus)
+	orders.Post("/", middleware.Idempotency(db), orderHandler.CreateOrder)
+	orders.Post("/quote", orderHandler.QuoteOrder)
+	orders.Put("/:id/status", middleware.RequireCafeRole(db, models.StaffRoles...), resolveCafe, orderHandler.UpdateOrderStatus)
+
+	// Kitchen display routes
+	kitchen := protected.Group("/kitchen", middleware.RequireCafeRole(db, models.StaffRoleManager, models.StaffRoleBarista), resolveCafe)
+	kitchen.Get("/orders", kitchenHandler.GetKitchenOrders)
+	kitchen.Get("/stream", kitchenHandler.StreamKitchen)
+	kitchen.Post("/orders/:id/bump", kitchenHandler.BumpOrder)
+	kitchen.Post("/orders/:id/items/:itemId/bump", kitchenHandler.BumpItem)
 
-	// Inventory routes (owner only)
-	inventory := protected.Group("/inventory", middleware.RequireRole("owner"))
+	// Inventory routes (owner and staff, item setup is for managers)
+	inventory := protected.Group("/inventory", middleware.RequireCafeRole(db, models.StaffRoleManager, models.StaffRoleBarista), resolveCafe)
 	inventory.Get("/", inventoryHandler.GetInventoryItems)
-	inventory.Post("/", inventoryHandler.CreateInventoryItem)
-	inventory.Put("/:id", inventoryHandler.UpdateInventoryItem)
-	inventory.Delete("/:id", inventoryHandler.DeleteInventoryItem)
+	inventory.Post("/", middleware.RequireCafeRole(db, models.StaffRoleManager), inventoryHandler.CreateInventoryItem)
+	inventory.Put("/:id", middleware.RequireCafeRole(db, models.StaffRoleManager), inventoryHandler.UpdateInventoryItem)
+	inventory.Delete("/:id", middleware.RequireCafeRole(db, models.StaffRoleManager), inventoryHandler.DeleteInventoryItem)
 	inventory.Post("/:id/movements", inventoryHandler.AddStockMovement)
 	inventory.Get("/:id/movements", inventoryHandler.GetStockMovements)
 	inventory.Get("/reports/low-stock", inventoryHandler.GetLowStockItems)
@@ -148,8 +276,8 @@ func main() {
 
 	// Owner loyalty management
 	ownerLoyalty := protected.Group("/loyalty", middleware.RequireRole("owner"))
-	ownerLoyalty.Post("/program", loyaltyHandler.CreateLoyaltyProgram)
-	ownerLoyalty.Post("/rewards", loyaltyHandler.CreateLoyaltyReward)
+	ownerLoyalty.Post("/program", resolveCafe, loyaltyHandler.CreateLoyaltyProgram)
+	ownerLoyalty.Post("/rewards", resolveCafe, loyaltyHandler.CreateLoyaltyReward)
 
 	// Chat routes
 	chat := protected.Group("/chat")
@@ -159,20 +287,25 @@ func main() {
 
 	// Payment routes
 	payment := protected.Group("/payment")
-	payment.Post("/process", paymentHandler.ProcessPayment)
+	payment.Post("/process", middleware.Idempotency(db), paymentHandler.ProcessPayment)
 	payment.Get("/status/:orderId", paymentHandler.GetPaymentStatus)
+	payment.Post("/:paymentId/confirm", middleware.RequireCafeRole(db, models.StaffRoleManager, models.StaffRoleCashier), resolveCafe, paymentHandler.ConfirmPayment)
+	payment.Post("/:paymentId/refund", middleware.RequireRole("owner"), resolveCafe, paymentHandler.RefundPayment)
 
-	// Admin routes (owner only)
-	admin := protected.Group("/admin", middleware.RequireRole("owner"))
-	admin.Get("/analytics", handlers.GetAnalytics)
-	admin.Get("/orders", handlers.GetAllOrders)
+	// Platform admin routes
+	admin := protected.Group("/admin", middleware.RequireRole(models.RoleAdmin))
+	admin.Get("/analytics", adminHandler.GetPlatformMetrics)
+	admin.Get("/orders", adminHandler.GetAllOrders)
+	admin.Get("/cafes", adminHandler.ListCafes)
+	admin.Get("/cafes/:id", adminHandler.GetCafe)
+	admin.Post("/cafes/:id/verify", adminHandler.VerifyCafe)
+	admin.Post("/cafes/:id/unverify", adminHandler.UnverifyCafe)
+	admin.Post("/cafes/:id/suspend", adminHandler.SuspendCafe)
+	admin.Post("/cafes/:id/reinstate", adminHandler.ReinstateCafe)
+	admin.Get("/reviews/reports", reviewHandler.GetReportQueue)
+	admin.Post("/reviews/:id/hide", reviewHandler.HideReview)
+	admin.Post("/reviews/:id/restore", reviewHandler.RestoreReview)
+	admin.Post("/reviews/:id/dismiss-reports", reviewHandler.DismissReports)
 
-	// Start server
-	port := cfg.Port
-	if port == "" {
-		port = "8080"
-	}
-
-	log.Printf("🚀 SiipCoffee API server starting on port %s", port)
-	log.Fatal(app.Listen(":" + port))
-}
\ No newline at end of file
+	return app
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"siipcoffe-api/internal/config"
+	"siipcoffe-api/internal/database"
+	"siipcoffe-api/internal/models"
+	"siipcoffe-api/pkg/gateway"
+	"siipcoffe-api/pkg/gemini"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// testPassword is the password of every user created by the tests
+const testPassword = "secret123"
+
+// testServer is the API on a fresh, seeded database
+type testServer struct {
+	t         *testing.T
+	app       *fiber.App
+	db        *gorm.DB
+	cfg       *config.Config
+	providers *gateway.Registry
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	cfg := config.Load()
+	cfg.Environment = "test"
+	cfg.DBPath = filepath.Join(t.TempDir(), "siipcoffe.db")
+	cfg.JWTSecret = "test-jwt-secret"
+	cfg.PaymentWebhookSecret = "test-webhook-secret"
+	cfg.PaymentFakeProvider = true
+	cfg.TableQRSecret = "test-table-qr-secret"
+
+	db, err := database.Initialize(cfg)
+	if err != nil {
+		t.Fatalf("initialize database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	geminiClient, err := gemini.NewClient("test-key")
+	if err != nil {
+		t.Fatalf("create gemini client: %v", err)
+	}
+
+	providers := newPaymentProviders(cfg)
+	return &testServer{
+		t:         t,
+		app:       newApp(cfg, db, geminiClient, providers),
+		db:        db,
+		cfg:       cfg,
+		providers: providers,
+	}
+}
+
+// testResponse is a decoded JSON response
+type testResponse struct {
+	Status int
+	Body   map[string]interface{}
+	Raw    []byte
+}
+
+// data returns the "data" object of the response
+func (r testResponse) data() map[string]interface{} {
+	data, _ := r.Body["data"].(map[string]interface{})
+	return data
+}
+
+// request calls a route. body is sent as is when it is a []byte and as JSON
+// otherwise; headers are name, value pairs.
+func (s *testServer) request(method, path, token string, body interface{}, headers ...string) testResponse {
+	s.t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		payload, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatalf("encode request body: %v", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	res, err := s.app.Test(req, -1)
+	if err != nil {
+		s.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+
+	result := testResponse{Status: res.StatusCode}
+	result.Raw, _ = io.ReadAll(res.Body)
+	if len(result.Raw) > 0 {
+		json.Unmarshal(result.Raw, &result.Body)
+	}
+	return result
+}
+
+// expectStatus fails the test when the response has another status
+func expectStatus(t *testing.T, res testResponse, want int, what string) {
+	t.Helper()
+	if res.Status != want {
+		t.Errorf("%s: got status %d, want %d (body %v)", what, res.Status, want, res.Body)
+	}
+}
+
+// createUser stores a user with testPassword
+func (s *testServer) createUser(name, role string) models.User {
+	s.t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		s.t.Fatalf("hash password: %v", err)
+	}
+	user := models.User{
+		ID:       uuid.New().String(),
+		Name:     name,
+		Email:    name + "@test.local",
+		Password: string(hash),
+		Role:     role,
+	}
+	if err := s.db.Create(&user).Error; err != nil {
+		s.t.Fatalf("create user %s: %v", name, err)
+	}
+	return user
+}
+
+// login returns a token for the user from the login route
+func (s *testServer) login(user models.User) string {
+	s.t.Helper()
+
+	res := s.request("POST", "/api/v1/auth/login", "", fiber.Map{
+		"email":    user.Email,
+		"password": testPassword,
+	})
+	token, _ := res.data()["token"].(string)
+	if res.Status != fiber.StatusOK || token == "" {
+		s.t.Fatalf("login %s: status %d, body %v", user.Email, res.Status, res.Body)
+	}
+	return token
+}
+
+// createCafe stores an active cafe that is open until changed by hand
+func (s *testServer) createCafe(name string, owner models.User) models.Cafe {
+	s.t.Helper()
+
+	open := true
+	cafe := models.Cafe{
+		ID:           uuid.New().String(),
+		OwnerID:      owner.ID,
+		Name:         name,
+		Address:      "Jl. Test No. 1",
+		City:         "Jakarta",
+		OpenOverride: &open,
+		Status:       models.CafeStatusActive,
+		Timezone:     "Asia/Jakarta",
+	}
+	if err := s.db.Create(&cafe).Error; err != nil {
+		s.t.Fatalf("create cafe %s: %v", name, err)
+	}
+	return cafe
+}
+
+// addStaff makes the user active staff of the cafe
+func (s *testServer) addStaff(cafe models.Cafe, user models.User, role string) models.CafeStaff {
+	s.t.Helper()
+
+	staff := models.CafeStaff{
+		ID:       uuid.New().String(),
+		CafeID:   cafe.ID,
+		UserID:   user.ID,
+		Role:     role,
+		IsActive: true,
+	}
+	if err := s.db.Create(&staff).Error; err != nil {
+		s.t.Fatalf("add staff %s: %v", user.Name, err)
+	}
+	return staff
+}
+
+// createMenu stores an available menu item
+func (s *testServer) createMenu(cafe models.Cafe, name string, price float64) models.Menu {
+	s.t.Helper()
+
+	menu := models.Menu{
+		ID:          uuid.New().String(),
+		CafeID:      cafe.ID,
+		Name:        name,
+		Category:    "coffee",
+		Price:       price,
+		IsAvailable: true,
+	}
+	if err := s.db.Create(&menu).Error; err != nil {
+		s.t.Fatalf("create menu %s: %v", name, err)
+	}
+	return menu
+}
+
+// createOrder stores a pending order of one menu item
+func (s *testServer) createOrder(cafe models.Cafe, customer models.User, menu models.Menu) models.Order {
+	s.t.Helper()
+
+	order := models.Order{
+		ID:             uuid.New().String(),
+		CafeID:         cafe.ID,
+		UserID:         customer.ID,
+		OrderNumber:    "ORD-" + uuid.New().String()[:8],
+		Status:         string(models.OrderStatusPending),
+		TotalAmount:    menu.Price,
+		SubtotalAmount: menu.Price,
+		PaymentStatus:  string(models.PaymentStatusPending),
+		OrderType:      "take_away",
+		CustomerName:   customer.Name,
+		OrderItems: []models.OrderItem{{
+			ID:         uuid.New().String(),
+			MenuID:     menu.ID,
+			Quantity:   1,
+			UnitPrice:  menu.Price,
+			TotalPrice: menu.Price,
+		}},
+	}
+	if err := s.db.Omit("OrderItems.Menu").Create(&order).Error; err != nil {
+		s.t.Fatalf("create order: %v", err)
+	}
+	return order
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMenuImportRowsClaimOneItemOnce(t *testing.T) {
+	s := newTestServer(t)
+
+	owner := s.createUser("owner", models.RoleOwner)
+	cafe := s.createCafe("Kopi Test", owner)
+	menu := s.createMenu(cafe, "Latte", 25000)
+	if err := s.db.Model(&menu).Update("sku", "LAT-01").Error; err != nil {
+		t.Fatalf("set sku: %v", err)
+	}
+	token := s.login(owner)
+
+	tests := []struct {
+		name  string
+		items []fiber.Map
+	}{
+		{"by id and by sku", []fiber.Map{
+			{"id": menu.ID, "name": "Latte Baru"},
+			{"sku": "LAT-01", "name": "Latte Lagi"},
+		}},
+		{"renamed sku used again", []fiber.Map{
+			{"id": menu.ID, "sku": "LAT-02"},
+			{"sku": "LAT-01", "name": "Iced Latte", "category": "coffee", "price": 28000},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := s.request("POST", "/api/v1/owner/menu/import", token, tt.items)
+			expectStatus(t, res, fiber.StatusUnprocessableEntity, "import")
+
+			rows, _ := res.data()["rows"].([]interface{})
+			if len(rows) != 2 {
+				t.Fatalf("got rows %v, want 2", res.data()["rows"])
+			}
+			second, _ := rows[1].(map[string]interface{})
+			claimed := false
+			errs, _ := second["errors"].([]interface{})
+			for _, err := range errs {
+				claimed = claimed || strings.Contains(err.(string), "already updated by row 1")
+			}
+			if second["action"] != "error" || !claimed {
+				t.Errorf("second row = %v, want it rejected as already updated by row 1", second)
+			}
+		})
+	}
+
+	var unchanged models.Menu
+	s.db.First(&unchanged, "id = ?", menu.ID)
+	if unchanged.Name != "Latte" || unchanged.SKU == nil || *unchanged.SKU != "LAT-01" {
+		t.Errorf("menu changed to %q (sku %v)", unchanged.Name, unchanged.SKU)
+	}
+
+	res := s.request("POST", "/api/v1/owner/menu/import", token, []fiber.Map{{"id": menu.ID, "name": "Latte Baru"}})
+	expectStatus(t, res, fiber.StatusOK, "import one row")
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMenuSearchEscapesSnippets(t *testing.T) {
+	s := newTestServer(t)
+
+	cafe := s.createCafe("Kopi Test", s.createUser("owner", models.RoleOwner))
+	menu := s.createMenu(cafe, "Latte", 25000)
+	description := `Creamy <img src=x onerror=alert(1)> latte & "foam"`
+	if err := s.db.Model(&menu).Update("description", description).Error; err != nil {
+		t.Fatalf("set description: %v", err)
+	}
+
+	res := s.request("GET", "/api/v1/menu/search?q=creamy", "", nil)
+	expectStatus(t, res, fiber.StatusOK, "search menus")
+
+	results, _ := res.Body["data"].([]interface{})
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1: %v", len(results), res.Body)
+	}
+	snippet, _ := results[0].(map[string]interface{})["snippet"].(string)
+	if !strings.Contains(snippet, "<mark>Creamy</mark>") {
+		t.Errorf("snippet %q does not mark the match", snippet)
+	}
+	if !strings.Contains(snippet, "&lt;img") || strings.Contains(snippet, "<img") {
+		t.Errorf("snippet %q does not escape the description", snippet)
+	}
+	if strings.Contains(snippet, `"foam"`) || strings.Contains(snippet, " & ") {
+		t.Errorf("snippet %q does not escape quotes and ampersands", snippet)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestFakePaymentFlow(t *testing.T) {
+	s := newTestServer(t)
+
+	owner := s.createUser("owner", models.RoleOwner)
+	customer := s.createUser("customer", models.RoleCustomer)
+	cafe := s.createCafe("Kopi Test", owner)
+	menu := s.createMenu(cafe, "Latte", 25000)
+	order := s.createOrder(cafe, customer, menu)
+	token := s.login(customer)
+
+	url, header, signature, payload := s.startFakePayment(token, order)
+
+	res := s.request("POST", url, "", payload, header, "not-the-signature")
+	expectStatus(t, res, fiber.StatusUnauthorized, "webhook with a bad signature")
+
+	res = s.request("GET", "/api/v1/payment/status/"+order.ID, token, nil)
+	expectStatus(t, res, fiber.StatusOK, "payment status")
+	if status := res.data()["payment_status"]; status != string(models.PaymentStatusPending) {
+		t.Fatalf("payment status after rejected webhook = %v, want pending", status)
+	}
+
+	res = s.request("POST", url, "", payload, header, signature)
+	expectStatus(t, res, fiber.StatusOK, "signed webhook")
+	if status := res.data()["payment_status"]; status != string(models.PaymentStatusPaid) {
+		t.Errorf("webhook payment status = %v, want paid", status)
+	}
+
+	res = s.request("GET", "/api/v1/payment/status/"+order.ID, token, nil)
+	expectStatus(t, res, fiber.StatusOK, "payment status")
+	if status := res.data()["payment_status"]; status != string(models.PaymentStatusPaid) {
+		t.Errorf("order payment status = %v, want paid", status)
+	}
+
+	res = s.request("POST", url, "", payload, header, signature)
+	expectStatus(t, res, fiber.StatusOK, "replayed webhook")
+	if message := res.Body["message"]; message != "Payment update already processed" {
+		t.Errorf("replayed webhook message = %v", message)
+	}
+}
+
+func TestLateWebhookForCancelledOrder(t *testing.T) {
+	s := newTestServer(t)
+
+	owner := s.createUser("owner", models.RoleOwner)
+	customer := s.createUser("customer", models.RoleCustomer)
+	cafe := s.createCafe("Kopi Test", owner)
+	order := s.createOrder(cafe, customer, s.createMenu(cafe, "Latte", 25000))
+	token := s.login(customer)
+
+	url, header, signature, payload := s.startFakePayment(token, order)
+
+	res := s.request("PUT", "/api/v1/orders/"+order.ID+"/status", s.login(owner), fiber.Map{
+		"status": "cancelled",
+		"reason": "Out of milk",
+	})
+	expectStatus(t, res, fiber.StatusOK, "cancel order")
+
+	res = s.request("POST", url, "", payload, header, signature)
+	expectStatus(t, res, fiber.StatusConflict, "paid webhook for a cancelled order")
+
+	res = s.request("GET", "/api/v1/payment/status/"+order.ID, token, nil)
+	expectStatus(t, res, fiber.StatusOK, "payment status")
+	if status := res.data()["payment_status"]; status == string(models.PaymentStatusPaid) {
+		t.Errorf("cancelled order was marked paid")
+	}
+}
+
+func TestConcurrentPaymentsForOneOrder(t *testing.T) {
+	s := newTestServer(t)
+
+	owner := s.createUser("owner", models.RoleOwner)
+	customer := s.createUser("customer", models.RoleCustomer)
+	cafe := s.createCafe("Kopi Test", owner)
+	order := s.createOrder(cafe, customer, s.createMenu(cafe, "Latte", 25000))
+	token := s.login(customer)
+
+	const attempts = 8
+	statuses := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := s.request("POST", "/api/v1/payment/process", token, fiber.Map{
+				"order_id": order.ID,
+				"method":   "fake",
+				"amount":   order.TotalAmount,
+			})
+			statuses <- res.Status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[fiber.StatusOK] != 1 || counts[fiber.StatusConflict] != attempts-1 {
+		t.Errorf("got statuses %v, want one 200 and %d 409", counts, attempts-1)
+	}
+
+	var active int64
+	s.db.Model(&models.Payment{}).Where("order_id = ? AND status IN ?", order.ID, models.ActivePaymentStatuses).Count(&active)
+	if active != 1 {
+		t.Errorf("order has %d active payments, want 1", active)
+	}
+}
+
+func TestOneActivePaymentPerOrderIndex(t *testing.T) {
+	s := newTestServer(t)
+
+	owner := s.createUser("owner", models.RoleOwner)
+	customer := s.createUser("customer", models.RoleCustomer)
+	cafe := s.createCafe("Kopi Test", owner)
+	order := s.createOrder(cafe, customer, s.createMenu(cafe, "Latte", 25000))
+
+	payment := func(status models.PaymentStatus) error {
+		return s.db.Create(&models.Payment{
+			ID:            uuid.New().String(),
+			OrderID:       order.ID,
+			Amount:        order.TotalAmount,
+			Method:        "cash",
+			Status:        string(status),
+			TransactionID: uuid.New().String(),
+		}).Error
+	}
+
+	if err := payment(models.PaymentStatusFailed); err != nil {
+		t.Fatalf("failed payment: %v", err)
+	}
+	if err := payment(models.PaymentStatusPending); err != nil {
+		t.Fatalf("first active payment: %v", err)
+	}
+	if err := payment(models.PaymentStatusPending); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Errorf("second active payment: got %v, want a duplicate key error", err)
+	}
+}
+
+func TestFakeProviderNeedsOptIn(t *testing.T) {
+	s := newTestServer(t)
+
+	s.cfg.PaymentFakeProvider = true
+	if _, ok := newPaymentProviders(s.cfg).Get("fake"); !ok {
+		t.Error("fake provider not registered with PAYMENT_FAKE_PROVIDER=true")
+	}
+
+	s.cfg.PaymentFakeProvider = false
+	if _, ok := newPaymentProviders(s.cfg).Get("fake"); ok {
+		t.Error("fake provider registered without PAYMENT_FAKE_PROVIDER")
+	}
+}
+
+// startFakePayment pays for the order with the fake provider and returns the
+// signed webhook from its instructions
+func (s *testServer) startFakePayment(token string, order models.Order) (url, header, signature string, payload []byte) {
+	s.t.Helper()
+
+	res := s.request("POST", "/api/v1/payment/process", token, fiber.Map{
+		"order_id": order.ID,
+		"method":   "fake",
+		"amount":   order.TotalAmount,
+	})
+	if res.Status != fiber.StatusOK {
+		s.t.Fatalf("initiate fake payment: status %d, body %v", res.Status, res.Body)
+	}
+
+	// The payload is kept as sent, the signature covers its exact bytes
+	var instructions struct {
+		Data struct {
+			Webhook struct {
+				URL       string          `json:"url"`
+				Header    string          `json:"header"`
+				Signature string          `json:"signature"`
+				Payload   json.RawMessage `json:"payload"`
+			} `json:"webhook"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(res.Raw, &instructions); err != nil || instructions.Data.Webhook.Signature == "" {
+		s.t.Fatalf("fake payment instructions have no signed webhook: %v", res.Body)
+	}
+	webhook := instructions.Data.Webhook
+	return webhook.URL, webhook.Header, webhook.Signature, []byte(webhook.Payload)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"siipcoffe-api/internal/models"
+	"siipcoffe-api/pkg/gateway"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// failingRefunds is the fake provider with refunds that fail, as when the
+// gateway is down
+type failingRefunds struct {
+	*gateway.FakeProvider
+	calls []string
+}
+
+func (p *failingRefunds) Refund(payment *models.Payment, amount float64, reference string) (string, error) {
+	p.calls = append(p.calls, reference)
+	return "", errors.New("gateway unavailable")
+}
+
+// paidOrder returns an order paid with the fake provider and the owner's token
+func (s *testServer) paidOrder() (models.Order, string) {
+	s.t.Helper()
+
+	owner := s.createUser("owner", models.RoleOwner)
+	customer := s.createUser("customer", models.RoleCustomer)
+	cafe := s.createCafe("Kopi Test", owner)
+	order := s.createOrder(cafe, customer, s.createMenu(cafe, "Latte", 25000))
+
+	url, header, signature, payload := s.startFakePayment(s.login(customer), order)
+	if res := s.request("POST", url, "", payload, header, signature); res.Status != fiber.StatusOK {
+		s.t.Fatalf("pay order: status %d, body %v", res.Status, res.Body)
+	}
+	if err := s.db.First(&order, "id = ?", order.ID).Error; err != nil {
+		s.t.Fatalf("reload order: %v", err)
+	}
+	return order, s.login(owner)
+}
+
+func (s *testServer) payment(id string) models.Payment {
+	s.t.Helper()
+
+	var payment models.Payment
+	if err := s.db.First(&payment, "id = ?", id).Error; err != nil {
+		s.t.Fatalf("load payment: %v", err)
+	}
+	return payment
+}
+
+func TestRefund(t *testing.T) {
+	s := newTestServer(t)
+	order, token := s.paidOrder()
+
+	res := s.request("POST", "/api/v1/payment/"+order.PaymentID+"/refund", token, fiber.Map{"reason": "Wrong order"})
+	expectStatus(t, res, fiber.StatusCreated, "refund")
+
+	refund, _ := res.data()["refund"].(map[string]interface{})
+	if refund["status"] != models.RefundStatusCompleted {
+		t.Errorf("refund status = %v, want completed", refund["status"])
+	}
+	if refund["provider_reference"] == "" {
+		t.Error("refund has no provider reference")
+	}
+
+	payment := s.payment(order.PaymentID)
+	if payment.Status != string(models.PaymentStatusRefunded) || payment.RefundedAmount != payment.Amount {
+		t.Errorf("payment = %s with %v refunded, want refunded in full", payment.Status, payment.RefundedAmount)
+	}
+}
+
+func TestRefundProviderFailure(t *testing.T) {
+	s := newTestServer(t)
+	order, token := s.paidOrder()
+
+	failing := &failingRefunds{FakeProvider: gateway.NewFakeProvider(s.cfg.PaymentWebhookSecret)}
+	s.providers.Register(failing)
+
+	res := s.request("POST", "/api/v1/payment/"+order.PaymentID+"/refund", token, fiber.Map{})
+	expectStatus(t, res, fiber.StatusBadGateway, "refund with the provider down")
+
+	var refunds []models.Refund
+	s.db.Where("payment_id = ?", order.PaymentID).Find(&refunds)
+	if len(refunds) != 1 || refunds[0].Status != models.RefundStatusFailed {
+		t.Fatalf("refunds = %+v, want one failed refund", refunds)
+	}
+	if len(failing.calls) != 1 || failing.calls[0] != refunds[0].ID {
+		t.Errorf("provider called with %v, want the refund ID %s", failing.calls, refunds[0].ID)
+	}
+
+	payment := s.payment(order.PaymentID)
+	if payment.Status != string(models.PaymentStatusPaid) || payment.RefundedAmount != 0 {
+		t.Errorf("payment = %s with %v refunded, want paid with nothing refunded", payment.Status, payment.RefundedAmount)
+	}
+
+	// Once the provider is back the refund goes through
+	s.providers.Register(failing.FakeProvider)
+	res = s.request("POST", "/api/v1/payment/"+order.PaymentID+"/refund", token, fiber.Map{})
+	expectStatus(t, res, fiber.StatusCreated, "refund after the provider is back")
+	if status := s.payment(order.PaymentID).Status; status != string(models.PaymentStatusRefunded) {
+		t.Errorf("payment status = %s, want refunded", status)
+	}
+}
+
+func TestRefundResumesPendingRefund(t *testing.T) {
+	s := newTestServer(t)
+	order, token := s.paidOrder()
+
+	// Left behind by a request that stopped after reserving the amount
+	payment := s.payment(order.PaymentID)
+	pending := models.Refund{
+		ID:         uuid.New().String(),
+		PaymentID:  payment.ID,
+		OrderID:    order.ID,
+		CafeID:     order.CafeID,
+		Amount:     10000,
+		Method:     payment.Method,
+		Status:     models.RefundStatusPending,
+		RefundedBy: "someone",
+	}
+	s.db.Create(&pending)
+	s.db.Model(&payment).Update("refunded_amount", pending.Amount)
+
+	res := s.request("POST", "/api/v1/payment/"+order.PaymentID+"/refund", token, fiber.Map{})
+	expectStatus(t, res, fiber.StatusCreated, "refund with one pending")
+
+	refund, _ := res.data()["refund"].(map[string]interface{})
+	if refund["id"] != pending.ID || refund["status"] != models.RefundStatusCompleted {
+		t.Errorf("refund = %v, want the pending refund completed", refund)
+	}
+
+	var count int64
+	s.db.Model(&models.Refund{}).Where("payment_id = ?", payment.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("got %d refunds, want the pending one only", count)
+	}
+
+	payment = s.payment(order.PaymentID)
+	if payment.Status != string(models.PaymentStatusPartiallyRefunded) || payment.RefundedAmount != pending.Amount {
+		t.Errorf("payment = %s with %v refunded, want partially refunded by %v", payment.Status, payment.RefundedAmount, pending.Amount)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// concurrently sends the same request from several clients and counts the
+// statuses they get
+func (s *testServer) concurrently(n int, method, path, token string, body interface{}) map[int]int {
+	statuses := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- s.request(method, path, token, body).Status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	return counts
+}
+
+func TestConcurrentReviewModeration(t *testing.T) {
+	s := newTestServer(t)
+
+	owner := s.createUser("owner", models.RoleOwner)
+	customer := s.createUser("customer", models.RoleCustomer)
+	cafe := s.createCafe("Kopi Test", owner)
+	order := s.createOrder(cafe, customer, s.createMenu(cafe, "Latte", 25000))
+	if err := s.db.Model(&order).UpdateColumn("status", models.OrderStatusCompleted).Error; err != nil {
+		t.Fatalf("complete order: %v", err)
+	}
+
+	const attempts = 6
+	counts := s.concurrently(attempts, "POST", "/api/v1/cafes/"+cafe.ID+"/reviews", s.login(customer), fiber.Map{
+		"order_id": order.ID,
+		"rating":   5,
+	})
+	if counts[fiber.StatusCreated] != 1 || counts[fiber.StatusConflict] != attempts-1 {
+		t.Errorf("review order: got statuses %v, want one 201 and %d 409", counts, attempts-1)
+	}
+	expectRatings(t, s, cafe.ID, 1)
+
+	var review models.CafeReview
+	if err := s.db.First(&review, "order_id = ?", order.ID).Error; err != nil {
+		t.Fatalf("load review: %v", err)
+	}
+	admin := s.login(s.createUser("admin", models.RoleAdmin))
+
+	for _, step := range []struct {
+		action string
+		count  int
+	}{
+		{"hide", 0},
+		{"restore", 1},
+	} {
+		counts := s.concurrently(attempts, "POST", "/api/v1/admin/reviews/"+review.ID+"/"+step.action, admin, nil)
+		if counts[fiber.StatusOK] != 1 || counts[fiber.StatusConflict] != attempts-1 {
+			t.Errorf("%s review: got statuses %v, want one 200 and %d 409", step.action, counts, attempts-1)
+		}
+		expectRatings(t, s, cafe.ID, step.count)
+	}
+}
+
+// expectRatings checks the cafe counts the 5 star rating the given number of
+// times
+func expectRatings(t *testing.T, s *testServer, cafeID string, count int) {
+	t.Helper()
+
+	var cafe models.Cafe
+	s.db.First(&cafe, "id = ?", cafeID)
+	if cafe.RatingCount != count || cafe.Rating5Count != count {
+		t.Errorf("cafe rating count = %d, 5 star count = %d, want %d", cafe.RatingCount, cafe.Rating5Count, count)
+	}
+}
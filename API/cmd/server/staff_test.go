@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestStaffInvitationNeedsToken(t *testing.T) {
+	s := newTestServer(t)
+
+	owner := s.createUser("owner", models.RoleOwner)
+	s.createCafe("Kopi Test", owner)
+	ownerToken := s.login(owner)
+
+	invite := func() (id, token string) {
+		res := s.request("POST", "/api/v1/owner/staff/invitations", ownerToken, fiber.Map{
+			"email": "barista@test.local",
+			"role":  models.StaffRoleBarista,
+		})
+		expectStatus(t, res, fiber.StatusCreated, "invite staff")
+		id, _ = res.data()["id"].(string)
+		token, _ = res.data()["token"].(string)
+		if token == "" {
+			t.Fatalf("invitation has no token: %v", res.Body)
+		}
+		return id, token
+	}
+
+	id, stale := invite()
+	_, token := invite()
+	if token == stale {
+		t.Fatal("inviting again kept the same token")
+	}
+
+	// Anyone can register the invited email, it is never verified
+	invitee := s.login(s.createUser("barista", models.RoleCustomer))
+	accept := "/api/v1/staff/invitations/" + id + "/accept"
+
+	res := s.request("GET", "/api/v1/staff/invitations", invitee, nil)
+	expectStatus(t, res, fiber.StatusBadRequest, "list invitations without a token")
+	res = s.request("POST", accept, invitee, nil)
+	expectStatus(t, res, fiber.StatusBadRequest, "accept without a token")
+	res = s.request("POST", accept, invitee, fiber.Map{"token": "guessed"})
+	expectStatus(t, res, fiber.StatusNotFound, "accept with a wrong token")
+	res = s.request("POST", accept, invitee, fiber.Map{"token": stale})
+	expectStatus(t, res, fiber.StatusNotFound, "accept with a replaced token")
+
+	// The token only works for the invited email
+	res = s.request("POST", accept, s.login(s.createUser("someone", models.RoleCustomer)), fiber.Map{"token": token})
+	expectStatus(t, res, fiber.StatusNotFound, "accept with another account")
+
+	res = s.request("GET", "/api/v1/staff/invitations?token="+token, invitee, nil)
+	expectStatus(t, res, fiber.StatusOK, "list invitations")
+	if invitations, _ := res.Body["data"].([]interface{}); len(invitations) != 1 {
+		t.Errorf("got invitations %v, want one", res.Body["data"])
+	}
+
+	res = s.request("POST", accept, invitee, fiber.Map{"token": token})
+	expectStatus(t, res, fiber.StatusOK, "accept invitation")
+	res = s.request("POST", accept, invitee, fiber.Map{"token": token})
+	expectStatus(t, res, fiber.StatusNotFound, "accept twice")
+}
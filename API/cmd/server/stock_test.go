@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func TestManualMovementDoesNotSkipOrderDeduction(t *testing.T) {
+	s := newTestServer(t)
+
+	owner := s.createUser("owner", models.RoleOwner)
+	cafe := s.createCafe("Kopi Test", owner)
+	menu := s.createMenu(cafe, "Latte", 25000)
+	order := s.createOrder(cafe, s.createUser("customer", models.RoleCustomer), menu)
+	token := s.login(owner)
+
+	milk := models.Inventory{ID: uuid.New().String(), CafeID: cafe.ID, Name: "Milk", Unit: "liter", CurrentStock: 10}
+	recipe := models.RecipeItem{ID: uuid.New().String(), MenuID: menu.ID, InventoryID: milk.ID, Quantity: 0.2}
+	for _, record := range []interface{}{&milk, &recipe} {
+		if err := s.db.Create(record).Error; err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	// Milk spilled while making the order, written down by hand
+	res := s.request("POST", "/api/v1/inventory/"+milk.ID+"/movements", token, fiber.Map{
+		"type":         "out",
+		"quantity":     1,
+		"reason":       "sale",
+		"reference_id": order.ID,
+	})
+	expectStatus(t, res, fiber.StatusCreated, "manual stock movement")
+
+	for _, status := range []string{"confirmed", "preparing", "ready", "completed"} {
+		res = s.request("PUT", "/api/v1/orders/"+order.ID+"/status", token, fiber.Map{"status": status})
+		expectStatus(t, res, fiber.StatusOK, "order "+status)
+	}
+
+	var stock models.Inventory
+	s.db.First(&stock, "id = ?", milk.ID)
+	if want := 10 - 1 - 0.2; stock.CurrentStock < want-1e-9 || stock.CurrentStock > want+1e-9 {
+		t.Errorf("milk stock = %v, want %v", stock.CurrentStock, want)
+	}
+}
+
+func TestInventoryUpdateRefreshesSoldOut(t *testing.T) {
+	s := newTestServer(t)
+
+	owner := s.createUser("owner", models.RoleOwner)
+	cafe := s.createCafe("Kopi Test", owner)
+	menu := s.createMenu(cafe, "Latte", 25000)
+	token := s.login(owner)
+
+	milk := models.Inventory{ID: uuid.New().String(), CafeID: cafe.ID, Name: "Milk", Unit: "liter", CurrentStock: 0.1}
+	recipe := models.RecipeItem{ID: uuid.New().String(), MenuID: menu.ID, InventoryID: milk.ID, Quantity: 0.2}
+	for _, record := range []interface{}{&milk, &recipe} {
+		if err := s.db.Create(record).Error; err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	res := s.request("PUT", "/api/v1/inventory/"+milk.ID, token, fiber.Map{"name": "Fresh Milk"})
+	expectStatus(t, res, fiber.StatusOK, "rename inventory item")
+
+	var updated models.Menu
+	s.db.First(&updated, "id = ?", menu.ID)
+	if !updated.SoldOut || updated.SoldOutReason != "Out of Fresh Milk" {
+		t.Errorf("menu sold out = %v (%q), want sold out of Fresh Milk", updated.SoldOut, updated.SoldOutReason)
+	}
+}
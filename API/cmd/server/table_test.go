@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestConcurrentScansShareOneTab(t *testing.T) {
+	s := newTestServer(t)
+
+	owner := s.createUser("owner", models.RoleOwner)
+	cafe := s.createCafe("Kopi Test", owner)
+	table := models.CafeTable{ID: uuid.New().String(), CafeID: cafe.ID, Number: "A1", QRVersion: 1, IsActive: true}
+	if err := s.db.Create(&table).Error; err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	res := s.request("GET", "/api/v1/owner/tables/"+table.ID+"/qr", s.login(owner), nil)
+	expectStatus(t, res, fiber.StatusOK, "table QR")
+	qr, _ := res.data()["token"].(string)
+
+	const guests = 8
+	tokens := make(chan string, guests)
+	var wg sync.WaitGroup
+	for i := 0; i < guests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := s.request("POST", "/api/v1/tables/scan", "", fiber.Map{"token": qr})
+			if res.Status != fiber.StatusOK {
+				t.Errorf("scan: got status %d, body %v", res.Status, res.Body)
+				return
+			}
+			token, _ := res.data()["session_token"].(string)
+			tokens <- token
+		}()
+	}
+	wg.Wait()
+	close(tokens)
+
+	seen := map[string]bool{}
+	for token := range tokens {
+		seen[token] = true
+	}
+	if len(seen) != 1 {
+		t.Errorf("guests got %d different tabs, want 1", len(seen))
+	}
+
+	var open int64
+	s.db.Model(&models.TableSession{}).Where("table_id = ? AND status = ?", table.ID, models.TableSessionOpen).Count(&open)
+	if open != 1 {
+		t.Errorf("table has %d open tabs, want 1", open)
+	}
+}
+
+func TestOneOpenTabPerTableIndex(t *testing.T) {
+	s := newTestServer(t)
+
+	cafe := s.createCafe("Kopi Test", s.createUser("owner", models.RoleOwner))
+	session := func(status string) error {
+		return s.db.Create(&models.TableSession{
+			ID:       uuid.New().String(),
+			CafeID:   cafe.ID,
+			TableID:  "table-1",
+			Token:    uuid.New().String(),
+			Status:   status,
+			OpenedAt: time.Now(),
+		}).Error
+	}
+
+	if err := session(models.TableSessionClosed); err != nil {
+		t.Fatalf("closed tab: %v", err)
+	}
+	if err := session(models.TableSessionOpen); err != nil {
+		t.Fatalf("first open tab: %v", err)
+	}
+	if err := session(models.TableSessionOpen); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Errorf("second open tab: got %v, want a duplicate key error", err)
+	}
+}
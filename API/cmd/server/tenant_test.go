@@ -0,0 +1,203 @@
+package main
+
+import (
+	"testing"
+
+	"siipcoffe-api/internal/middleware"
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// tenant is a cafe with one of everything, and tokens for its owner and
+// manager
+type tenant struct {
+	cafe      models.Cafe
+	menu      models.Menu
+	inventory models.Inventory
+	table     models.CafeTable
+	order     models.Order
+	review    models.CafeReview
+	staff     models.CafeStaff // a barista
+	owner     string
+	manager   string
+}
+
+func (s *testServer) createTenant(name string, owner models.User) tenant {
+	s.t.Helper()
+
+	cafe := s.createCafe(name, owner)
+	manager := s.createUser(name+"-manager", models.RoleCustomer)
+	s.addStaff(cafe, manager, models.StaffRoleManager)
+	customer := s.createUser(name+"-customer", models.RoleCustomer)
+
+	t := tenant{
+		cafe:    cafe,
+		menu:    s.createMenu(cafe, name+" Latte", 25000),
+		staff:   s.addStaff(cafe, s.createUser(name+"-barista", models.RoleCustomer), models.StaffRoleBarista),
+		owner:   s.login(owner),
+		manager: s.login(manager),
+	}
+	t.order = s.createOrder(cafe, customer, t.menu)
+
+	t.inventory = models.Inventory{ID: uuid.New().String(), CafeID: cafe.ID, Name: name + " Milk", Unit: "liter", CurrentStock: 10}
+	t.table = models.CafeTable{ID: uuid.New().String(), CafeID: cafe.ID, Number: "A1", Label: name + " window"}
+	t.review = models.CafeReview{ID: uuid.New().String(), CafeID: cafe.ID, UserID: customer.ID, Rating: 5, Comment: "Enak"}
+	for _, record := range []interface{}{&t.inventory, &t.table, &t.review} {
+		if err := s.db.Create(record).Error; err != nil {
+			s.t.Fatalf("seed %s: %v", name, err)
+		}
+	}
+
+	return t
+}
+
+func TestCrossTenantIsolation(t *testing.T) {
+	s := newTestServer(t)
+
+	ownerA := s.createUser("owner-a", models.RoleOwner)
+	a := s.createTenant("cafe-a", ownerA)
+	s.createCafe("cafe-a-branch", ownerA) // owner A must pick a branch
+	b := s.createTenant("cafe-b", s.createUser("owner-b", models.RoleOwner))
+
+	branchA := []string{middleware.BranchHeader, a.cafe.ID}
+	branchB := []string{middleware.BranchHeader, b.cafe.ID}
+
+	tests := []struct {
+		name    string
+		token   string
+		headers []string
+		method  string
+		path    string
+		body    interface{}
+	}{
+		// Cafe A's owner on cafe B's resources
+		{"owner updates menu", a.owner, branchA, "PUT", "/api/v1/menu/" + b.menu.ID, fiber.Map{"name": "Hacked"}},
+		{"owner deletes menu", a.owner, branchA, "DELETE", "/api/v1/menu/" + b.menu.ID, nil},
+		{"owner reads recipe", a.owner, branchA, "GET", "/api/v1/menu/" + b.menu.ID + "/recipe", nil},
+		{"owner changes order status", a.owner, branchA, "PUT", "/api/v1/orders/" + b.order.ID + "/status", fiber.Map{"status": "cancelled"}},
+		{"owner updates inventory", a.owner, branchA, "PUT", "/api/v1/inventory/" + b.inventory.ID, fiber.Map{"name": "Hacked"}},
+		{"owner adds stock movement", a.owner, branchA, "POST", "/api/v1/inventory/" + b.inventory.ID + "/movements", fiber.Map{"type": "out", "quantity": 5, "reason": "waste"}},
+		{"owner reads stock movements", a.owner, branchA, "GET", "/api/v1/inventory/" + b.inventory.ID + "/movements", nil},
+		{"owner deletes inventory", a.owner, branchA, "DELETE", "/api/v1/inventory/" + b.inventory.ID, nil},
+		{"owner updates table", a.owner, branchA, "PUT", "/api/v1/owner/tables/" + b.table.ID, fiber.Map{"label": "Hacked"}},
+		{"owner reads table QR", a.owner, branchA, "GET", "/api/v1/owner/tables/" + b.table.ID + "/qr", nil},
+		{"owner deletes table", a.owner, branchA, "DELETE", "/api/v1/owner/tables/" + b.table.ID, nil},
+		{"owner updates staff", a.owner, branchA, "PUT", "/api/v1/owner/staff/" + b.staff.ID, fiber.Map{"role": models.StaffRoleManager}},
+		{"owner replies to review", a.owner, branchA, "PUT", "/api/v1/owner/reviews/" + b.review.ID + "/reply", fiber.Map{"reply": "Hacked"}},
+
+		// Cafe A's owner picking cafe B as branch
+		{"owner selects other cafe", a.owner, branchB, "GET", "/api/v1/owner/cafe", nil},
+		{"owner lists other cafe orders", a.owner, branchB, "GET", "/api/v1/orders/cafe", nil},
+		{"owner lists other cafe inventory", a.owner, branchB, "GET", "/api/v1/inventory", nil},
+		{"owner lists other cafe tables", a.owner, branchB, "GET", "/api/v1/owner/tables", nil},
+		{"owner lists other cafe staff", a.owner, branchB, "GET", "/api/v1/owner/staff", nil},
+		{"owner updates menu as other cafe", a.owner, branchB, "PUT", "/api/v1/menu/" + b.menu.ID, fiber.Map{"name": "Hacked"}},
+		{"owner updates staff as other cafe", a.owner, branchB, "PUT", "/api/v1/owner/staff/" + b.staff.ID, fiber.Map{"role": models.StaffRoleManager}},
+		{"owner replies to review as other cafe", a.owner, branchB, "PUT", "/api/v1/owner/reviews/" + b.review.ID + "/reply", fiber.Map{"reply": "Hacked"}},
+
+		// Cafe A's manager on cafe B's resources, with and without the header
+		{"manager changes order status", a.manager, nil, "PUT", "/api/v1/orders/" + b.order.ID + "/status", fiber.Map{"status": "cancelled"}},
+		{"manager changes order status as other cafe", a.manager, branchB, "PUT", "/api/v1/orders/" + b.order.ID + "/status", fiber.Map{"status": "cancelled"}},
+		{"manager bumps order", a.manager, branchB, "POST", "/api/v1/kitchen/orders/" + b.order.ID + "/bump", nil},
+		{"manager updates inventory", a.manager, branchB, "PUT", "/api/v1/inventory/" + b.inventory.ID, fiber.Map{"name": "Hacked"}},
+		{"manager adds stock movement", a.manager, branchB, "POST", "/api/v1/inventory/" + b.inventory.ID + "/movements", fiber.Map{"type": "out", "quantity": 5, "reason": "waste"}},
+		{"manager reads stock movements", a.manager, nil, "GET", "/api/v1/inventory/" + b.inventory.ID + "/movements", nil},
+		{"manager updates menu", a.manager, branchB, "PUT", "/api/v1/menu/" + b.menu.ID, fiber.Map{"name": "Hacked"}},
+		{"manager updates table", a.manager, branchB, "PUT", "/api/v1/owner/tables/" + b.table.ID, fiber.Map{"label": "Hacked"}},
+		{"manager updates staff", a.manager, branchB, "PUT", "/api/v1/owner/staff/" + b.staff.ID, fiber.Map{"role": models.StaffRoleManager}},
+		{"manager replies to review", a.manager, branchB, "PUT", "/api/v1/owner/reviews/" + b.review.ID + "/reply", fiber.Map{"reply": "Hacked"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := s.request(tt.method, tt.path, tt.token, tt.body, tt.headers...)
+			if res.Status != fiber.StatusForbidden && res.Status != fiber.StatusNotFound {
+				t.Errorf("got status %d, want 403 or 404 (body %v)", res.Status, res.Body)
+			}
+		})
+	}
+
+	// Nothing of cafe B changed
+	var menu models.Menu
+	if err := s.db.First(&menu, "id = ?", b.menu.ID).Error; err != nil || menu.Name != b.menu.Name {
+		t.Errorf("cafe B menu changed: %+v, %v", menu, err)
+	}
+	var order models.Order
+	if s.db.First(&order, "id = ?", b.order.ID); order.Status != b.order.Status {
+		t.Errorf("cafe B order status changed to %s", order.Status)
+	}
+	var inventory models.Inventory
+	if err := s.db.First(&inventory, "id = ?", b.inventory.ID).Error; err != nil || inventory.Name != b.inventory.Name || inventory.CurrentStock != b.inventory.CurrentStock {
+		t.Errorf("cafe B inventory changed: %+v, %v", inventory, err)
+	}
+	var movements int64
+	if s.db.Model(&models.StockMovement{}).Where("inventory_id = ?", b.inventory.ID).Count(&movements); movements != 0 {
+		t.Errorf("cafe B inventory has %d movements", movements)
+	}
+	var table models.CafeTable
+	if err := s.db.First(&table, "id = ?", b.table.ID).Error; err != nil || table.Label != b.table.Label {
+		t.Errorf("cafe B table changed: %+v, %v", table, err)
+	}
+	var staff models.CafeStaff
+	if s.db.First(&staff, "id = ?", b.staff.ID); staff.Role != models.StaffRoleBarista {
+		t.Errorf("cafe B staff role changed to %s", staff.Role)
+	}
+	var review models.CafeReview
+	if s.db.First(&review, "id = ?", b.review.ID); review.OwnerReply != "" {
+		t.Errorf("cafe B review got reply %q", review.OwnerReply)
+	}
+}
+
+func TestTenantListsStayInCafe(t *testing.T) {
+	s := newTestServer(t)
+
+	ownerA := s.createUser("owner-a", models.RoleOwner)
+	a := s.createTenant("cafe-a", ownerA)
+	s.createCafe("cafe-a-branch", ownerA)
+	b := s.createTenant("cafe-b", s.createUser("owner-b", models.RoleOwner))
+
+	// Staff act on the cafe in their token, whatever branch they ask for
+	lists := []struct {
+		path  string
+		field string
+		id    string
+		other string
+	}{
+		{"/api/v1/inventory", "inventory", a.inventory.ID, b.inventory.ID},
+		{"/api/v1/orders/cafe", "", a.order.ID, b.order.ID},
+	}
+	for _, list := range lists {
+		res := s.request("GET", list.path, a.manager, nil, middleware.BranchHeader, b.cafe.ID)
+		expectStatus(t, res, fiber.StatusOK, "manager lists "+list.path)
+
+		// An empty field means the list is the data itself
+		items, _ := res.Body["data"].([]interface{})
+		if list.field != "" {
+			items, _ = res.data()[list.field].([]interface{})
+		}
+		ids := map[string]bool{}
+		for _, item := range items {
+			if item, ok := item.(map[string]interface{}); ok {
+				ids[item["id"].(string)] = true
+			}
+		}
+		if !ids[list.id] || ids[list.other] {
+			t.Errorf("%s: got %v, want cafe A's %s only", list.path, ids, list.id)
+		}
+	}
+
+	// The owner reaches their own resources through the branch header
+	branchA := []string{middleware.BranchHeader, a.cafe.ID}
+	res := s.request("PUT", "/api/v1/menu/"+a.menu.ID, a.owner, fiber.Map{"name": "Kopi Susu"}, branchA...)
+	expectStatus(t, res, fiber.StatusOK, "owner updates own menu")
+	res = s.request("PUT", "/api/v1/owner/tables/"+a.table.ID, a.owner, fiber.Map{"label": "Corner"}, branchA...)
+	expectStatus(t, res, fiber.StatusOK, "owner updates own table")
+	res = s.request("PUT", "/api/v1/owner/reviews/"+a.review.ID+"/reply", a.owner, fiber.Map{"reply": "Terima kasih"}, branchA...)
+	expectStatus(t, res, fiber.StatusOK, "owner replies to own review")
+	res = s.request("PUT", "/api/v1/inventory/"+a.inventory.ID, a.manager, fiber.Map{"name": "Fresh Milk", "unit": "liter"})
+	expectStatus(t, res, fiber.StatusOK, "manager updates own inventory")
+	res = s.request("PUT", "/api/v1/orders/"+a.order.ID+"/status", a.manager, fiber.Map{"status": "confirmed"})
+	expectStatus(t, res, fiber.StatusOK, "manager confirms own order")
+}
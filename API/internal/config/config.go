@@ -1,9 +1,20 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"time"
 )
 
+// DefaultPaymentWebhookSecret is only good for local development, the server
+// refuses to start with it anywhere else
+const DefaultPaymentWebhookSecret = "default-webhook-secret"
+
+// DefaultTableQRSecret is only good for local development, anyone knowing it
+// can sign a QR token for any table
+const DefaultTableQRSecret = "default-table-qr-secret"
+
 type Config struct {
 	Port            string
 	Environment     string
@@ -21,9 +32,17 @@ type Config struct {
 	EthereumRPCURL  string
 	ContractAddress string
 	PrivateKey      string
+	PaymentWebhookSecret string
+	PaymentFakeProvider bool // registers the fake payment provider, never enable in production
+	PaymentSweepInterval string
+	TableQRSecret   string
+	TableQRURL      string
 	CafeName        string
 	CafeAddress     string
 	CafePhone       string
+	AdminEmail      string
+	AdminPassword   string
+	AdminName       string
 }
 
 func Load() *Config {
@@ -44,10 +63,35 @@ func Load() *Config {
 		EthereumRPCURL:  getEnv("ETHEREUM_RPC_URL", ""),
 		ContractAddress: getEnv("CONTRACT_ADDRESS", ""),
 		PrivateKey:      getEnv("PRIVATE_KEY", ""),
+		PaymentWebhookSecret: getEnv("PAYMENT_WEBHOOK_SECRET", DefaultPaymentWebhookSecret),
+		PaymentFakeProvider: getEnv("PAYMENT_FAKE_PROVIDER", "false") == "true",
+		PaymentSweepInterval: getEnv("PAYMENT_SWEEP_INTERVAL", "1m"),
+		TableQRSecret:   getEnv("TABLE_QR_SECRET", DefaultTableQRSecret),
+		TableQRURL:      getEnv("TABLE_QR_URL", "http://localhost:3000/table"),
 		CafeName:        getEnv("CAFE_NAME", "SiipCoffee"),
 		CafeAddress:     getEnv("CAFE_ADDRESS", "Jl. Cafe No. 123, Jakarta"),
 		CafePhone:       getEnv("CAFE_PHONE", "+62 812-3456-7890"),
+		AdminEmail:      getEnv("ADMIN_EMAIL", ""),
+		AdminPassword:   getEnv("ADMIN_PASSWORD", ""),
+		AdminName:       getEnv("ADMIN_NAME", "Platform Admin"),
+	}
+}
+
+// Validate rejects settings that are unsafe outside local development
+func (c *Config) Validate() error {
+	if c.Environment != "development" && c.PaymentWebhookSecret == DefaultPaymentWebhookSecret {
+		return errors.New("PAYMENT_WEBHOOK_SECRET must be set outside development")
+	}
+	if c.Environment != "development" && c.TableQRSecret == DefaultTableQRSecret {
+		return errors.New("TABLE_QR_SECRET must be set outside development")
+	}
+	if c.Environment == "production" && c.PaymentFakeProvider {
+		return errors.New("PAYMENT_FAKE_PROVIDER cannot be enabled in production")
+	}
+	if interval, err := time.ParseDuration(c.PaymentSweepInterval); err != nil || interval <= 0 {
+		return fmt.Errorf("PAYMENT_SWEEP_INTERVAL must be a positive duration such as 1m, got %q", c.PaymentSweepInterval)
 	}
+	return nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -0,0 +1,65 @@
+package config
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		secret      string
+		qrSecret    string
+		fake        bool
+		wantErr     bool
+	}{
+		{"development defaults", "development", DefaultPaymentWebhookSecret, DefaultTableQRSecret, true, false},
+		{"staging with default secret", "staging", DefaultPaymentWebhookSecret, "qr", false, true},
+		{"production with default secret", "production", DefaultPaymentWebhookSecret, "qr", false, true},
+		{"production with default QR secret", "production", "s3cret", DefaultTableQRSecret, false, true},
+		{"staging with default QR secret", "staging", "s3cret", DefaultTableQRSecret, false, true},
+		{"production with secrets", "production", "s3cret", "qr", false, false},
+		{"production with fake provider", "production", "s3cret", "qr", true, true},
+		{"staging with fake provider", "staging", "s3cret", "qr", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Environment:          tt.environment,
+				PaymentWebhookSecret: tt.secret,
+				TableQRSecret:        tt.qrSecret,
+				PaymentSweepInterval: "1m",
+				PaymentFakeProvider:  tt.fake,
+			}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSweepInterval(t *testing.T) {
+	tests := []struct {
+		interval string
+		wantErr  bool
+	}{
+		{"1m", false},
+		{"30s", false},
+		{"0s", true},
+		{"0", true},
+		{"-1m", true},
+		{"soon", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.interval, func(t *testing.T) {
+			cfg := &Config{
+				Environment:          "development",
+				PaymentSweepInterval: tt.interval,
+			}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,13 +1,17 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"siipcoffe-api/internal/config"
 	"siipcoffe-api/internal/models"
+	"siipcoffe-api/pkg/businesshours"
 
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -21,7 +25,8 @@ func Initialize(cfg *config.Config) (*gorm.DB, error) {
 
 	// Configure GORM
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger:         logger.Default.LogMode(logger.Info),
+		TranslateError: true, // unique violations come back as gorm.ErrDuplicatedKey
 	}
 
 	// Open database connection
@@ -33,13 +38,30 @@ func Initialize(cfg *config.Config) (*gorm.DB, error) {
 	// Auto-migrate the schema
 	if err := db.AutoMigrate(
 		&models.User{},
+		&models.Organization{},
 		&models.Cafe{},
 		&models.CafeReview{},
+		&models.CafeModerationLog{},
 		&models.CafeStaff{},
+		&models.StaffInvitation{},
+		&models.CafeTable{},
+		&models.TableSession{},
+		&models.CatalogItem{},
+		&models.MenuCategory{},
 		&models.Menu{},
+		&models.MenuOptionGroup{},
+		&models.MenuOption{},
+		&models.RecipeItem{},
 		&models.Order{},
 		&models.OrderItem{},
+		&models.OrderItemOption{},
+		&models.OrderStatusHistory{},
+		&models.OrderEvent{},
+		&models.ReviewItemRating{},
+		&models.ReviewReport{},
 		&models.Payment{},
+		&models.Refund{},
+		&models.RefundItem{},
 		&models.Chat{},
 		&models.Inventory{},
 		&models.StockMovement{},
@@ -48,18 +70,159 @@ func Initialize(cfg *config.Config) (*gorm.DB, error) {
 		&models.LoyaltyReward{},
 		&models.MemberReward{},
 		&models.LoyaltyTransaction{},
+		&models.IdempotencyKey{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if err := setupPaymentIndexes(db); err != nil {
+		return nil, fmt.Errorf("failed to set up payment indexes: %w", err)
+	}
+
+	if err := setupTableSessionIndexes(db); err != nil {
+		return nil, fmt.Errorf("failed to set up table session indexes: %w", err)
+	}
+
+	if err := setupMenuSearch(db); err != nil {
+		return nil, fmt.Errorf("failed to set up menu search: %w", err)
+	}
+
+	if err := backfillRatingHistograms(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill rating histograms: %w", err)
+	}
+
 	// Seed initial data
 	if err := seedData(db); err != nil {
 		return nil, fmt.Errorf("failed to seed database: %w", err)
 	}
 
+	if err := backfillMenuCategories(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill menu categories: %w", err)
+	}
+
+	if err := seedPlatformAdmin(db, cfg); err != nil {
+		return nil, fmt.Errorf("failed to seed platform admin: %w", err)
+	}
+
 	return db, nil
 }
 
+// backfillRatingHistograms fills the star histogram of cafes reviewed before
+// it existed, so that incremental rating updates start from the right counts
+func backfillRatingHistograms(db *gorm.DB) error {
+	reviewed := db.Model(&models.CafeReview{}).Select("cafe_id").Where("status = ?", models.ReviewStatusPublished)
+
+	var cafeIDs []string
+	err := db.Model(&models.Cafe{}).
+		Where("rating1_count + rating2_count + rating3_count + rating4_count + rating5_count = 0").
+		Where("id IN (?)", reviewed).
+		Pluck("id", &cafeIDs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, cafeID := range cafeIDs {
+		var rows []struct {
+			Rating int
+			Count  int
+		}
+		err := db.Model(&models.CafeReview{}).
+			Select("rating, COUNT(*) as count").
+			Where("cafe_id = ? AND status = ?", cafeID, models.ReviewStatusPublished).
+			Group("rating").
+			Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{}
+		total, sum := 0, 0
+		for _, row := range rows {
+			updates[fmt.Sprintf("rating%d_count", row.Rating)] = row.Count
+			total += row.Count
+			sum += row.Rating * row.Count
+		}
+		if total == 0 {
+			continue
+		}
+		updates["rating_count"] = total
+		updates["rating_average"] = float64(sum) / float64(total)
+
+		if err := db.Model(&models.Cafe{}).Where("id = ?", cafeID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillMenuCategories creates the cafe categories named by menu items from
+// before categories existed and links the items to them
+func backfillMenuCategories(db *gorm.DB) error {
+	var rows []struct {
+		CafeID   string
+		Category string
+	}
+	err := db.Unscoped().Model(&models.Menu{}).
+		Select("DISTINCT cafe_id, category").
+		Where("category_id IS NULL").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		category := models.NewMenuCategory(uuid.New().String(), row.CafeID, row.Category)
+		if category.Slug == "" {
+			continue
+		}
+		if err := db.Where(models.MenuCategory{CafeID: row.CafeID, Slug: category.Slug}).FirstOrCreate(&category).Error; err != nil {
+			return err
+		}
+		err := db.Unscoped().Model(&models.Menu{}).
+			Where("cafe_id = ? AND category = ? AND category_id IS NULL", row.CafeID, row.Category).
+			Updates(map[string]interface{}{"category_id": category.ID, "category": category.Slug}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedPlatformAdmin creates the platform admin configured with ADMIN_EMAIL and
+// ADMIN_PASSWORD. An existing account with that email is promoted to admin
+// and keeps its password.
+func seedPlatformAdmin(db *gorm.DB, cfg *config.Config) error {
+	if cfg.AdminEmail == "" || cfg.AdminPassword == "" {
+		return nil
+	}
+
+	var existing models.User
+	err := db.Where("email = ?", cfg.AdminEmail).First(&existing).Error
+	if err == nil {
+		if existing.Role == models.RoleAdmin {
+			return nil
+		}
+		return db.Model(&existing).Update("role", models.RoleAdmin).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(cfg.AdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	admin := models.User{
+		ID:       uuid.New().String(),
+		Name:     cfg.AdminName,
+		Email:    cfg.AdminEmail,
+		Password: string(hashedPassword),
+		Role:     models.RoleAdmin,
+	}
+	return db.Create(&admin).Error
+}
+
 func seedData(db *gorm.DB) error {
 	// Check if data already exists
 	var cafeCount int64
@@ -98,18 +261,40 @@ func seedData(db *gorm.DB) error {
 		Website:                "https://siipcoffe.com",
 		CoordinateLat:          -6.2088,
 		CoordinateLng:          106.8456,
-		BusinessHours:          `{"monday":{"open":"07:00","close":"22:00"},"tuesday":{"open":"07:00","close":"22:00"},"wednesday":{"open":"07:00","close":"22:00"},"thursday":{"open":"07:00","close":"22:00"},"friday":{"open":"07:00","close":"23:00"},"saturday":{"open":"08:00","close":"23:00"},"sunday":{"open":"08:00","close":"22:00"}}`,
-		IsOpen:                 true,
+		BusinessHours: businesshours.Schedule{
+			Weekly: map[string][]businesshours.Period{
+				"monday":    {{Open: "07:00", Close: "22:00"}},
+				"tuesday":   {{Open: "07:00", Close: "22:00"}},
+				"wednesday": {{Open: "07:00", Close: "22:00"}},
+				"thursday":  {{Open: "07:00", Close: "22:00"}},
+				"friday":    {{Open: "07:00", Close: "11:30"}, {Open: "13:00", Close: "23:00"}},
+				"saturday":  {{Open: "08:00", Close: "23:00"}},
+				"sunday":    {{Open: "08:00", Close: "22:00"}},
+			},
+		},
+		Timezone:               businesshours.DefaultTimezone,
 		IsVerified:             true,
 		RatingAverage:          4.5,
 		RatingCount:            150,
+		Rating1Count:           2,
+		Rating2Count:           3,
+		Rating3Count:           10,
+		Rating4Count:           38,
+		Rating5Count:           97,
 		TaxPercentage:          10,
 		ServiceChargePercentage: 5,
 		DeliveryFee:            10000,
 		MinOrderAmount:         25000,
 		MaxDeliveryDistance:    5,
-		Features:               `["wifi","outdoor_seating","delivery","take_away","ac","parking"]`,
-		SocialMedia:            `{"instagram":"@siipcoffe","facebook":"SiipCoffee","twitter":"SiipCoffeeID"}`,
+		Features: models.CafeFeatures{
+			models.FeatureWifi, models.FeatureOutdoorSeating, models.FeatureDelivery,
+			models.FeatureTakeAway, models.FeatureAC, models.FeatureParking,
+		},
+		SocialMedia: models.SocialMedia{
+			Instagram: "@siipcoffe",
+			Facebook:  "SiipCoffee",
+			Twitter:   "SiipCoffeeID",
+		},
 		Status:                 "active",
 	}
 
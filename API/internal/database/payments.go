@@ -0,0 +1,49 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"siipcoffe-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// setupPaymentIndexes allows at most one active payment per order. Extra
+// pending payments left by earlier builds, which did not enforce this, are
+// expired first, keeping the one the order points to.
+func setupPaymentIndexes(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		duplicated := tx.Model(&models.Payment{}).
+			Select("order_id").
+			Where("status IN ?", models.ActivePaymentStatuses).
+			Group("order_id").
+			Having("COUNT(*) > 1")
+		current := tx.Model(&models.Order{}).Select("payment_id").Where("payment_id IS NOT NULL")
+
+		result := tx.Model(&models.Payment{}).
+			Where("status = ? AND order_id IN (?) AND id NOT IN (?)", string(models.PaymentStatusPending), duplicated, current).
+			Update("status", string(models.PaymentStatusExpired))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Expired %d duplicate pending payment(s)", result.RowsAffected)
+		}
+
+		// SQLite does not bind parameters in index definitions
+		statuses := make([]string, 0, len(models.ActivePaymentStatuses))
+		for _, status := range models.ActivePaymentStatuses {
+			statuses = append(statuses, "'"+status+"'")
+		}
+		err := tx.Exec(fmt.Sprintf(
+			"CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_active_order ON payments (order_id) WHERE status IN (%s)",
+			strings.Join(statuses, ", "),
+		)).Error
+		if err != nil {
+			return fmt.Errorf("orders with more than one active payment must be resolved by hand: %w", err)
+		}
+		return nil
+	})
+}
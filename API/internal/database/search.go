@@ -0,0 +1,81 @@
+package database
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// MenuSearchTable is the SQLite FTS5 index of menu items. It is only created
+// when the SQLite build includes FTS5 (go build -tags sqlite_fts5); without it
+// menu search falls back to LIKE matching.
+const MenuSearchTable = "menu_search"
+
+// menuSearchTriggers keep the index in step with the menus table. Index rows
+// share the rowid of their menu item.
+var menuSearchTriggers = []string{
+	`CREATE TRIGGER menus_search_insert AFTER INSERT ON menus BEGIN
+		INSERT INTO menu_search (rowid, menu_id, name, category, ingredients, description)
+		VALUES (new.rowid, new.id, new.name, new.category, new.ingredients, new.description);
+	END`,
+	`CREATE TRIGGER menus_search_update AFTER UPDATE ON menus BEGIN
+		DELETE FROM menu_search WHERE rowid = old.rowid;
+		INSERT INTO menu_search (rowid, menu_id, name, category, ingredients, description)
+		VALUES (new.rowid, new.id, new.name, new.category, new.ingredients, new.description);
+	END`,
+	`CREATE TRIGGER menus_search_delete AFTER DELETE ON menus BEGIN
+		DELETE FROM menu_search WHERE rowid = old.rowid;
+	END`,
+}
+
+var menuSearchTriggerNames = []string{"menus_search_insert", "menus_search_update", "menus_search_delete"}
+
+// setupMenuSearch creates the menu search index and its triggers and rebuilds
+// the index, since migrations may have recreated the menus table. Without
+// FTS5 the triggers are dropped, so a database indexed by an earlier build
+// keeps working; MenuSearchIndexed then reports false.
+func setupMenuSearch(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range menuSearchTriggerNames {
+			if err := tx.Exec("DROP TRIGGER IF EXISTS " + name).Error; err != nil {
+				return err
+			}
+		}
+
+		var fts5 bool
+		if err := tx.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5).Error; err != nil {
+			return err
+		}
+		if !fts5 {
+			log.Printf("SQLite was built without FTS5, menu search uses LIKE matching")
+			return nil
+		}
+
+		err := tx.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS menu_search USING fts5(
+			menu_id UNINDEXED, name, category, ingredients, description,
+			tokenize = 'unicode61 remove_diacritics 2', prefix = '2 3'
+		)`).Error
+		if err != nil {
+			return err
+		}
+
+		for _, trigger := range menuSearchTriggers {
+			if err := tx.Exec(trigger).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("DELETE FROM menu_search").Error; err != nil {
+			return err
+		}
+		return tx.Exec(`INSERT INTO menu_search (rowid, menu_id, name, category, ingredients, description)
+			SELECT rowid, id, name, category, ingredients, description FROM menus`).Error
+	})
+}
+
+// MenuSearchIndexed reports whether menu search can use the FTS5 index
+func MenuSearchIndexed(db *gorm.DB) bool {
+	var triggers int64
+	err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?", menuSearchTriggerNames[0]).Scan(&triggers).Error
+	return err == nil && triggers > 0
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"fmt"
+
+	"siipcoffe-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// setupTableSessionIndexes allows at most one open tab per table. Tables with
+// more than one open tab, left by earlier builds, are not closed here since
+// their orders may still be unpaid.
+func setupTableSessionIndexes(db *gorm.DB) error {
+	// SQLite does not bind parameters in index definitions
+	err := db.Exec(fmt.Sprintf(
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_table_sessions_open_table ON table_sessions (table_id) WHERE status = '%s'",
+		models.TableSessionOpen,
+	)).Error
+	if err != nil {
+		return fmt.Errorf("tables with more than one open tab must be closed by staff first: %w", err)
+	}
+	return nil
+}
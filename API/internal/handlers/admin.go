@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AdminHandler struct {
+	db *gorm.DB
+}
+
+func NewAdminHandler(db *gorm.DB) *AdminHandler {
+	return &AdminHandler{db: db}
+}
+
+type ModerationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// AdminCafeResponse is a cafe as seen by platform admins, with its owner
+type AdminCafeResponse struct {
+	models.CafeResponse
+	Owner         models.UserResponse        `json:"owner"`
+	SuspendedAt   *time.Time                 `json:"suspended_at,omitempty"`
+	ModerationLog []models.CafeModerationLog `json:"moderation_log,omitempty"`
+}
+
+// ListCafes lists all cafes whatever their status. verified=false gives the
+// queue of cafes waiting for review, oldest first.
+func (h *AdminHandler) ListCafes(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.Cafe{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if verified := c.Query("verified"); verified != "" {
+		query = query.Where("is_verified = ?", verified == "true")
+	}
+	if search := c.Query("search"); search != "" {
+		query = query.Where("name LIKE ? OR city LIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var cafes []models.Cafe
+	err := query.Preload("Owner").Order("created_at ASC").Offset(offset).Limit(limit).Find(&cafes).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to get cafes",
+		})
+	}
+
+	responses := make([]AdminCafeResponse, 0, len(cafes))
+	for i := range cafes {
+		responses = append(responses, adminCafeResponse(&cafes[i], nil))
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"cafes": responses,
+			"pagination": fiber.Map{
+				"page":  page,
+				"limit": limit,
+				"total": total,
+				"pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		},
+	})
+}
+
+// GetCafe returns a cafe with its owner and moderation history
+func (h *AdminHandler) GetCafe(c *fiber.Ctx) error {
+	cafe, ferr := h.findCafe(c.Params("id"))
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var logs []models.CafeModerationLog
+	h.db.Preload("Admin").Where("cafe_id = ?", cafe.ID).Order("created_at DESC").Find(&logs)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    adminCafeResponse(cafe, logs),
+	})
+}
+
+// VerifyCafe marks a cafe as reviewed and genuine
+func (h *AdminHandler) VerifyCafe(c *fiber.Ctx) error {
+	return h.moderate(c, models.ModerationActionVerify, func(cafe *models.Cafe, req ModerationRequest) (map[string]interface{}, *fiber.Error) {
+		if cafe.IsVerified {
+			return nil, fiber.NewError(fiber.StatusConflict, "Cafe is already verified")
+		}
+		return map[string]interface{}{
+			"is_verified": true,
+			"verified_at": time.Now(),
+		}, nil
+	})
+}
+
+// UnverifyCafe withdraws a cafe's verification
+func (h *AdminHandler) UnverifyCafe(c *fiber.Ctx) error {
+	return h.moderate(c, models.ModerationActionUnverify, func(cafe *models.Cafe, req ModerationRequest) (map[string]interface{}, *fiber.Error) {
+		if !cafe.IsVerified {
+			return nil, fiber.NewError(fiber.StatusConflict, "Cafe is not verified")
+		}
+		if req.Reason == "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "A reason is required")
+		}
+		return map[string]interface{}{
+			"is_verified": false,
+			"verified_at": nil,
+		}, nil
+	})
+}
+
+// SuspendCafe takes a cafe off the platform. Suspended cafes are not listed,
+// their menus are hidden and they cannot take orders or payments.
+func (h *AdminHandler) SuspendCafe(c *fiber.Ctx) error {
+	return h.moderate(c, models.ModerationActionSuspend, func(cafe *models.Cafe, req ModerationRequest) (map[string]interface{}, *fiber.Error) {
+		if cafe.Status == models.CafeStatusSuspended {
+			return nil, fiber.NewError(fiber.StatusConflict, "Cafe is already suspended")
+		}
+		if req.Reason == "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "A reason is required")
+		}
+		return map[string]interface{}{
+			"status":            models.CafeStatusSuspended,
+			"suspended_at":      time.Now(),
+			"suspension_reason": req.Reason,
+		}, nil
+	})
+}
+
+// ReinstateCafe lifts a suspension
+func (h *AdminHandler) ReinstateCafe(c *fiber.Ctx) error {
+	return h.moderate(c, models.ModerationActionReinstate, func(cafe *models.Cafe, req ModerationRequest) (map[string]interface{}, *fiber.Error) {
+		if cafe.Status != models.CafeStatusSuspended {
+			return nil, fiber.NewError(fiber.StatusConflict, "Cafe is not suspended")
+		}
+		return map[string]interface{}{
+			"status":            models.CafeStatusActive,
+			"suspended_at":      nil,
+			"suspension_reason": "",
+		}, nil
+	})
+}
+
+// GetPlatformMetrics returns platform wide counts and sales
+func (h *AdminHandler) GetPlatformMetrics(c *fiber.Ctx) error {
+	startDate := c.Query("start_date", "")
+	endDate := c.Query("end_date", "")
+	inPeriod := func(q *gorm.DB, column string) *gorm.DB {
+		if startDate != "" && endDate != "" {
+			return q.Where(column+" BETWEEN ? AND ?", startDate, endDate)
+		}
+		// Default to last 30 days
+		return q.Where(column+" >= ?", time.Now().AddDate(0, 0, -30))
+	}
+
+	type countByKey struct {
+		Key   string `json:"key"`
+		Count int64  `json:"count"`
+	}
+
+	var usersByRole []countByKey
+	h.db.Model(&models.User{}).Select("role as key, COUNT(*) as count").Group("role").Scan(&usersByRole)
+
+	var cafesByStatus []countByKey
+	h.db.Model(&models.Cafe{}).Select("status as key, COUNT(*) as count").Group("status").Scan(&cafesByStatus)
+
+	var pendingReview int64
+	h.db.Model(&models.Cafe{}).Where("is_verified = ? AND status <> ?", false, models.CafeStatusSuspended).Count(&pendingReview)
+
+	var newCafes, newUsers int64
+	inPeriod(h.db.Model(&models.Cafe{}), "created_at").Count(&newCafes)
+	inPeriod(h.db.Model(&models.User{}), "created_at").Count(&newUsers)
+
+	var sales struct {
+		Orders  int64
+		Revenue float64
+	}
+	inPeriod(h.db.Model(&models.Order{}), "created_at").
+		Select("COUNT(*) as orders, COALESCE(SUM(total_amount), 0) as revenue").
+		Where("status <> ?", string(models.OrderStatusCancelled)).
+		Scan(&sales)
+
+	var refunded float64
+	inPeriod(h.db.Model(&models.Refund{}), "created_at").
+		Where("status = ?", models.RefundStatusCompleted).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&refunded)
+
+	type topCafe struct {
+		CafeID  string  `json:"cafe_id"`
+		Name    string  `json:"name"`
+		Orders  int64   `json:"orders"`
+		Revenue float64 `json:"revenue"`
+	}
+	topCafes := []topCafe{}
+	inPeriod(h.db.Table("orders"), "orders.created_at").
+		Select("orders.cafe_id, caves.name, COUNT(*) as orders, SUM(orders.total_amount) as revenue").
+		Joins("JOIN caves ON caves.id = orders.cafe_id").
+		Where("orders.status <> ?", string(models.OrderStatusCancelled)).
+		Group("orders.cafe_id, caves.name").
+		Order("revenue DESC").
+		Limit(10).
+		Scan(&topCafes)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"users_by_role":        usersByRole,
+			"cafes_by_status":      cafesByStatus,
+			"cafes_pending_review": pendingReview,
+			"new_cafes":            newCafes,
+			"new_users":            newUsers,
+			"total_orders":         sales.Orders,
+			"total_revenue":        sales.Revenue,
+			"total_refunds":        refunded,
+			"average_order_value":  averageOrderValue(sales.Revenue, sales.Orders),
+			"top_cafes":            topCafes,
+		},
+	})
+}
+
+// GetAllOrders lists orders across all cafes
+func (h *AdminHandler) GetAllOrders(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.Order{})
+	if cafeID := c.Query("cafe_id"); cafeID != "" {
+		query = query.Where("cafe_id = ?", cafeID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var orders []models.Order
+	err := query.Preload("User").Preload("Cafe").Order("created_at DESC").Offset(offset).Limit(limit).Find(&orders).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to get orders",
+		})
+	}
+
+	responses := make([]fiber.Map, 0, len(orders))
+	for _, order := range orders {
+		responses = append(responses, fiber.Map{
+			"id":           order.ID,
+			"order_number": order.OrderNumber,
+			"cafe_id":      order.CafeID,
+			"cafe":         order.Cafe.Name,
+			"customer":     order.User.Name,
+			"status":       order.Status,
+			"total":        order.TotalAmount,
+			"created_at":   order.CreatedAt,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"orders": responses,
+			"pagination": fiber.Map{
+				"page":  page,
+				"limit": limit,
+				"total": total,
+				"pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		},
+	})
+}
+
+// moderate applies a moderation decision to a cafe and records it in the
+// cafe's moderation log
+func (h *AdminHandler) moderate(c *fiber.Ctx, action string, decide func(*models.Cafe, ModerationRequest) (map[string]interface{}, *fiber.Error)) error {
+	var req ModerationRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invalid request body",
+			})
+		}
+	}
+
+	cafe, ferr := h.findCafe(c.Params("id"))
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	updates, ferr := decide(cafe, req)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	previousStatus := cafe.Status
+	newStatus := previousStatus
+	if status, ok := updates["status"].(string); ok {
+		newStatus = status
+	}
+
+	entry := models.CafeModerationLog{
+		ID:             uuid.New().String(),
+		CafeID:         cafe.ID,
+		AdminID:        c.Locals("user_id").(string),
+		Action:         action,
+		Reason:         req.Reason,
+		PreviousStatus: previousStatus,
+		NewStatus:      newStatus,
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Cafe{}).Where("id = ?", cafe.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Create(&entry).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to update cafe",
+		})
+	}
+
+	cafe, ferr = h.findCafe(cafe.ID)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    adminCafeResponse(cafe, []models.CafeModerationLog{entry}),
+	})
+}
+
+func (h *AdminHandler) findCafe(id string) (*models.Cafe, *fiber.Error) {
+	var cafe models.Cafe
+	if err := h.db.Preload("Owner").First(&cafe, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Cafe not found")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to get cafe")
+	}
+	return &cafe, nil
+}
+
+func adminCafeResponse(cafe *models.Cafe, logs []models.CafeModerationLog) AdminCafeResponse {
+	return AdminCafeResponse{
+		CafeResponse:  cafe.ToResponse(),
+		Owner:         cafe.Owner.ToResponse(),
+		SuspendedAt:   cafe.SuspendedAt,
+		ModerationLog: logs,
+	}
+}
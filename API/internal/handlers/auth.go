@@ -34,7 +34,7 @@ type RegisterRequest struct {
 	Password string `json:"password" validate:"required,min=6"`
 	Phone    string `json:"phone"`
 	Address  string `json:"address"`
-	Role     string `json:"role"` // optional, defaults to "customer"
+	Role     string `json:"role"` // optional, "customer" (default) or "owner"
 }
 
 type AuthResponse struct {
@@ -59,6 +59,18 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 
+	// Set default role if not provided. Admins are only created from the
+	// server configuration.
+	role := req.Role
+	if role == "" {
+		role = models.RoleCustomer
+	}
+	if role != models.RoleCustomer && role != models.RoleOwner {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Role must be customer or owner",
+		})
+	}
+
 	// Check if user already exists
 	var existingUser models.User
 	err := h.db.Where("email = ?", req.Email).First(&existingUser).Error
@@ -82,12 +94,6 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 
-	// Set default role if not provided
-	role := req.Role
-	if role == "" {
-		role = "customer"
-	}
-
 	// Create user
 	user := models.User{
 		ID:       uuid.New().String(),
@@ -107,7 +113,7 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	}
 
 	// Generate JWT token
-	token, expiresAt, err := h.generateJWT(user.ID, user.Role)
+	token, expiresAt, err := generateJWT(h.db, h.cfg, &user)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate token",
@@ -159,7 +165,7 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	}
 
 	// Generate JWT token
-	token, expiresAt, err := h.generateJWT(user.ID, user.Role)
+	token, expiresAt, err := generateJWT(h.db, h.cfg, &user)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate token",
@@ -181,8 +187,8 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
 
-	// Generate new JWT token
-	token, expiresAt, err := h.generateJWT(user.ID, user.Role)
+	// Generate new JWT token, picking up staff membership changes
+	token, expiresAt, err := generateJWT(h.db, h.cfg, user)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate token",
@@ -201,9 +207,11 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 	})
 }
 
-func (h *AuthHandler) generateJWT(userID, role string) (string, int64, error) {
+// generateJWT issues a token for the user. Active staff members also get the
+// cafe they work for and their staff role.
+func generateJWT(db *gorm.DB, cfg *config.Config, user *models.User) (string, int64, error) {
 	// Parse expiration duration
-	duration, err := time.ParseDuration(h.cfg.JWTExpiresIn)
+	duration, err := time.ParseDuration(cfg.JWTExpiresIn)
 	if err != nil {
 		duration = 24 * time.Hour // fallback to 24 hours
 	}
@@ -212,15 +220,24 @@ func (h *AuthHandler) generateJWT(userID, role string) (string, int64, error) {
 
 	// Create JWT claims
 	claims := jwt.MapClaims{
-		"user_id": userID,
-		"role":    role,
+		"user_id": user.ID,
+		"role":    user.Role,
 		"exp":     expiresAt,
 		"iat":     time.Now().Unix(),
 	}
 
+	var staff models.CafeStaff
+	err = db.Where("user_id = ? AND is_active = ?", user.ID, true).Order("joined_at DESC").First(&staff).Error
+	if err == nil {
+		claims["cafe_id"] = staff.CafeID
+		claims["staff_role"] = staff.Role
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", 0, err
+	}
+
 	// Create token
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(h.cfg.JWTSecret))
+	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
 	if err != nil {
 		return "", 0, err
 	}
@@ -1,16 +1,26 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"siipcoffe-api/internal/models"
+	"siipcoffe-api/pkg/businesshours"
+	"siipcoffe-api/pkg/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// maxPaymentExpiryMinutes caps the payment expiry a cafe can configure (7 days)
+const maxPaymentExpiryMinutes = 7 * 24 * 60
+
 type CafeHandler struct {
 	db *gorm.DB
 }
@@ -41,14 +51,16 @@ func (h *CafeHandler) CreateCafe(c *fiber.Ctx) error {
 		Website              string  `json:"website"`
 		CoordinateLat        float64 `json:"coordinate_lat"`
 		CoordinateLng        float64 `json:"coordinate_lng"`
-		BusinessHours        string  `json:"business_hours"`
+		BusinessHours        businesshours.Schedule `json:"business_hours"`
+		Timezone             string  `json:"timezone"`
 		TaxPercentage        float64 `json:"tax_percentage"`
 		ServiceChargePercentage float64 `json:"service_charge_percentage"`
 		DeliveryFee          float64 `json:"delivery_fee"`
 		MinOrderAmount       float64 `json:"min_order_amount"`
 		MaxDeliveryDistance  float64 `json:"max_delivery_distance"`
-		Features             string  `json:"features"`
-		SocialMedia          string  `json:"social_media"`
+		Features             models.CafeFeatures `json:"features"`
+		SocialMedia          models.SocialMedia `json:"social_media"`
+		Ordering             *models.OrderingSettings `json:"ordering"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -58,16 +70,35 @@ func (h *CafeHandler) CreateCafe(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if user already has a cafe
-	var existingCafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&existingCafe).Error
-	if err == nil {
+	if err := req.BusinessHours.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid business hours",
+			"message": err.Error(),
+		})
+	}
+
+	if req.Timezone == "" {
+		req.Timezone = businesshours.DefaultTimezone
+	} else if !businesshours.ValidTimezone(req.Timezone) {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
-			"error":   "You already have a cafe",
+			"error":   "Invalid timezone",
 		})
 	}
 
+	if ferr := validateCafeProfile(&req.Features, &req.SocialMedia, req.Ordering); ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid cafe details",
+			"message": ferr.Message,
+		})
+	}
+
+	// Owners with an organization open further cafes as its branches
+	var organization models.Organization
+	hasOrganization := h.db.Where("owner_id = ?", user.ID).First(&organization).Error == nil
+
 	cafe := models.Cafe{
 		ID:                     uuid.New().String(),
 		OwnerID:                user.ID,
@@ -83,6 +114,7 @@ func (h *CafeHandler) CreateCafe(c *fiber.Ctx) error {
 		CoordinateLat:          req.CoordinateLat,
 		CoordinateLng:          req.CoordinateLng,
 		BusinessHours:          req.BusinessHours,
+		Timezone:               req.Timezone,
 		TaxPercentage:          req.TaxPercentage,
 		ServiceChargePercentage: req.ServiceChargePercentage,
 		DeliveryFee:            req.DeliveryFee,
@@ -90,11 +122,24 @@ func (h *CafeHandler) CreateCafe(c *fiber.Ctx) error {
 		MaxDeliveryDistance:    req.MaxDeliveryDistance,
 		Features:               req.Features,
 		SocialMedia:            req.SocialMedia,
-		IsOpen:                 true,
-		Status:                 "active",
+		Status:                 models.CafeStatusActive,
+		Settings:               models.CafeSettings{Version: models.CafeSettingsVersion, Ordering: req.Ordering},
 	}
 
-	if err := h.db.Create(&cafe).Error; err != nil {
+	if hasOrganization {
+		cafe.OrganizationID = &organization.ID
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&cafe).Error; err != nil {
+			return err
+		}
+		if hasOrganization {
+			return syncCatalogToBranch(tx, organization.ID, cafe.ID)
+		}
+		return nil
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   "Failed to create cafe",
@@ -109,26 +154,11 @@ func (h *CafeHandler) CreateCafe(c *fiber.Ctx) error {
 
 // GetMyCafe gets the current user's cafe (owner only)
 func (h *CafeHandler) GetMyCafe(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"success": false,
-			"error":   "Only owners can access cafe information",
-		})
-	}
-
-	var cafe models.Cafe
-	err := h.db.Preload("Owner").Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"success": false,
-				"error":   "Cafe not found",
-			})
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Failed to get cafe",
+			"error":   ferr.Message,
 		})
 	}
 
@@ -162,15 +192,20 @@ func (h *CafeHandler) UpdateCafe(c *fiber.Ctx) error {
 		Website              string  `json:"website"`
 		CoordinateLat        float64 `json:"coordinate_lat"`
 		CoordinateLng        float64 `json:"coordinate_lng"`
-		BusinessHours        string  `json:"business_hours"`
-		IsOpen               *bool   `json:"is_open"`
+		BusinessHours        *businesshours.Schedule `json:"business_hours"`
+		Timezone             string  `json:"timezone"`
+		IsOpen               *bool   `json:"is_open"` // sets a manual status until the business hours next change
 		TaxPercentage        float64 `json:"tax_percentage"`
 		ServiceChargePercentage float64 `json:"service_charge_percentage"`
 		DeliveryFee          float64 `json:"delivery_fee"`
 		MinOrderAmount       float64 `json:"min_order_amount"`
 		MaxDeliveryDistance  float64 `json:"max_delivery_distance"`
-		Features             string  `json:"features"`
-		SocialMedia          string  `json:"social_media"`
+		Features             *models.CafeFeatures `json:"features"`
+		SocialMedia          *models.SocialMedia `json:"social_media"`
+		Ordering             *models.OrderingSettings `json:"ordering"` // replaces the accepted order types and payment methods
+		PaymentExpiryMinutes map[string]int `json:"payment_expiry_minutes"` // per payment method, 0 removes the override
+		ScheduledOrders      *models.ScheduledOrderSettings `json:"scheduled_orders"`
+		Delivery             *models.DeliverySettings `json:"delivery"` // replaces the delivery zones and fee tiers
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -180,18 +215,73 @@ func (h *CafeHandler) UpdateCafe(c *fiber.Ctx) error {
 		})
 	}
 
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	for method, minutes := range req.PaymentExpiryMinutes {
+		if !models.IsPaymentMethod(method) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"success": false,
-				"error":   "Cafe not found",
+				"error":   "Invalid payment expiry",
+				"message": fmt.Sprintf("payment_expiry_minutes.%s is not a payment method, use one of %s", method, strings.Join(models.PaymentMethods, ", ")),
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		if minutes < 0 || minutes > maxPaymentExpiryMinutes {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invalid payment expiry",
+				"message": fmt.Sprintf("payment_expiry_minutes.%s must be between 0 and %d", method, maxPaymentExpiryMinutes),
+			})
+		}
+	}
+
+	if req.BusinessHours != nil {
+		if err := req.BusinessHours.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invalid business hours",
+				"message": err.Error(),
+			})
+		}
+	}
+
+	if req.Timezone != "" && !businesshours.ValidTimezone(req.Timezone) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
-			"error":   "Failed to get cafe",
+			"error":   "Invalid timezone",
+		})
+	}
+
+	if ferr := validateCafeProfile(req.Features, req.SocialMedia, req.Ordering); ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid cafe details",
+			"message": ferr.Message,
+		})
+	}
+
+	if req.Delivery != nil {
+		if msg := validateDeliverySettings(req.Delivery); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invalid delivery settings",
+				"message": msg,
+			})
+		}
+	}
+
+	if req.ScheduledOrders != nil {
+		if msg := validateScheduledOrderSettings(req.ScheduledOrders); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invalid scheduled order settings",
+				"message": msg,
+			})
+		}
+	}
+
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
 		})
 	}
 
@@ -236,11 +326,19 @@ func (h *CafeHandler) UpdateCafe(c *fiber.Ctx) error {
 	if req.CoordinateLng != 0 {
 		updates["coordinate_lng"] = req.CoordinateLng
 	}
-	if req.BusinessHours != "" {
-		updates["business_hours"] = req.BusinessHours
+	if req.BusinessHours != nil {
+		cafe.BusinessHours = *req.BusinessHours
+		updates["business_hours"] = cafe.BusinessHours
+	}
+	if req.Timezone != "" {
+		cafe.Timezone = req.Timezone
+		updates["timezone"] = req.Timezone
 	}
 	if req.IsOpen != nil {
-		updates["is_open"] = *req.IsOpen
+		// Evaluated against the new hours and timezone when both are sent
+		cafe.SetOpenOverride(*req.IsOpen, nil, time.Now())
+		updates["open_override"] = cafe.OpenOverride
+		updates["open_override_until"] = cafe.OpenOverrideUntil
 	}
 	if req.TaxPercentage > 0 {
 		updates["tax_percentage"] = req.TaxPercentage
@@ -257,14 +355,43 @@ func (h *CafeHandler) UpdateCafe(c *fiber.Ctx) error {
 	if req.MaxDeliveryDistance > 0 {
 		updates["max_delivery_distance"] = req.MaxDeliveryDistance
 	}
-	if req.Features != "" {
-		updates["features"] = req.Features
+	if req.Features != nil {
+		updates["features"] = *req.Features
 	}
-	if req.SocialMedia != "" {
-		updates["social_media"] = req.SocialMedia
+	if req.SocialMedia != nil {
+		updates["social_media"] = *req.SocialMedia
+	}
+	if req.PaymentExpiryMinutes != nil || req.ScheduledOrders != nil || req.Delivery != nil || req.Ordering != nil {
+		settings := cafe.GetSettings()
+		if req.Ordering != nil {
+			settings.Ordering = req.Ordering
+		}
+		if req.ScheduledOrders != nil {
+			settings.ScheduledOrders = req.ScheduledOrders
+		}
+		if req.Delivery != nil {
+			settings.Delivery = req.Delivery
+		}
+		if settings.PaymentExpiryMinutes == nil {
+			settings.PaymentExpiryMinutes = make(map[string]int)
+		}
+		for method, minutes := range req.PaymentExpiryMinutes {
+			if minutes == 0 {
+				delete(settings.PaymentExpiryMinutes, method)
+			} else {
+				settings.PaymentExpiryMinutes[method] = minutes
+			}
+		}
+		if err := cafe.SetSettings(settings); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"error":   "Failed to update cafe settings",
+			})
+		}
+		updates["settings"] = cafe.Settings
 	}
 
-	if err := h.db.Model(&cafe).Updates(updates).Error; err != nil {
+	if err := h.db.Model(cafe).Updates(updates).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   "Failed to update cafe",
@@ -272,7 +399,7 @@ func (h *CafeHandler) UpdateCafe(c *fiber.Ctx) error {
 	}
 
 	// Refresh data
-	h.db.Preload("Owner").First(&cafe, cafe.ID)
+	h.db.Preload("Owner").First(cafe, "id = ?", cafe.ID)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -280,7 +407,101 @@ func (h *CafeHandler) UpdateCafe(c *fiber.Ctx) error {
 	})
 }
 
-// GetAllCafes gets all active cafes (public)
+// validateCafeProfile checks the features, social media accounts and ordering
+// settings sent for a cafe. Nil values were not sent and are skipped.
+func validateCafeProfile(features *models.CafeFeatures, socialMedia *models.SocialMedia, ordering *models.OrderingSettings) *fiber.Error {
+	if features != nil {
+		if err := features.Validate(); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+	if socialMedia != nil {
+		if err := socialMedia.Validate(); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+	if ordering != nil {
+		if err := ordering.Validate(); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+	return nil
+}
+
+// validateScheduledOrderSettings returns a message describing the first
+// invalid setting, or an empty string
+func validateScheduledOrderSettings(settings *models.ScheduledOrderSettings) string {
+	switch {
+	case settings.LeadMinutes < 0 || settings.LeadMinutes > 24*60:
+		return "lead_minutes must be between 0 and 1440"
+	case settings.SlotMinutes < 5 || settings.SlotMinutes > 240:
+		return "slot_minutes must be between 5 and 240"
+	case settings.SlotCapacity < 0:
+		return "slot_capacity cannot be negative"
+	case settings.MaxDaysAhead < 1 || settings.MaxDaysAhead > 60:
+		return "max_days_ahead must be between 1 and 60"
+	}
+	return ""
+}
+
+// validateDeliverySettings returns a message describing the first invalid
+// delivery zone or fee tier, or an empty string when the settings are valid
+func validateDeliverySettings(settings *models.DeliverySettings) string {
+	for i, zone := range settings.Zones {
+		if zone.Name == "" {
+			return fmt.Sprintf("zones[%d].name is required", i)
+		}
+		if len(zone.Polygon) > 0 {
+			if zone.RadiusKm > 0 {
+				return fmt.Sprintf("zone %s must have either radius_km or polygon, not both", zone.Name)
+			}
+			if len(zone.Polygon) < 3 {
+				return fmt.Sprintf("zone %s polygon needs at least 3 points", zone.Name)
+			}
+			for _, point := range zone.Polygon {
+				if point.Lat < -90 || point.Lat > 90 || point.Lng < -180 || point.Lng > 180 {
+					return fmt.Sprintf("zone %s has a point out of range", zone.Name)
+				}
+			}
+			continue
+		}
+		if zone.RadiusKm <= 0 || zone.RadiusKm > maxNearbyRadiusKm {
+			return fmt.Sprintf("zone %s radius_km must be greater than 0 and at most %.0f", zone.Name, maxNearbyRadiusKm)
+		}
+	}
+
+	seen := make(map[float64]bool, len(settings.FeeTiers))
+	for _, tier := range settings.FeeTiers {
+		if tier.UpToKm <= 0 {
+			return "fee_tiers up_to_km must be greater than 0"
+		}
+		if tier.Fee < 0 {
+			return "fee_tiers fee cannot be negative"
+		}
+		if seen[tier.UpToKm] {
+			return fmt.Sprintf("fee_tiers has more than one tier up to %.1f km", tier.UpToKm)
+		}
+		seen[tier.UpToKm] = true
+	}
+
+	return ""
+}
+
+// Nearby search radius limits in km
+const (
+	defaultNearbyRadiusKm = 5.0
+	maxNearbyRadiusKm     = 50.0
+)
+
+// nearbyQuery is a location filter on the cafe listing
+type nearbyQuery struct {
+	Lat      float64
+	Lng      float64
+	RadiusKm float64
+}
+
+// GetAllCafes gets all active cafes (public). With lat and lng the cafes
+// within radius km are returned nearest first.
 func (h *CafeHandler) GetAllCafes(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "10"))
@@ -288,9 +509,23 @@ func (h *CafeHandler) GetAllCafes(c *fiber.Ctx) error {
 	city := c.Query("city", "")
 	isOpen := c.Query("is_open", "")
 
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
 	offset := (page - 1) * limit
 
-	query := h.db.Model(&models.Cafe{}).Where("status = ?", "active")
+	nearby, ferr := parseNearbyQuery(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	query := h.db.Model(&models.Cafe{}).Where("status = ?", models.CafeStatusActive)
 
 	if search != "" {
 		query = query.Where("name LIKE ? OR description LIKE ?", "%"+search+"%", "%"+search+"%")
@@ -298,19 +533,50 @@ func (h *CafeHandler) GetAllCafes(c *fiber.Ctx) error {
 	if city != "" {
 		query = query.Where("city = ?", city)
 	}
-	if isOpen != "" {
-		query = query.Where("is_open = ?", isOpen == "true")
+	if features := c.Query("features", ""); features != "" {
+		// Cafes must have every requested feature
+		for _, feature := range strings.Split(features, ",") {
+			feature = strings.TrimSpace(feature)
+			if !models.IsValidCafeFeature(feature) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"success":        false,
+					"error":          fmt.Sprintf("Unknown feature %q", feature),
+					"valid_features": models.CafeFeatureList,
+				})
+			}
+			query = query.Where("features LIKE ?", `%"`+feature+`"%`)
+		}
+	}
+	if nearby != nil {
+		// Cheap prefilter on the location index, the exact distance is
+		// checked after loading
+		minLat, maxLat, minLng, maxLng := utils.BoundingBox(nearby.Lat, nearby.Lng, nearby.RadiusKm)
+		query = query.Where("coordinate_lat BETWEEN ? AND ? AND coordinate_lng BETWEEN ? AND ?", minLat, maxLat, minLng, maxLng)
 	}
 
 	var total int64
-	query.Count(&total)
-
 	var cafes []models.Cafe
-	err := query.Preload("Owner").
-		Offset(offset).
-		Limit(limit).
-		Order("rating_average DESC, created_at DESC").
-		Find(&cafes).Error
+	var distances map[string]float64
+	var err error
+
+	query = query.Preload("Owner").Order("rating_average DESC, created_at DESC")
+	if isOpen != "" || nearby != nil {
+		// Open status depends on each cafe's hours and timezone and distance
+		// is computed per cafe, so these are filtered after loading and
+		// paginated in memory
+		err = query.Find(&cafes).Error
+		if isOpen != "" {
+			cafes = filterCafesByOpen(cafes, isOpen == "true", time.Now())
+		}
+		if nearby != nil {
+			cafes, distances = filterCafesByDistance(cafes, *nearby)
+		}
+		total = int64(len(cafes))
+		cafes = cafes[min(offset, len(cafes)):min(offset+limit, len(cafes))]
+	} else {
+		query.Count(&total)
+		err = query.Offset(offset).Limit(limit).Find(&cafes).Error
+	}
 
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -321,7 +587,11 @@ func (h *CafeHandler) GetAllCafes(c *fiber.Ctx) error {
 
 	var cafeResponses []models.CafeResponse
 	for _, cafe := range cafes {
-		cafeResponses = append(cafeResponses, cafe.ToResponse())
+		response := cafe.ToResponse()
+		if distance, ok := distances[cafe.ID]; ok {
+			response.DistanceKm = &distance
+		}
+		cafeResponses = append(cafeResponses, response)
 	}
 
 	return c.JSON(fiber.Map{
@@ -338,6 +608,74 @@ func (h *CafeHandler) GetAllCafes(c *fiber.Ctx) error {
 	})
 }
 
+// parseNearbyQuery reads the lat, lng and radius query parameters. It returns
+// nil when no location was given.
+func parseNearbyQuery(c *fiber.Ctx) (*nearbyQuery, *fiber.Error) {
+	latParam, lngParam := c.Query("lat"), c.Query("lng")
+	if latParam == "" && lngParam == "" {
+		return nil, nil
+	}
+	if latParam == "" || lngParam == "" {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "lat and lng must be given together")
+	}
+
+	lat, err := strconv.ParseFloat(latParam, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "lat must be a number between -90 and 90")
+	}
+	lng, err := strconv.ParseFloat(lngParam, 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "lng must be a number between -180 and 180")
+	}
+
+	radius := defaultNearbyRadiusKm
+	if param := c.Query("radius"); param != "" {
+		radius, err = strconv.ParseFloat(param, 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadiusKm {
+			return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("radius must be greater than 0 and at most %.0f km", maxNearbyRadiusKm))
+		}
+	}
+
+	return &nearbyQuery{Lat: lat, Lng: lng, RadiusKm: radius}, nil
+}
+
+// filterCafesByDistance keeps the cafes within the search radius, nearest
+// first, and returns their distances in km keyed by cafe ID
+func filterCafesByDistance(cafes []models.Cafe, nearby nearbyQuery) ([]models.Cafe, map[string]float64) {
+	filtered := make([]models.Cafe, 0, len(cafes))
+	distances := make(map[string]float64, len(cafes))
+	for _, cafe := range cafes {
+		distance := utils.CalculateDistance(nearby.Lat, nearby.Lng, cafe.CoordinateLat, cafe.CoordinateLng)
+		if distance <= nearby.RadiusKm {
+			filtered = append(filtered, cafe)
+			distances[cafe.ID] = math.Round(distance*100) / 100
+		}
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return distances[filtered[i].ID] < distances[filtered[j].ID]
+	})
+	return filtered, distances
+}
+
+func filterCafesByOpen(cafes []models.Cafe, open bool, now time.Time) []models.Cafe {
+	filtered := make([]models.Cafe, 0, len(cafes))
+	for _, cafe := range cafes {
+		if cafe.IsOpenAt(now) == open {
+			filtered = append(filtered, cafe)
+		}
+	}
+	return filtered
+}
+
+// GetCafeFeatures lists the features cafes can have and be filtered by (public)
+func (h *CafeHandler) GetCafeFeatures(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    models.CafeFeatureList,
+	})
+}
+
 // GetCafeByID gets a specific cafe by ID (public)
 func (h *CafeHandler) GetCafeByID(c *fiber.Ctx) error {
 	cafeID := c.Params("id")
@@ -347,7 +685,7 @@ func (h *CafeHandler) GetCafeByID(c *fiber.Ctx) error {
 		Preload("MenuItems", "is_available = ?", true).
 		Preload("Reviews").
 		Preload("Reviews.User").
-		Where("id = ? AND status = ?", cafeID, "active").
+		Where("id = ? AND status = ?", cafeID, models.CafeStatusActive).
 		First(&cafe).Error
 
 	if err != nil {
@@ -369,27 +707,44 @@ func (h *CafeHandler) GetCafeByID(c *fiber.Ctx) error {
 	})
 }
 
-// ToggleCafeStatus toggles cafe open/closed status (owner only)
+// ToggleCafeStatus flips the cafe's current open/closed status (owner only).
+// The manual status lasts until the optional "until" time, or otherwise until
+// the business hours next change, after which the schedule applies again.
 func (h *CafeHandler) ToggleCafeStatus(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Only owners can toggle cafe status",
+			"error":   ferr.Message,
 		})
 	}
 
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	var req struct {
+		Until *time.Time `json:"until"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invalid request body",
+			})
+		}
+	}
+
+	now := time.Now()
+	if req.Until != nil && !req.Until.After(now) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
-			"error":   "Cafe not found",
+			"error":   "until must be in the future",
 		})
 	}
 
-	cafe.IsOpen = !cafe.IsOpen
-	if err := h.db.Save(&cafe).Error; err != nil {
+	cafe.SetOpenOverride(!cafe.IsOpenAt(now), req.Until, now)
+	err := h.db.Model(cafe).Updates(map[string]interface{}{
+		"open_override":       cafe.OpenOverride,
+		"open_override_until": cafe.OpenOverrideUntil,
+	}).Error
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   "Failed to update cafe status",
@@ -399,27 +754,21 @@ func (h *CafeHandler) ToggleCafeStatus(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data": fiber.Map{
-			"is_open": cafe.IsOpen,
+			"is_open":             cafe.IsOpenAt(now),
+			"open_override":       cafe.OpenOverride,
+			"open_override_until": cafe.OpenOverrideUntil,
+			"next_status_change":  cafe.NextStatusChange(now),
 		},
 	})
 }
 
 // GetCafeAnalytics gets analytics data for the cafe (owner only)
 func (h *CafeHandler) GetCafeAnalytics(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"success": false,
-			"error":   "Only owners can access analytics",
-		})
-	}
-
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Cafe not found",
+			"error":   ferr.Message,
 		})
 	}
 
@@ -443,6 +792,16 @@ func (h *CafeHandler) GetCafeAnalytics(c *fiber.Ctx) error {
 	baseQuery.Count(&totalOrders)
 	baseQuery.Select("COALESCE(SUM(total_amount), 0)").Scan(&totalRevenue)
 
+	// Refunds issued in the same period
+	var totalRefunds float64
+	refundQuery := h.db.Model(&models.Refund{}).Where("cafe_id = ? AND status = ?", cafe.ID, models.RefundStatusCompleted)
+	if startDate != "" && endDate != "" {
+		refundQuery = refundQuery.Where("created_at BETWEEN ? AND ?", startDate, endDate)
+	} else {
+		refundQuery = refundQuery.Where("created_at >= ?", time.Now().AddDate(0, 0, -30))
+	}
+	refundQuery.Select("COALESCE(SUM(amount), 0)").Scan(&totalRefunds)
+
 	// Orders by status
 	var ordersByStatus []struct {
 		Status string `json:"status"`
@@ -495,6 +854,8 @@ func (h *CafeHandler) GetCafeAnalytics(c *fiber.Ctx) error {
 		"data": fiber.Map{
 			"total_orders":     totalOrders,
 			"total_revenue":    totalRevenue,
+			"total_refunds":    totalRefunds,
+			"net_revenue":      totalRevenue - totalRefunds,
 			"orders_by_status": ordersByStatus,
 			"popular_items":    popularItems,
 			"daily_stats":      dailyStats,
@@ -502,7 +863,15 @@ func (h *CafeHandler) GetCafeAnalytics(c *fiber.Ctx) error {
 	})
 }
 
-// AddCafeReview adds a review for a cafe (customer only)
+// ReviewItemRequest rates one menu item of the reviewed order
+type ReviewItemRequest struct {
+	MenuID  string `json:"menu_id"`
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment"`
+}
+
+// AddCafeReview reviews a completed order at the cafe (customer only). Each
+// order can be reviewed once and the review is marked as a verified purchase.
 func (h *CafeHandler) AddCafeReview(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
 	if user.Role != "customer" {
@@ -515,9 +884,11 @@ func (h *CafeHandler) AddCafeReview(c *fiber.Ctx) error {
 	cafeID := c.Params("id")
 
 	var req struct {
-		Rating  int    `json:"rating" validate:"required,min=1,max=5"`
-		Comment string `json:"comment"`
-		Images  string `json:"images"`
+		OrderID string              `json:"order_id"`
+		Rating  int                 `json:"rating" validate:"required,min=1,max=5"`
+		Comment string              `json:"comment"`
+		Images  string              `json:"images"`
+		Items   []ReviewItemRequest `json:"items"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -527,9 +898,22 @@ func (h *CafeHandler) AddCafeReview(c *fiber.Ctx) error {
 		})
 	}
 
+	if req.OrderID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "order_id is required, reviews are written for a completed order",
+		})
+	}
+	if req.Rating < 1 || req.Rating > 5 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Rating must be between 1 and 5",
+		})
+	}
+
 	// Check if cafe exists
 	var cafe models.Cafe
-	err := h.db.Where("id = ? AND status = ?", cafeID, "active").First(&cafe).Error
+	err := h.db.Where("id = ? AND status = ?", cafeID, models.CafeStatusActive).First(&cafe).Error
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
@@ -537,37 +921,107 @@ func (h *CafeHandler) AddCafeReview(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if user has already reviewed this cafe
-	var existingReview models.CafeReview
-	err = h.db.Where("cafe_id = ? AND user_id = ?", cafeID, user.ID).First(&existingReview).Error
-	if err == nil {
+	var order models.Order
+	err = h.db.Preload("OrderItems").Where("id = ? AND user_id = ? AND cafe_id = ?", req.OrderID, user.ID, cafeID).First(&order).Error
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Order not found",
+		})
+	}
+	if models.OrderStatus(order.Status) != models.OrderStatusCompleted {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
-			"error":   "You have already reviewed this cafe",
+			"error":   "Only completed orders can be reviewed",
+		})
+	}
+
+	// Check if the order has already been reviewed
+	var existing int64
+	h.db.Model(&models.CafeReview{}).Where("order_id = ?", order.ID).Count(&existing)
+	if existing > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   "You have already reviewed this order",
 		})
 	}
 
 	review := models.CafeReview{
-		ID:     uuid.New().String(),
-		CafeID: cafeID,
-		UserID: user.ID,
-		Rating: req.Rating,
-		Comment: req.Comment,
-		Images: req.Images,
+		ID:         uuid.New().String(),
+		CafeID:     cafeID,
+		UserID:     user.ID,
+		OrderID:    &order.ID,
+		Rating:     req.Rating,
+		Comment:    req.Comment,
+		Images:     req.Images,
+		IsVerified: true,
+		Status:     models.ReviewStatusPublished,
 	}
 
-	if err := h.db.Create(&review).Error; err != nil {
+	ordered := make(map[string]bool, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		ordered[item.MenuID] = true
+	}
+	rated := make(map[string]bool, len(req.Items))
+	for _, item := range req.Items {
+		if !ordered[item.MenuID] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   fmt.Sprintf("Menu %s is not part of this order", item.MenuID),
+			})
+		}
+		if rated[item.MenuID] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   fmt.Sprintf("Menu %s is rated more than once", item.MenuID),
+			})
+		}
+		if item.Rating < 1 || item.Rating > 5 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Item ratings must be between 1 and 5",
+			})
+		}
+		rated[item.MenuID] = true
+
+		review.ItemRatings = append(review.ItemRatings, models.ReviewItemRating{
+			ID:       uuid.New().String(),
+			ReviewID: review.ID,
+			MenuID:   item.MenuID,
+			Rating:   item.Rating,
+			Comment:  item.Comment,
+		})
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&review).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+			"rating": req.Rating,
+			"review": req.Comment,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return updateCafeRating(tx, cafeID, req.Rating, 1)
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// Another request reviewed the order after the check above
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   "You have already reviewed this order",
+		})
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   "Failed to add review",
 		})
 	}
 
-	// Update cafe rating
-	h.updateCafeRating(cafeID)
-
 	// Preload data for response
-	h.db.Preload("User").First(&review, review.ID)
+	h.db.Preload("User").Preload("ItemRatings.Menu").First(&review, "id = ?", review.ID)
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"success": true,
@@ -584,7 +1038,7 @@ func (h *CafeHandler) GetCafeReviews(c *fiber.Ctx) error {
 
 	offset := (page - 1) * limit
 
-	query := h.db.Model(&models.CafeReview{}).Where("cafe_id = ?", cafeID)
+	query := h.db.Model(&models.CafeReview{}).Where("cafe_id = ? AND status = ?", cafeID, models.ReviewStatusPublished)
 
 	if rating != "" {
 		query = query.Where("rating = ?", rating)
@@ -594,7 +1048,7 @@ func (h *CafeHandler) GetCafeReviews(c *fiber.Ctx) error {
 	query.Count(&total)
 
 	var reviews []models.CafeReview
-	err := query.Preload("User").
+	err := query.Preload("User").Preload("ItemRatings.Menu").
 		Offset(offset).
 		Limit(limit).
 		Order("created_at DESC").
@@ -612,10 +1066,19 @@ func (h *CafeHandler) GetCafeReviews(c *fiber.Ctx) error {
 		reviewResponses = append(reviewResponses, review.ToResponse())
 	}
 
+	var cafe models.Cafe
+	h.db.Select("id", "rating_average", "rating_count", "rating1_count", "rating2_count", "rating3_count", "rating4_count", "rating5_count").
+		First(&cafe, "id = ?", cafeID)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data": fiber.Map{
 			"reviews": reviewResponses,
+			"rating": fiber.Map{
+				"average":   cafe.RatingAverage,
+				"count":     cafe.RatingCount,
+				"histogram": cafe.RatingHistogram(),
+			},
 			"pagination": fiber.Map{
 				"page":  page,
 				"limit": limit,
@@ -626,22 +1089,16 @@ func (h *CafeHandler) GetCafeReviews(c *fiber.Ctx) error {
 	})
 }
 
-// Helper function to update cafe rating
-func (h *CafeHandler) updateCafeRating(cafeID string) {
-	var avgRating struct {
-		Average float64 `json:"average"`
-		Count   int64   `json:"count"`
-	}
-
-	h.db.Model(&models.CafeReview{}).
-		Select("AVG(rating) as average, COUNT(*) as count").
-		Where("cafe_id = ?", cafeID).
-		Scan(&avgRating)
-
-	h.db.Model(&models.Cafe{}).
+// updateCafeRating adds (delta 1) or removes (delta -1) a published review
+// with the given stars from the cafe's average, count and star histogram
+// without rescanning its reviews
+func updateCafeRating(tx *gorm.DB, cafeID string, rating, delta int) error {
+	histogram := fmt.Sprintf("rating%d_count", rating)
+	return tx.Model(&models.Cafe{}).
 		Where("id = ?", cafeID).
 		Updates(map[string]interface{}{
-			"rating_average": avgRating.Average,
-			"rating_count":   avgRating.Count,
-		})
-}
\ No newline at end of file
+			"rating_average": gorm.Expr("CASE WHEN rating_count + ? > 0 THEN (rating_average * rating_count + ?) / (rating_count + ?) ELSE 0 END", delta, rating*delta, delta),
+			"rating_count":   gorm.Expr("rating_count + ?", delta),
+			histogram:        gorm.Expr(histogram+" + ?", delta),
+		}).Error
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"siipcoffe-api/internal/config"
 	"siipcoffe-api/internal/models"
@@ -301,21 +302,62 @@ func (h *ChatHandler) getConversationHistory(userID, sessionID string) ([]map[st
 
 func (h *ChatHandler) getAvailableMenus() ([]map[string]interface{}, error) {
 	var menus []models.Menu
-	err := h.db.Where("is_available = ?", true).Find(&menus).Error
+	err := h.db.Scopes(withoutSuspendedCafes, withoutInactiveCategories).
+		Where("is_available = ?", true).
+		Preload("Cafe").
+		Preload("OptionGroups", func(db *gorm.DB) *gorm.DB {
+			return db.Order("sort_order ASC")
+		}).
+		Preload("OptionGroups.Options", func(db *gorm.DB) *gorm.DB {
+			return db.Where("is_available = ?", true).Order("sort_order ASC")
+		}).
+		Find(&menus).Error
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now()
 	var menuMaps []map[string]interface{}
 	for _, menu := range menus {
+		// Let the assistant know when a cafe is closed so it suggests a pre-order
+		cafeOpen := menu.Cafe.IsOpenAt(now)
+		opensAt := ""
+		if next := menu.Cafe.NextStatusChange(now); !cafeOpen && next != nil {
+			opensAt = next.In(menu.Cafe.Location()).Format("Mon 15:04")
+		}
+
+		var optionGroups []map[string]interface{}
+		for _, group := range menu.OptionGroups {
+			var options []map[string]interface{}
+			for _, option := range group.Options {
+				options = append(options, map[string]interface{}{
+					"id":          option.ID,
+					"name":        option.Name,
+					"price_delta": option.PriceDelta,
+				})
+			}
+			optionGroups = append(optionGroups, map[string]interface{}{
+				"name":       group.Name,
+				"required":   group.MinRequired() > 0,
+				"max_select": group.MaxSelect,
+				"options":    options,
+			})
+		}
+
 		menuMaps = append(menuMaps, map[string]interface{}{
-			"id":          menu.ID,
-			"name":        menu.Name,
-			"description": menu.Description,
-			"category":    menu.Category,
-			"price":       menu.Price,
-			"ingredients": menu.Ingredients,
-			"prep_time":   menu.PrepTime,
+			"id":              menu.ID,
+			"name":            menu.Name,
+			"description":     menu.Description,
+			"category":        menu.Category,
+			"price":           menu.Price,
+			"ingredients":     menu.Ingredients,
+			"prep_time":       menu.PrepTime,
+			"sold_out":        menu.SoldOut,
+			"sold_out_reason": menu.SoldOutReason,
+			"option_groups":   optionGroups,
+			"cafe_name":       menu.Cafe.Name,
+			"cafe_open":       cafeOpen,
+			"cafe_opens_at":   opensAt,
 		})
 	}
 
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"siipcoffe-api/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestAssistantMenusSkipHiddenMenus(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "chat.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Cafe{}, &models.MenuCategory{}, &models.Menu{}, &models.MenuOptionGroup{}, &models.MenuOption{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	hidden := "cat-hidden"
+	records := []interface{}{
+		&models.Cafe{ID: "cafe-open", Name: "Open", OwnerID: "owner", Status: models.CafeStatusActive},
+		&models.Cafe{ID: "cafe-suspended", Name: "Suspended", OwnerID: "owner", Status: models.CafeStatusSuspended},
+		&models.MenuCategory{ID: hidden, CafeID: "cafe-open", Slug: "seasonal", NameID: "Musiman"},
+		&models.Menu{ID: "latte", CafeID: "cafe-open", Name: "Latte", Category: "coffee", Price: 25000, IsAvailable: true},
+		&models.Menu{ID: "pumpkin", CafeID: "cafe-open", CategoryID: &hidden, Name: "Pumpkin Latte", Category: "seasonal", Price: 30000, IsAvailable: true},
+		&models.Menu{ID: "mocha", CafeID: "cafe-suspended", Name: "Mocha", Category: "coffee", Price: 28000, IsAvailable: true},
+	}
+	for _, record := range records {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	// is_active defaults to true on create
+	if err := db.Model(&models.MenuCategory{}).Where("id = ?", hidden).Update("is_active", false).Error; err != nil {
+		t.Fatalf("hide category: %v", err)
+	}
+
+	menus, err := NewChatHandler(db, nil, nil).getAvailableMenus()
+	if err != nil {
+		t.Fatalf("getAvailableMenus: %v", err)
+	}
+	if len(menus) != 1 || menus[0]["id"] != "latte" {
+		t.Errorf("got menus %v, want only latte", menus)
+	}
+}
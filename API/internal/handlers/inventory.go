@@ -19,23 +19,14 @@ func NewInventoryHandler(db *gorm.DB) *InventoryHandler {
 	return &InventoryHandler{db: db}
 }
 
-// GetInventoryItems gets all inventory items for a cafe (owner only)
+// GetInventoryItems gets all inventory items for a cafe (owner and staff)
 func (h *InventoryHandler) GetInventoryItems(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	// Get the cafe the caller works for
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Only owners can access inventory",
-		})
-	}
-
-	// Get user's cafe
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success": false,
-			"error":   "Cafe not found",
+			"error":   ferr.Message,
 		})
 	}
 
@@ -70,7 +61,7 @@ func (h *InventoryHandler) GetInventoryItems(c *fiber.Ctx) error {
 	query.Count(&total)
 
 	var inventoryItems []models.Inventory
-	err = query.Offset(offset).
+	err := query.Offset(offset).
 		Limit(limit).
 		Order("name ASC").
 		Find(&inventoryItems).Error
@@ -101,23 +92,14 @@ func (h *InventoryHandler) GetInventoryItems(c *fiber.Ctx) error {
 	})
 }
 
-// CreateInventoryItem creates a new inventory item (owner only)
+// CreateInventoryItem creates a new inventory item (owner and managers)
 func (h *InventoryHandler) CreateInventoryItem(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	// Get the cafe the caller works for
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Only owners can create inventory items",
-		})
-	}
-
-	// Get user's cafe
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success": false,
-			"error":   "Cafe not found",
+			"error":   ferr.Message,
 		})
 	}
 
@@ -181,23 +163,14 @@ func (h *InventoryHandler) CreateInventoryItem(c *fiber.Ctx) error {
 	})
 }
 
-// UpdateInventoryItem updates an inventory item (owner only)
+// UpdateInventoryItem updates an inventory item (owner and managers)
 func (h *InventoryHandler) UpdateInventoryItem(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"success": false,
-			"error":   "Only owners can update inventory items",
-		})
-	}
-
-	// Get user's cafe
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	// Get the cafe the caller works for
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Cafe not found",
+			"error":   ferr.Message,
 		})
 	}
 
@@ -225,7 +198,7 @@ func (h *InventoryHandler) UpdateInventoryItem(c *fiber.Ctx) error {
 	}
 
 	var inventory models.Inventory
-	err = h.db.Where("id = ? AND cafe_id = ?", itemID, cafe.ID).First(&inventory).Error
+	err := h.db.Where("id = ? AND cafe_id = ?", itemID, cafe.ID).First(&inventory).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -274,7 +247,14 @@ func (h *InventoryHandler) UpdateInventoryItem(c *fiber.Ctx) error {
 		updates["is_active"] = *req.IsActive
 	}
 
-	if err := h.db.Model(&inventory).Updates(updates).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&inventory).Updates(updates).Error; err != nil {
+			return err
+		}
+		// Sold out reasons name the item
+		return refreshSoldOut(tx, inventory.ID)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   "Failed to update inventory item",
@@ -289,23 +269,14 @@ func (h *InventoryHandler) UpdateInventoryItem(c *fiber.Ctx) error {
 	})
 }
 
-// AddStockMovement adds a stock movement (owner only)
+// AddStockMovement adds a stock movement (owner and staff)
 func (h *InventoryHandler) AddStockMovement(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"success": false,
-			"error":   "Only owners can add stock movements",
-		})
-	}
-
-	// Get user's cafe
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	// Get the cafe the caller works for
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Cafe not found",
+			"error":   ferr.Message,
 		})
 	}
 
@@ -337,7 +308,7 @@ func (h *InventoryHandler) AddStockMovement(c *fiber.Ctx) error {
 	}
 
 	var inventory models.Inventory
-	err = h.db.Where("id = ? AND cafe_id = ?", itemID, cafe.ID).First(&inventory).Error
+	err := h.db.Where("id = ? AND cafe_id = ?", itemID, cafe.ID).First(&inventory).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -364,7 +335,7 @@ func (h *InventoryHandler) AddStockMovement(c *fiber.Ctx) error {
 		Reason:      req.Reason,
 		ReferenceID: req.ReferenceID,
 		Notes:       req.Notes,
-		PerformedBy: user.ID,
+		PerformedBy: c.Locals("user_id").(string),
 	}
 
 	// Start transaction
@@ -430,6 +401,15 @@ func (h *InventoryHandler) AddStockMovement(c *fiber.Ctx) error {
 		tx.Model(&inventory).Update("last_restocked", &now)
 	}
 
+	// Menu items using this item may have run out or come back
+	if err := refreshSoldOut(tx, inventory.ID); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to update menu availability",
+		})
+	}
+
 	tx.Commit()
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
@@ -438,23 +418,14 @@ func (h *InventoryHandler) AddStockMovement(c *fiber.Ctx) error {
 	})
 }
 
-// GetStockMovements gets stock movements for an inventory item (owner only)
+// GetStockMovements gets stock movements for an inventory item (owner and staff)
 func (h *InventoryHandler) GetStockMovements(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"success": false,
-			"error":   "Only owners can access stock movements",
-		})
-	}
-
-	// Get user's cafe
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	// Get the cafe the caller works for
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Cafe not found",
+			"error":   ferr.Message,
 		})
 	}
 
@@ -465,6 +436,21 @@ func (h *InventoryHandler) GetStockMovements(c *fiber.Ctx) error {
 
 	offset := (page - 1) * limit
 
+	var inventory models.Inventory
+	err := h.db.Where("id = ? AND cafe_id = ?", itemID, cafe.ID).First(&inventory).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"error":   "Inventory item not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to get inventory item",
+		})
+	}
+
 	query := h.db.Model(&models.StockMovement{}).
 		Joins("JOIN inventories ON inventories.id = stock_movements.inventory_id").
 		Where("stock_movements.inventory_id = ? AND inventories.cafe_id = ?", itemID, cafe.ID)
@@ -510,28 +496,19 @@ func (h *InventoryHandler) GetStockMovements(c *fiber.Ctx) error {
 	})
 }
 
-// GetLowStockItems gets items with low stock (owner only)
+// GetLowStockItems gets items with low stock (owner and staff)
 func (h *InventoryHandler) GetLowStockItems(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	// Get the cafe the caller works for
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Only owners can access inventory reports",
-		})
-	}
-
-	// Get user's cafe
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success": false,
-			"error":   "Cafe not found",
+			"error":   ferr.Message,
 		})
 	}
 
 	var lowStockItems []models.Inventory
-	err = h.db.Where("cafe_id = ? AND current_stock <= min_stock_level AND is_active = ?", cafe.ID, true).
+	err := h.db.Where("cafe_id = ? AND current_stock <= min_stock_level AND is_active = ?", cafe.ID, true).
 		Order("current_stock ASC").
 		Find(&lowStockItems).Error
 
@@ -553,30 +530,21 @@ func (h *InventoryHandler) GetLowStockItems(c *fiber.Ctx) error {
 	})
 }
 
-// GetExpiringItems gets items that will expire soon (owner only)
+// GetExpiringItems gets items that will expire soon (owner and staff)
 func (h *InventoryHandler) GetExpiringItems(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"success": false,
-			"error":   "Only owners can access inventory reports",
-		})
-	}
-
-	// Get user's cafe
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	// Get the cafe the caller works for
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Cafe not found",
+			"error":   ferr.Message,
 		})
 	}
 
 	// Get items expiring in the next 7 days
 	sevenDaysFromNow := time.Now().AddDate(0, 0, 7)
 	var expiringItems []models.Inventory
-	err = h.db.Where("cafe_id = ? AND expiry_date IS NOT NULL AND expiry_date <= ? AND current_stock > 0 AND is_active = ?",
+	err := h.db.Where("cafe_id = ? AND expiry_date IS NOT NULL AND expiry_date <= ? AND current_stock > 0 AND is_active = ?",
 		cafe.ID, sevenDaysFromNow, true).
 		Order("expiry_date ASC").
 		Find(&expiringItems).Error
@@ -599,30 +567,21 @@ func (h *InventoryHandler) GetExpiringItems(c *fiber.Ctx) error {
 	})
 }
 
-// DeleteInventoryItem deletes an inventory item (owner only)
+// DeleteInventoryItem deletes an inventory item (owner and managers)
 func (h *InventoryHandler) DeleteInventoryItem(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	// Get the cafe the caller works for
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Only owners can delete inventory items",
-		})
-	}
-
-	// Get user's cafe
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success": false,
-			"error":   "Cafe not found",
+			"error":   ferr.Message,
 		})
 	}
 
 	itemID := c.Params("id")
 
 	var inventory models.Inventory
-	err = h.db.Where("id = ? AND cafe_id = ?", itemID, cafe.ID).First(&inventory).Error
+	err := h.db.Where("id = ? AND cafe_id = ?", itemID, cafe.ID).First(&inventory).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -636,7 +595,14 @@ func (h *InventoryHandler) DeleteInventoryItem(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.db.Delete(&inventory).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&inventory).Error; err != nil {
+			return err
+		}
+		// Recipes stop tracking a deleted item
+		return refreshSoldOut(tx, inventory.ID)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   "Failed to delete inventory item",
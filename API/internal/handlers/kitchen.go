@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// kitchenStatuses are the order statuses shown on the kitchen display, in
+// the order the columns appear
+var kitchenStatuses = []models.OrderStatus{
+	models.OrderStatusPending,
+	models.OrderStatusConfirmed,
+	models.OrderStatusPreparing,
+	models.OrderStatusReady,
+}
+
+type KitchenHandler struct {
+	db     *gorm.DB
+	events *OrderEventHub
+}
+
+func NewKitchenHandler(db *gorm.DB, events *OrderEventHub) *KitchenHandler {
+	return &KitchenHandler{db: db, events: events}
+}
+
+type KitchenItem struct {
+	ID            string   `json:"id"`
+	MenuID        string   `json:"menu_id"`
+	Name          string   `json:"name"`
+	Quantity      int      `json:"quantity"`
+	Notes         string   `json:"notes"`
+	PrepTime      int      `json:"prep_time"`
+	KitchenStatus string   `json:"kitchen_status"`
+	Options       []string `json:"options"` // e.g. "Milk: Oat"
+}
+
+type KitchenOrder struct {
+	ID             string        `json:"id"`
+	OrderNumber    string        `json:"order_number"`
+	Status         string        `json:"status"`
+	OrderType      string        `json:"order_type"`
+	TableNumber    string        `json:"table_number"`
+	CustomerName   string        `json:"customer_name"`
+	Notes          string        `json:"notes"`
+	PaymentStatus  string        `json:"payment_status"`
+	CreatedAt      time.Time     `json:"created_at"`
+	ScheduledFor   *time.Time    `json:"scheduled_for"`
+	ElapsedMinutes int           `json:"elapsed_minutes"` // since the order reached the kitchen
+	TargetMinutes  int           `json:"target_minutes"`  // longest item prep time, the ETA if set, or the lead time of a scheduled order
+	IsLate         bool          `json:"is_late"`
+	Items          []KitchenItem `json:"items"`
+}
+
+// GetKitchenOrders returns the cafe's active orders grouped by status.
+// Scheduled orders are held back until their lead time before pickup.
+func (h *KitchenHandler) GetKitchenOrders(c *fiber.Ctx) error {
+	cafe, ferr := h.kitchenCafe(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	now := time.Now().UTC()
+	lead := cafe.ScheduledOrderSettings().LeadMinutes
+	releaseCutoff := now.Add(time.Duration(lead) * time.Minute)
+
+	var orders []models.Order
+	err := h.db.Preload("OrderItems.Menu").Preload("OrderItems.Options").
+		Where("cafe_id = ? AND status IN ?", cafe.ID, kitchenStatuses).
+		Where("scheduled_for IS NULL OR scheduled_for <= ?", releaseCutoff).
+		Order("COALESCE(scheduled_for, created_at) ASC").
+		Find(&orders).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to fetch orders",
+		})
+	}
+
+	// Tell the display when the next held-back order is due so it can refresh
+	var upcoming []models.Order
+	h.db.Select("id", "scheduled_for").
+		Where("cafe_id = ? AND status IN ?", cafe.ID, kitchenStatuses).
+		Where("scheduled_for > ?", releaseCutoff).
+		Order("scheduled_for ASC").
+		Find(&upcoming)
+
+	var nextReleaseAt *time.Time
+	if len(upcoming) > 0 {
+		releaseAt := kitchenReleaseAt(*upcoming[0].ScheduledFor, lead)
+		nextReleaseAt = &releaseAt
+	}
+
+	columns := make(fiber.Map, len(kitchenStatuses))
+	for _, status := range kitchenStatuses {
+		columns[string(status)] = []KitchenOrder{}
+	}
+	for _, order := range orders {
+		columns[order.Status] = append(columns[order.Status].([]KitchenOrder), toKitchenOrder(&order, now, lead))
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"cafe_id":         cafe.ID,
+			"orders":          columns,
+			"total_active":    len(orders),
+			"scheduled_later": len(upcoming),
+			"next_release_at": nextReleaseAt,
+			"generated_at":    now,
+		},
+	})
+}
+
+// BumpOrder moves an order to its next kitchen state:
+// pending -> confirmed -> preparing -> ready -> completed
+func (h *KitchenHandler) BumpOrder(c *fiber.Ctx) error {
+	cafe, ferr := h.kitchenCafe(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var order models.Order
+	err := h.db.First(&order, "id = ? AND cafe_id = ?", c.Params("id"), cafe.ID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"error":   "Order not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to fetch order",
+		})
+	}
+
+	next, ok := nextKitchenOrderStatus(models.OrderStatus(order.Status))
+	if !ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   fmt.Sprintf("Order is %s and cannot be bumped", order.Status),
+		})
+	}
+
+	actor := kitchenActor(c)
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		return changeOrderStatus(tx, &order, next, actor, "Bumped from kitchen display")
+	})
+	if err != nil {
+		return kitchenError(c, err)
+	}
+
+	return h.respondWithOrder(c, cafe, order.ID)
+}
+
+// BumpItem moves a single order item to its next kitchen state
+// (queued -> preparing -> done). The order follows its items: it starts
+// preparing with the first item and becomes ready when every item is done.
+func (h *KitchenHandler) BumpItem(c *fiber.Ctx) error {
+	cafe, ferr := h.kitchenCafe(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var order models.Order
+	err := h.db.Preload("OrderItems").First(&order, "id = ? AND cafe_id = ?", c.Params("id"), cafe.ID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"error":   "Order not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to fetch order",
+		})
+	}
+
+	status := models.OrderStatus(order.Status)
+	if status != models.OrderStatusConfirmed && status != models.OrderStatusPreparing {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   fmt.Sprintf("Items of a %s order cannot be bumped", order.Status),
+		})
+	}
+
+	var item *models.OrderItem
+	for i := range order.OrderItems {
+		if order.OrderItems[i].ID == c.Params("itemId") {
+			item = &order.OrderItems[i]
+		}
+	}
+	if item == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Order item not found",
+		})
+	}
+
+	next := models.NextKitchenStatus(item.KitchenStatus)
+	if next == "" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   "Item is already done",
+		})
+	}
+
+	actor := kitchenActor(c)
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OrderItem{}).
+			Where("id = ? AND kitchen_status = ?", item.ID, item.KitchenStatus).
+			Update("kitchen_status", next)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errStatusChanged
+		}
+		item.KitchenStatus = next
+
+		err := publishOrderEvent(tx, order.ID, models.OrderEventItem, fiber.Map{
+			"order_item_id":  item.ID,
+			"menu_id":        item.MenuID,
+			"kitchen_status": next,
+		})
+		if err != nil {
+			return err
+		}
+
+		if models.OrderStatus(order.Status) == models.OrderStatusConfirmed {
+			if err := changeOrderStatus(tx, &order, models.OrderStatusPreparing, actor, "Kitchen started preparing"); err != nil {
+				return err
+			}
+		}
+
+		for _, other := range order.OrderItems {
+			if other.KitchenStatus != models.KitchenStatusDone {
+				return nil
+			}
+		}
+		return changeOrderStatus(tx, &order, models.OrderStatusReady, actor, "All items done")
+	})
+	if err != nil {
+		return kitchenError(c, err)
+	}
+
+	return h.respondWithOrder(c, cafe, order.ID)
+}
+
+// StreamKitchen pushes every order change of the cafe to kitchen screens as
+// server-sent events
+func (h *KitchenHandler) StreamKitchen(c *fiber.Ctx) error {
+	cafe, ferr := h.kitchenCafe(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	return eventStream(c, h.db, h.events, eventFilter{CafeID: cafe.ID})
+}
+
+// kitchenCafe returns the cafe whose kitchen the caller works in
+func (h *KitchenHandler) kitchenCafe(c *fiber.Ctx) (*models.Cafe, *fiber.Error) {
+	return actingCafe(h.db, c)
+}
+
+func (h *KitchenHandler) respondWithOrder(c *fiber.Ctx, cafe *models.Cafe, orderID string) error {
+	var order models.Order
+	h.db.Preload("OrderItems.Menu").Preload("OrderItems.Options").First(&order, "id = ?", orderID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    toKitchenOrder(&order, time.Now(), cafe.ScheduledOrderSettings().LeadMinutes),
+	})
+}
+
+// nextKitchenOrderStatus is the forward (non-cancelling) transition of a status
+func nextKitchenOrderStatus(status models.OrderStatus) (models.OrderStatus, bool) {
+	for _, next := range status.NextStatuses() {
+		if next != models.OrderStatusCancelled {
+			return next, true
+		}
+	}
+	return "", false
+}
+
+func kitchenActor(c *fiber.Ctx) statusActor {
+	return statusActor{
+		ID:   c.Locals("user_id").(string),
+		Role: actingRole(c),
+	}
+}
+
+func kitchenError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errStatusChanged) || errors.Is(err, errInvalidStatusTransition) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   "Order was changed by someone else, please refresh",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"error":   "Failed to update order",
+	})
+}
+
+// toKitchenOrder builds the display card of an order. A scheduled order is
+// timed from its release to the kitchen, so it is late once pickup time passes.
+func toKitchenOrder(order *models.Order, now time.Time, leadMinutes int) KitchenOrder {
+	startedAt := order.CreatedAt
+	if order.ScheduledFor != nil {
+		if releaseAt := kitchenReleaseAt(*order.ScheduledFor, leadMinutes); releaseAt.After(startedAt) {
+			startedAt = releaseAt
+		}
+	}
+
+	ko := KitchenOrder{
+		ID:             order.ID,
+		OrderNumber:    order.OrderNumber,
+		Status:         order.Status,
+		OrderType:      order.OrderType,
+		TableNumber:    order.TableNumber,
+		CustomerName:   order.CustomerName,
+		Notes:          order.Notes,
+		PaymentStatus:  order.PaymentStatus,
+		CreatedAt:      order.CreatedAt,
+		ScheduledFor:   order.ScheduledFor,
+		ElapsedMinutes: int(now.Sub(startedAt).Minutes()),
+		Items:          make([]KitchenItem, 0, len(order.OrderItems)),
+	}
+
+	for _, item := range order.OrderItems {
+		if item.Menu.PrepTime > ko.TargetMinutes {
+			ko.TargetMinutes = item.Menu.PrepTime
+		}
+		options := make([]string, 0, len(item.Options))
+		for _, option := range item.Options {
+			options = append(options, option.GroupName+": "+option.OptionName)
+		}
+		ko.Items = append(ko.Items, KitchenItem{
+			ID:            item.ID,
+			MenuID:        item.MenuID,
+			Name:          item.Menu.Name,
+			Quantity:      item.Quantity,
+			Notes:         item.Notes,
+			PrepTime:      item.Menu.PrepTime,
+			KitchenStatus: item.KitchenStatus,
+			Options:       options,
+		})
+	}
+
+	if order.EstimatedTime > 0 {
+		ko.TargetMinutes = order.EstimatedTime
+	}
+	if order.ScheduledFor != nil {
+		ko.TargetMinutes = int(order.ScheduledFor.Sub(startedAt).Minutes())
+	}
+	ko.IsLate = ko.TargetMinutes > 0 && ko.ElapsedMinutes > ko.TargetMinutes
+
+	return ko
+}
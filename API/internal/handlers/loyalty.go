@@ -21,27 +21,17 @@ func NewLoyaltyHandler(db *gorm.DB) *LoyaltyHandler {
 
 // CreateLoyaltyProgram creates a new loyalty program (owner only)
 func (h *LoyaltyHandler) CreateLoyaltyProgram(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Only owners can create loyalty programs",
-		})
-	}
-
-	// Get user's cafe
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success": false,
-			"error":   "Cafe not found",
+			"error":   ferr.Message,
 		})
 	}
 
 	// Check if program already exists
 	var existingProgram models.LoyaltyProgram
-	err = h.db.Where("cafe_id = ?", cafe.ID).First(&existingProgram).Error
+	err := h.db.Where("cafe_id = ?", cafe.ID).First(&existingProgram).Error
 	if err == nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
@@ -192,27 +182,17 @@ func (h *LoyaltyHandler) JoinLoyaltyProgram(c *fiber.Ctx) error {
 
 // CreateLoyaltyReward creates a new reward (owner only)
 func (h *LoyaltyHandler) CreateLoyaltyReward(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	if user.Role != "owner" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
 			"success": false,
-			"error":   "Only owners can create rewards",
-		})
-	}
-
-	// Get user's cafe
-	var cafe models.Cafe
-	err := h.db.Where("owner_id = ?", user.ID).First(&cafe).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success": false,
-			"error":   "Cafe not found",
+			"error":   ferr.Message,
 		})
 	}
 
 	// Get loyalty program
 	var program models.LoyaltyProgram
-	err = h.db.Where("cafe_id = ? AND is_active = ?", cafe.ID, true).First(&program).Error
+	err := h.db.Where("cafe_id = ? AND is_active = ?", cafe.ID, true).First(&program).Error
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
@@ -242,6 +222,17 @@ func (h *LoyaltyHandler) CreateLoyaltyReward(c *fiber.Ctx) error {
 		})
 	}
 
+	if req.FreeItemID != "" {
+		var freeItems int64
+		h.db.Model(&models.Menu{}).Scopes(inCafe(cafe.ID)).Where("id = ?", req.FreeItemID).Count(&freeItems)
+		if freeItems == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Free item is not on your menu",
+			})
+		}
+	}
+
 	reward := models.LoyaltyReward{
 		ID:            uuid.New().String(),
 		ProgramID:     program.ID,
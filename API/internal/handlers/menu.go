@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"time"
+
 	"siipcoffe-api/internal/models"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,25 +18,55 @@ func NewMenuHandler(db *gorm.DB) *MenuHandler {
 	return &MenuHandler{db: db}
 }
 
+// GetAllMenus lists a cafe's available menu items in category order, both as
+// a flat list and grouped by category
 func (h *MenuHandler) GetAllMenus(c *fiber.Ctx) error {
+	var cafe models.Cafe
+	if err := h.db.Where("id = ? AND status <> ?", c.Params("cafeId"), models.CafeStatusSuspended).First(&cafe).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Cafe not found",
+		})
+	}
+
+	var categories []models.MenuCategory
+	err := h.db.Scopes(inCafe(cafe.ID), menuCategoryOrder).Where("is_active = ?", true).Find(&categories).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch categories",
+			"message": err.Error(),
+		})
+	}
+
 	var menus []models.Menu
-	var err error
+	query := h.db.Scopes(withMenuOptions, inCafe(cafe.ID)).Where("is_available = ?", true)
 
-	// Filter by category if provided
+	// Filter by category slug or ID if provided
 	category := c.Query("category")
 	if category != "" {
-		err = h.db.Where("category = ? AND is_available = ?", category, true).Find(&menus).Error
-	} else {
-		err = h.db.Where("is_available = ?", true).Find(&menus).Error
+		query = query.Where("category = ? OR category_id = ?", category, category)
 	}
 
-	if err != nil {
+	if err := query.Find(&menus).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch menus",
 			"message": err.Error(),
 		})
 	}
 
+	// Items of inactive categories are hidden with them
+	active := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		active[category.ID] = true
+	}
+	listed := menus[:0]
+	for _, menu := range menus {
+		if menu.CategoryID == nil || active[*menu.CategoryID] {
+			listed = append(listed, menu)
+		}
+	}
+	menus = listed
+	sortMenusByCategory(menus, categories)
+
 	// Convert to response format
 	var response []models.MenuResponse
 	for _, menu := range menus {
@@ -44,6 +76,7 @@ func (h *MenuHandler) GetAllMenus(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data": response,
+		"categories": groupMenusByCategory(menus, categories, menuLanguage(c), time.Now().In(cafe.Location())),
 		"count": len(response),
 	})
 }
@@ -52,7 +85,7 @@ func (h *MenuHandler) GetMenuByID(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	var menu models.Menu
-	err := h.db.Where("id = ? AND is_available = ?", id, true).First(&menu).Error
+	err := h.db.Scopes(withMenuOptions, withoutSuspendedCafes).Where("id = ? AND is_available = ?", id, true).First(&menu).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -72,10 +105,19 @@ func (h *MenuHandler) GetMenuByID(c *fiber.Ctx) error {
 }
 
 func (h *MenuHandler) CreateMenu(c *fiber.Ctx) error {
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
 	var req struct {
+		SKU         string  `json:"sku"`
 		Name        string  `json:"name" validate:"required"`
 		Description string  `json:"description"`
-		Category    string  `json:"category" validate:"required"`
+		Category    string  `json:"category"`
+		CategoryID  string  `json:"category_id"`
 		Price       float64 `json:"price" validate:"required,gt=0"`
 		ImageURL    string  `json:"image_url"`
 		IsAvailable bool    `json:"is_available"`
@@ -91,14 +133,20 @@ func (h *MenuHandler) CreateMenu(c *fiber.Ctx) error {
 	}
 
 	// Validate required fields
-	if req.Name == "" || req.Category == "" || req.Price <= 0 {
+	if req.Name == "" || (req.Category == "" && req.CategoryID == "") || req.Price <= 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Name, category, and price are required",
 		})
 	}
+	if req.CategoryID == "" && models.MenuCategorySlug(req.Category) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Category must contain letters or digits",
+		})
+	}
 
 	menu := models.Menu{
 		ID:          uuid.New().String(),
+		CafeID:      cafe.ID,
 		Name:        req.Name,
 		Description: req.Description,
 		Category:    req.Category,
@@ -108,8 +156,34 @@ func (h *MenuHandler) CreateMenu(c *fiber.Ctx) error {
 		Ingredients: req.Ingredients,
 		PrepTime:    req.PrepTime,
 	}
+	if req.SKU != "" {
+		if menuSKUTaken(h.db, menu.CafeID, req.SKU, "") {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Another menu item already uses SKU " + req.SKU,
+			})
+		}
+		menu.SKU = &req.SKU
+	}
+	if req.CategoryID != "" {
+		category, ferr := menuCategoryByID(h.db, cafe.ID, req.CategoryID)
+		if ferr != nil {
+			return c.Status(ferr.Code).JSON(fiber.Map{
+				"error": ferr.Message,
+			})
+		}
+		menu.CategoryID = &category.ID
+		menu.Category = category.Slug
+	}
 
-	if err := h.db.Create(&menu).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if menu.CategoryID == nil {
+			if err := assignMenuCategory(tx, &menu); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&menu).Error
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create menu",
 			"message": err.Error(),
@@ -126,8 +200,15 @@ func (h *MenuHandler) CreateMenu(c *fiber.Ctx) error {
 func (h *MenuHandler) UpdateMenu(c *fiber.Ctx) error {
 	id := c.Params("id")
 
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
 	var menu models.Menu
-	err := h.db.First(&menu, "id = ?", id).Error
+	err := h.db.Scopes(inCafe(cafe.ID)).First(&menu, "id = ?", id).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -141,9 +222,11 @@ func (h *MenuHandler) UpdateMenu(c *fiber.Ctx) error {
 	}
 
 	var req struct {
+		SKU         *string  `json:"sku"`
 		Name        *string  `json:"name"`
 		Description *string  `json:"description"`
 		Category    *string  `json:"category"`
+		CategoryID  *string  `json:"category_id"`
 		Price       *float64 `json:"price"`
 		ImageURL    *string  `json:"image_url"`
 		IsAvailable *bool    `json:"is_available"`
@@ -160,23 +243,63 @@ func (h *MenuHandler) UpdateMenu(c *fiber.Ctx) error {
 
 	// Update only provided fields
 	updates := make(map[string]interface{})
+	if req.SKU != nil {
+		if *req.SKU == "" {
+			updates["sku"] = nil
+		} else if menuSKUTaken(h.db, menu.CafeID, *req.SKU, menu.ID) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Another menu item already uses SKU " + *req.SKU,
+			})
+		} else {
+			updates["sku"] = *req.SKU
+		}
+	}
 	if req.Name != nil {
 		updates["name"] = *req.Name
 	}
 	if req.Description != nil {
 		updates["description"] = *req.Description
 	}
-	if req.Category != nil {
-		updates["category"] = *req.Category
+	if req.CategoryID != nil {
+		category, ferr := menuCategoryByID(h.db, cafe.ID, *req.CategoryID)
+		if ferr != nil {
+			return c.Status(ferr.Code).JSON(fiber.Map{
+				"error": ferr.Message,
+			})
+		}
+		updates["category_id"] = category.ID
+		updates["category"] = category.Slug
+	} else if req.Category != nil {
+		if models.MenuCategorySlug(*req.Category) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Category must contain letters or digits",
+			})
+		}
+		moved := models.Menu{CafeID: menu.CafeID, Category: *req.Category}
+		if err := assignMenuCategory(h.db, &moved); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update menu",
+				"message": err.Error(),
+			})
+		}
+		updates["category_id"] = *moved.CategoryID
+		updates["category"] = moved.Category
 	}
 	if req.Price != nil {
 		updates["price"] = *req.Price
+		// A branch price on a catalogue item is kept when the catalogue changes
+		if menu.CatalogItemID != nil {
+			updates["price_overridden"] = true
+		}
 	}
 	if req.ImageURL != nil {
 		updates["image_url"] = *req.ImageURL
 	}
 	if req.IsAvailable != nil {
 		updates["is_available"] = *req.IsAvailable
+		if menu.CatalogItemID != nil {
+			updates["availability_overridden"] = true
+		}
 	}
 	if req.Ingredients != nil {
 		updates["ingredients"] = *req.Ingredients
@@ -193,7 +316,7 @@ func (h *MenuHandler) UpdateMenu(c *fiber.Ctx) error {
 	}
 
 	// Refresh menu data
-	h.db.First(&menu, "id = ?", id)
+	h.db.Scopes(withMenuOptions).First(&menu, "id = ?", id)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -205,12 +328,24 @@ func (h *MenuHandler) UpdateMenu(c *fiber.Ctx) error {
 func (h *MenuHandler) DeleteMenu(c *fiber.Ctx) error {
 	id := c.Params("id")
 
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
 	// Soft delete by setting is_available to false
-	err := h.db.Model(&models.Menu{}).Where("id = ?", id).Update("is_available", false).Error
-	if err != nil {
+	result := h.db.Model(&models.Menu{}).Scopes(inCafe(cafe.ID)).Where("id = ?", id).Update("is_available", false)
+	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete menu",
-			"message": err.Error(),
+			"message": result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Menu not found",
 		})
 	}
 
@@ -220,43 +355,14 @@ func (h *MenuHandler) DeleteMenu(c *fiber.Ctx) error {
 	})
 }
 
-func (h *MenuHandler) GetCategories(c *fiber.Ctx) error {
-	categories := models.GetDefaultCategories()
-
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data": categories,
-	})
+// withoutSuspendedCafes hides the menus of cafes suspended by a platform admin
+func withoutSuspendedCafes(db *gorm.DB) *gorm.DB {
+	suspended := db.Session(&gorm.Session{NewDB: true}).Model(&models.Cafe{}).Select("id").Where("status = ?", models.CafeStatusSuspended)
+	return db.Where("cafe_id NOT IN (?)", suspended)
 }
 
-func (h *MenuHandler) SearchMenus(c *fiber.Ctx) error {
-	query := c.Query("q")
-	if query == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Search query is required",
-		})
-	}
-
-	var menus []models.Menu
-	err := h.db.Where("is_available = ? AND (name LIKE ? OR description LIKE ? OR category LIKE ?)",
-		true, "%"+query+"%", "%"+query+"%", "%"+query+"%").Find(&menus).Error
-
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to search menus",
-			"message": err.Error(),
-		})
-	}
-
-	var response []models.MenuResponse
-	for _, menu := range menus {
-		response = append(response, menu.ToResponse())
-	}
-
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data": response,
-		"count": len(response),
-		"query": query,
-	})
-}
\ No newline at end of file
+// withoutInactiveCategories hides the menus of categories the cafe switched off
+func withoutInactiveCategories(db *gorm.DB) *gorm.DB {
+	inactive := db.Session(&gorm.Session{NewDB: true}).Model(&models.MenuCategory{}).Select("id").Where("is_active = ?", false)
+	return db.Where("category_id IS NULL OR category_id NOT IN (?)", inactive)
+}
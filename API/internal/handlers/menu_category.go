@@ -0,0 +1,519 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"siipcoffe-api/internal/models"
+	"siipcoffe-api/pkg/businesshours"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MenuCategoryRequest creates or updates a menu category. The slug defaults
+// to one made from the English name, or the Indonesian name without one.
+type MenuCategoryRequest struct {
+	Slug         *string                 `json:"slug"`
+	NameID       *string                 `json:"name_id"`
+	NameEN       *string                 `json:"name_en"`
+	Icon         *string                 `json:"icon"`
+	SortOrder    *int                    `json:"sort_order"`
+	IsActive     *bool                   `json:"is_active"`
+	Availability *businesshours.Schedule `json:"availability"`
+}
+
+// MenuCategoryGroup is a category of a menu listing with its items
+type MenuCategoryGroup struct {
+	Category *models.MenuCategoryResponse `json:"category"` // nil for items without a category
+	Items    []models.MenuResponse        `json:"items"`
+}
+
+// GetCategories lists a cafe's active menu categories in menu order. Names
+// are in Indonesian unless lang=en or the Accept-Language header asks for
+// English.
+func (h *MenuHandler) GetCategories(c *fiber.Ctx) error {
+	var cafe models.Cafe
+	if err := h.db.Where("id = ? AND status <> ?", c.Params("cafeId"), models.CafeStatusSuspended).First(&cafe).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Cafe not found",
+		})
+	}
+
+	var categories []models.MenuCategory
+	if err := h.db.Scopes(inCafe(cafe.ID), menuCategoryOrder).Where("is_active = ?", true).Find(&categories).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to fetch categories",
+			"message": err.Error(),
+		})
+	}
+
+	lang := menuLanguage(c)
+	now := time.Now().In(cafe.Location())
+	response := make([]models.MenuCategoryResponse, 0, len(categories))
+	for _, category := range categories {
+		response = append(response, category.ToResponse(lang, now))
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// ListMenuCategories lists all categories of the acting cafe, inactive ones
+// included, with the number of menu items in each (owner only)
+func (h *MenuHandler) ListMenuCategories(c *fiber.Ctx) error {
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	var categories []models.MenuCategory
+	if err := h.db.Scopes(inCafe(cafe.ID), menuCategoryOrder).Find(&categories).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to fetch categories",
+			"message": err.Error(),
+		})
+	}
+
+	var counts []struct {
+		CategoryID string
+		Count      int64
+	}
+	h.db.Model(&models.Menu{}).
+		Select("category_id, COUNT(*) as count").
+		Where("cafe_id = ? AND category_id IS NOT NULL", cafe.ID).
+		Group("category_id").
+		Scan(&counts)
+	menuCounts := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		menuCounts[count.CategoryID] = count.Count
+	}
+
+	lang := menuLanguage(c)
+	now := time.Now().In(cafe.Location())
+	response := make([]models.MenuCategoryResponse, 0, len(categories))
+	for _, category := range categories {
+		item := category.ToResponse(lang, now)
+		count := menuCounts[category.ID]
+		item.MenuCount = &count
+		response = append(response, item)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// CreateMenuCategory adds a category to the acting cafe's menu (owner only)
+func (h *MenuHandler) CreateMenuCategory(c *fiber.Ctx) error {
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	var req MenuCategoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"message": err.Error(),
+		})
+	}
+	if req.NameID == nil || strings.TrimSpace(*req.NameID) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "name_id is required",
+		})
+	}
+
+	category := models.MenuCategory{
+		ID:       uuid.New().String(),
+		CafeID:   cafe.ID,
+		NameID:   strings.TrimSpace(*req.NameID),
+		IsActive: true,
+	}
+	if req.NameEN != nil {
+		category.NameEN = strings.TrimSpace(*req.NameEN)
+	}
+	if req.Icon != nil {
+		category.Icon = *req.Icon
+	}
+	if req.SortOrder != nil {
+		category.SortOrder = *req.SortOrder
+	} else {
+		category.SortOrder = nextCategorySortOrder(h.db, cafe.ID)
+	}
+	if req.Availability != nil {
+		if err := req.Availability.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid availability",
+				"message": err.Error(),
+			})
+		}
+		category.Availability = *req.Availability
+	}
+
+	switch {
+	case req.Slug != nil && *req.Slug != "":
+		category.Slug = models.MenuCategorySlug(*req.Slug)
+	case category.NameEN != "":
+		category.Slug = models.MenuCategorySlug(category.NameEN)
+	default:
+		category.Slug = models.MenuCategorySlug(category.NameID)
+	}
+	if ferr := h.checkCategorySlug(cafe.ID, category.Slug, ""); ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	active := req.IsActive == nil || *req.IsActive
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&category).Error; err != nil {
+			return err
+		}
+		// is_active defaults to true on insert
+		if !active {
+			category.IsActive = false
+			return tx.Model(&models.MenuCategory{}).Where("id = ?", category.ID).Update("is_active", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to create category",
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Category created successfully",
+		"data":    category.ToResponse(menuLanguage(c), time.Now().In(cafe.Location())),
+	})
+}
+
+// UpdateMenuCategory changes a category of the acting cafe. A new slug is
+// carried over to the category's menu items (owner only).
+func (h *MenuHandler) UpdateMenuCategory(c *fiber.Ctx) error {
+	cafe, category, ferr := h.ownedMenuCategory(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	var req MenuCategoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"message": err.Error(),
+		})
+	}
+
+	updates := make(map[string]interface{})
+	if req.NameID != nil {
+		if strings.TrimSpace(*req.NameID) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "name_id cannot be empty",
+			})
+		}
+		updates["name_id"] = strings.TrimSpace(*req.NameID)
+	}
+	if req.NameEN != nil {
+		updates["name_en"] = strings.TrimSpace(*req.NameEN)
+	}
+	if req.Icon != nil {
+		updates["icon"] = *req.Icon
+	}
+	if req.SortOrder != nil {
+		updates["sort_order"] = *req.SortOrder
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if req.Availability != nil {
+		if err := req.Availability.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid availability",
+				"message": err.Error(),
+			})
+		}
+		updates["availability"] = *req.Availability
+	}
+
+	slug := category.Slug
+	if req.Slug != nil {
+		slug = models.MenuCategorySlug(*req.Slug)
+		if ferr := h.checkCategorySlug(cafe.ID, slug, category.ID); ferr != nil {
+			return c.Status(ferr.Code).JSON(fiber.Map{
+				"error": ferr.Message,
+			})
+		}
+		updates["slug"] = slug
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.MenuCategory{}).Where("id = ?", category.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if slug == category.Slug {
+			return nil
+		}
+		return tx.Unscoped().Model(&models.Menu{}).Where("category_id = ?", category.ID).Update("category", slug).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to update category",
+			"message": err.Error(),
+		})
+	}
+
+	h.db.First(category, "id = ?", category.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Category updated successfully",
+		"data":    category.ToResponse(menuLanguage(c), time.Now().In(cafe.Location())),
+	})
+}
+
+// DeleteMenuCategory removes an empty category of the acting cafe (owner only)
+func (h *MenuHandler) DeleteMenuCategory(c *fiber.Ctx) error {
+	_, category, ferr := h.ownedMenuCategory(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	var menus int64
+	h.db.Model(&models.Menu{}).Where("category_id = ?", category.ID).Count(&menus)
+	if menus > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":      "Move or delete the menu items in this category first",
+			"menu_count": menus,
+		})
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Archived menu items keep their category slug but lose the link
+		if err := tx.Unscoped().Model(&models.Menu{}).Where("category_id = ?", category.ID).Update("category_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to delete category",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Category deleted successfully",
+	})
+}
+
+// ownedMenuCategory loads the category in the route of the acting cafe
+func (h *MenuHandler) ownedMenuCategory(c *fiber.Ctx) (*models.Cafe, *models.MenuCategory, *fiber.Error) {
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return nil, nil, ferr
+	}
+
+	var category models.MenuCategory
+	if err := h.db.Scopes(inCafe(cafe.ID)).First(&category, "id = ?", c.Params("categoryId")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, fiber.NewError(fiber.StatusNotFound, "Category not found")
+		}
+		return nil, nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch category")
+	}
+	return cafe, &category, nil
+}
+
+func (h *MenuHandler) checkCategorySlug(cafeID, slug, exceptID string) *fiber.Error {
+	if slug == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Category slug must contain letters or digits")
+	}
+	var count int64
+	h.db.Model(&models.MenuCategory{}).Where("cafe_id = ? AND slug = ? AND id <> ?", cafeID, slug, exceptID).Count(&count)
+	if count > 0 {
+		return fiber.NewError(fiber.StatusConflict, "Your menu already has a category "+slug)
+	}
+	return nil
+}
+
+// nextCategorySortOrder returns the sort order that puts a new category at
+// the end of a cafe's menu
+func nextCategorySortOrder(db *gorm.DB, cafeID string) int {
+	var last struct{ SortOrder int }
+	db.Model(&models.MenuCategory{}).Select("MAX(sort_order) as sort_order").Where("cafe_id = ?", cafeID).Scan(&last)
+	return last.SortOrder + 1
+}
+
+// menuCategoryOrder sorts categories the way the menu shows them
+func menuCategoryOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("sort_order ASC, name_id ASC")
+}
+
+// menuLanguage returns the language to show category names in, "en" or "id"
+func menuLanguage(c *fiber.Ctx) string {
+	if lang := c.Query("lang"); lang != "" {
+		if lang == "en" {
+			return "en"
+		}
+		return "id"
+	}
+	if c.AcceptsLanguages("id", "en") == "en" {
+		return "en"
+	}
+	return "id"
+}
+
+// assignMenuCategory links menu to the category of its cafe that its
+// Category names, by slug or display name. Unknown names become a new
+// category, so menu items created without managing categories still group.
+func assignMenuCategory(tx *gorm.DB, menu *models.Menu) error {
+	name := strings.TrimSpace(menu.Category)
+	slug := models.MenuCategorySlug(name)
+	if slug == "" {
+		return fmt.Errorf("category %q must contain letters or digits", name)
+	}
+
+	var category models.MenuCategory
+	err := tx.Scopes(inCafe(menu.CafeID)).
+		Where("slug = ? OR LOWER(name_id) = ? OR LOWER(name_en) = ?", slug, strings.ToLower(name), strings.ToLower(name)).
+		First(&category).Error
+	if err == gorm.ErrRecordNotFound {
+		category = models.NewMenuCategory(uuid.New().String(), menu.CafeID, name)
+		category.SortOrder = nextCategorySortOrder(tx, menu.CafeID)
+		err = tx.Create(&category).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	menu.CategoryID = &category.ID
+	menu.Category = category.Slug
+	return nil
+}
+
+// menuCategoryByID loads a category of a cafe chosen by its ID
+func menuCategoryByID(db *gorm.DB, cafeID, id string) (*models.MenuCategory, *fiber.Error) {
+	var category models.MenuCategory
+	if err := db.Scopes(inCafe(cafeID)).First(&category, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Category "+id+" is not on your menu")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch category")
+	}
+	return &category, nil
+}
+
+// checkMenuCategories makes sure the categories of the ordered items are
+// active and serving at the given time
+func checkMenuCategories(db *gorm.DB, cafe *models.Cafe, items []models.OrderItem, at time.Time) *fiber.Error {
+	var categoryIDs []string
+	for _, item := range items {
+		if item.Menu.CategoryID != nil {
+			categoryIDs = append(categoryIDs, *item.Menu.CategoryID)
+		}
+	}
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+
+	var categories []models.MenuCategory
+	if err := db.Where("id IN ?", categoryIDs).Find(&categories).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch menu categories")
+	}
+	byID := make(map[string]*models.MenuCategory, len(categories))
+	for i := range categories {
+		byID[categories[i].ID] = &categories[i]
+	}
+
+	local := at.In(cafe.Location())
+	for _, item := range items {
+		if item.Menu.CategoryID == nil {
+			continue
+		}
+		category := byID[*item.Menu.CategoryID]
+		if category == nil {
+			continue
+		}
+		if !category.IsActive {
+			return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("Menu item %s not found or unavailable", item.MenuID))
+		}
+		if !category.IsAvailableAt(local) {
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("%s is not served at this time (%s)", item.Menu.Name, category.NameID))
+		}
+	}
+	return nil
+}
+
+// groupMenusByCategory groups menu items under their categories in menu
+// order. Items of categories missing from categories are left out, items
+// without a category come last.
+func groupMenusByCategory(menus []models.Menu, categories []models.MenuCategory, lang string, now time.Time) []MenuCategoryGroup {
+	groups := make([]MenuCategoryGroup, 0, len(categories)+1)
+	index := make(map[string]int, len(categories))
+	for _, category := range categories {
+		response := category.ToResponse(lang, now)
+		index[category.ID] = len(groups)
+		groups = append(groups, MenuCategoryGroup{Category: &response, Items: []models.MenuResponse{}})
+	}
+
+	var uncategorized []models.MenuResponse
+	for _, menu := range menus {
+		if menu.CategoryID == nil {
+			uncategorized = append(uncategorized, menu.ToResponse())
+			continue
+		}
+		if i, ok := index[*menu.CategoryID]; ok {
+			groups[i].Items = append(groups[i].Items, menu.ToResponse())
+		}
+	}
+
+	// Categories without items are left out of the listing
+	listed := groups[:0]
+	for _, group := range groups {
+		if len(group.Items) > 0 {
+			listed = append(listed, group)
+		}
+	}
+	if len(uncategorized) > 0 {
+		listed = append(listed, MenuCategoryGroup{Items: uncategorized})
+	}
+	return listed
+}
+
+// sortMenusByCategory orders menu items by the position of their category,
+// keeping the order of items within a category
+func sortMenusByCategory(menus []models.Menu, categories []models.MenuCategory) {
+	position := make(map[string]int, len(categories))
+	for i, category := range categories {
+		position[category.ID] = i
+	}
+	rank := func(menu models.Menu) int {
+		if menu.CategoryID != nil {
+			if i, ok := position[*menu.CategoryID]; ok {
+				return i
+			}
+		}
+		return len(categories)
+	}
+	sort.SliceStable(menus, func(i, j int) bool { return rank(menus[i]) < rank(menus[j]) })
+}
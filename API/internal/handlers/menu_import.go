@@ -0,0 +1,667 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxMenuImportRows limits the size of one import
+const maxMenuImportRows = 1000
+
+// menuTransferColumns are the CSV columns of a menu export, in order. Imports
+// may leave out any column except name, category and price for new items.
+var menuTransferColumns = []string{
+	"id", "sku", "name", "description", "category", "price", "image_url", "is_available",
+	"ingredients", "prep_time", "is_popular", "is_recommended", "calories", "allergens", "customizable",
+}
+
+// MenuTransferItem is a menu item in an export or import file. Fields left
+// out of an import row keep their current value.
+type MenuTransferItem struct {
+	ID            string    `json:"id,omitempty"`
+	SKU           string    `json:"sku"`
+	Name          *string   `json:"name"`
+	Description   *string   `json:"description"`
+	Category      *string   `json:"category"`
+	Price         *float64  `json:"price"`
+	ImageURL      *string   `json:"image_url"`
+	IsAvailable   *bool     `json:"is_available"`
+	Ingredients   *string   `json:"ingredients"`
+	PrepTime      *int      `json:"prep_time"`
+	IsPopular     *bool     `json:"is_popular"`
+	IsRecommended *bool     `json:"is_recommended"`
+	Calories      *int      `json:"calories"`
+	Allergens     *[]string `json:"allergens"`
+	Customizable  *bool     `json:"customizable"`
+}
+
+// MenuExport is the JSON export of a cafe's menu
+type MenuExport struct {
+	CafeID     string             `json:"cafe_id"`
+	ExportedAt time.Time          `json:"exported_at"`
+	Items      []MenuTransferItem `json:"items"`
+}
+
+// MenuImportRow is the outcome of one row of an import
+type MenuImportRow struct {
+	Row    int      `json:"row"` // line in a CSV file, position in a JSON list
+	SKU    string   `json:"sku,omitempty"`
+	Name   string   `json:"name,omitempty"`
+	Action string   `json:"action"` // create, update or error
+	MenuID string   `json:"menu_id,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// MenuImportReport summarises an import or its dry run
+type MenuImportReport struct {
+	DryRun  bool            `json:"dry_run"`
+	Applied bool            `json:"applied"`
+	Total   int             `json:"total"`
+	Created int             `json:"created"`
+	Updated int             `json:"updated"`
+	Failed  int             `json:"failed"`
+	Rows    []MenuImportRow `json:"rows"`
+}
+
+// ExportMenu downloads every menu item of the caller's cafe as CSV or JSON
+// (owner only)
+func (h *MenuHandler) ExportMenu(c *fiber.Ctx) error {
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	format := c.Query("format", "json")
+	if format != "json" && format != "csv" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be csv or json",
+		})
+	}
+
+	var menus []models.Menu
+	if err := h.db.Where("cafe_id = ?", cafe.ID).Order("category ASC, name ASC").Find(&menus).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to fetch menus",
+			"message": err.Error(),
+		})
+	}
+
+	items := make([]MenuTransferItem, 0, len(menus))
+	for i := range menus {
+		items = append(items, menuTransferItem(&menus[i]))
+	}
+
+	filename := fmt.Sprintf("menu-%s-%s.%s", cafe.ID, time.Now().Format("20060102"), format)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+
+	if format == "json" {
+		return c.JSON(MenuExport{
+			CafeID:     cafe.ID,
+			ExportedAt: time.Now(),
+			Items:      items,
+		})
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(menuTransferColumns)
+	for _, item := range items {
+		w.Write(item.csvRecord())
+	}
+	w.Flush()
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	return c.Send(buf.Bytes())
+}
+
+// ImportMenu creates and updates menu items from a CSV or JSON file. Rows are
+// matched to existing items by SKU, then by id; unmatched rows with a SKU are
+// created. Every row is validated first; the import is applied in one
+// transaction only when no row has errors, and never with dry_run=true (owner
+// only).
+func (h *MenuHandler) ImportMenu(c *fiber.Ctx) error {
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	data, format, ferr := menuImportFile(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	var items []MenuTransferItem
+	var rowNumbers []int
+	var rowErrors [][]string
+	var err error
+	if format == "csv" {
+		items, rowNumbers, rowErrors, err = parseMenuCSV(data)
+	} else {
+		items, err = parseMenuJSON(data)
+		rowNumbers = make([]int, len(items))
+		rowErrors = make([][]string, len(items))
+		for i := range items {
+			rowNumbers[i] = i + 1
+		}
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid import file",
+			"message": err.Error(),
+		})
+	}
+	if len(items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The import file has no menu items",
+		})
+	}
+	if len(items) > maxMenuImportRows {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("An import can have at most %d menu items", maxMenuImportRows),
+		})
+	}
+
+	var existing []models.Menu
+	if err := h.db.Unscoped().Where("cafe_id = ?", cafe.ID).Find(&existing).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to fetch menus",
+			"message": err.Error(),
+		})
+	}
+	bySKU := make(map[string]*models.Menu, len(existing))
+	byID := make(map[string]*models.Menu, len(existing))
+	for i := range existing {
+		byID[existing[i].ID] = &existing[i]
+		if existing[i].SKU != nil {
+			bySKU[*existing[i].SKU] = &existing[i]
+		}
+	}
+
+	report := MenuImportReport{
+		DryRun: c.QueryBool("dry_run", false),
+		Total:  len(items),
+		Rows:   make([]MenuImportRow, 0, len(items)),
+	}
+	planned := make([]models.Menu, len(items))
+	seenSKUs := make(map[string]int, len(items))
+	// claimed keeps rows from updating the same menu item twice, say one by
+	// id and another by the SKU the first one renames
+	claimed := make(map[string]int, len(items))
+
+	for i, item := range items {
+		row := MenuImportRow{Row: rowNumbers[i], SKU: item.SKU, Errors: rowErrors[i]}
+
+		var current *models.Menu
+		switch {
+		case item.SKU != "" && bySKU[item.SKU] != nil:
+			current = bySKU[item.SKU]
+			if item.ID != "" && item.ID != current.ID {
+				row.Errors = append(row.Errors, "sku "+item.SKU+" belongs to another menu item")
+			}
+		case item.ID != "" && byID[item.ID] != nil:
+			current = byID[item.ID]
+		case item.SKU == "" && item.ID != "":
+			row.Errors = append(row.Errors, "menu item "+item.ID+" does not exist in this cafe, give it a sku to create it")
+		case item.SKU == "":
+			row.Errors = append(row.Errors, "sku is required for new menu items")
+		}
+		if current != nil {
+			if first, ok := claimed[current.ID]; ok {
+				row.Errors = append(row.Errors, fmt.Sprintf("menu item %s is already updated by row %d", current.ID, first))
+			} else {
+				claimed[current.ID] = row.Row
+			}
+		}
+		if item.SKU != "" {
+			if first, ok := seenSKUs[item.SKU]; ok {
+				row.Errors = append(row.Errors, fmt.Sprintf("sku %s is already used on row %d", item.SKU, first))
+			} else {
+				seenSKUs[item.SKU] = row.Row
+			}
+		}
+
+		menu := models.Menu{ID: uuid.New().String(), CafeID: cafe.ID, IsAvailable: true}
+		row.Action = "create"
+		if current != nil {
+			menu = *current
+			row.Action = "update"
+		}
+		if item.SKU != "" {
+			sku := item.SKU
+			menu.SKU = &sku
+		}
+		item.applyTo(&menu)
+		row.Errors = append(row.Errors, validateImportedMenu(&menu)...)
+
+		row.Name = menu.Name
+		if current != nil {
+			row.MenuID = current.ID
+		}
+		planned[i] = menu
+
+		switch {
+		case len(row.Errors) > 0:
+			row.Action = "error"
+			report.Failed++
+		case row.Action == "create":
+			report.Created++
+		default:
+			report.Updated++
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	if report.DryRun {
+		return c.JSON(fiber.Map{
+			"success": report.Failed == 0,
+			"data":    report,
+		})
+	}
+	if report.Failed > 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"success": false,
+			"error":   "Some rows are invalid, nothing was imported",
+			"data":    report,
+		})
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		for i := range planned {
+			if report.Rows[i].Action == "create" {
+				if err := createImportedMenu(tx, &planned[i]); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := updateImportedMenu(tx, byID[planned[i].ID], &planned[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to import menu",
+			"message": err.Error(),
+		})
+	}
+
+	report.Applied = true
+	for i := range planned {
+		report.Rows[i].MenuID = planned[i].ID
+	}
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": fmt.Sprintf("Menu imported: %d created, %d updated", report.Created, report.Updated),
+		"data":    report,
+	})
+}
+
+// menuImportFile returns the uploaded file, from the "file" form field or the
+// request body, and whether it is CSV or JSON
+func menuImportFile(c *fiber.Ctx) ([]byte, string, *fiber.Error) {
+	format := c.Query("format")
+	data := c.Body()
+
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			return nil, "", fiber.NewError(fiber.StatusBadRequest, "Failed to read the uploaded file")
+		}
+		defer f.Close()
+		if data, err = io.ReadAll(f); err != nil {
+			return nil, "", fiber.NewError(fiber.StatusBadRequest, "Failed to read the uploaded file")
+		}
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Filename)), ".")
+		}
+	}
+
+	if format == "" {
+		if strings.Contains(c.Get(fiber.HeaderContentType), "csv") {
+			format = "csv"
+		} else {
+			format = "json"
+		}
+	}
+	if format != "json" && format != "csv" {
+		return nil, "", fiber.NewError(fiber.StatusBadRequest, "format must be csv or json")
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, "", fiber.NewError(fiber.StatusBadRequest, "The import file is empty")
+	}
+	return data, format, nil
+}
+
+// parseMenuJSON reads a JSON export, or a plain list of menu items
+func parseMenuJSON(data []byte) ([]MenuTransferItem, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var items []MenuTransferItem
+		err := json.Unmarshal(data, &items)
+		return items, err
+	}
+
+	var export MenuExport
+	err := json.Unmarshal(data, &export)
+	return export.Items, err
+}
+
+// parseMenuCSV reads a CSV file with a header row. Cells that cannot be read
+// are reported as errors of their row, together with the row's line number.
+func parseMenuCSV(data []byte) ([]MenuTransferItem, []int, [][]string, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("missing header row")
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		known := false
+		for _, name := range menuTransferColumns {
+			known = known || name == header[i]
+		}
+		if !known {
+			return nil, nil, nil, fmt.Errorf("unknown column %q, columns are %s", column, strings.Join(menuTransferColumns, ", "))
+		}
+	}
+
+	var items []MenuTransferItem
+	var rowNumbers []int
+	var rowErrors [][]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		line, _ := r.FieldPos(0)
+
+		var item MenuTransferItem
+		var errs []string
+		for i, column := range header {
+			if err := item.setCSVField(column, strings.TrimSpace(record[i])); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+
+		items = append(items, item)
+		rowNumbers = append(rowNumbers, line)
+		rowErrors = append(rowErrors, errs)
+	}
+
+	return items, rowNumbers, rowErrors, nil
+}
+
+// setCSVField sets the field of a CSV column. Empty numbers and flags are
+// left unset.
+func (item *MenuTransferItem) setCSVField(column, value string) error {
+	switch column {
+	case "id":
+		item.ID = value
+	case "sku":
+		item.SKU = value
+	case "name":
+		item.Name = &value
+	case "description":
+		item.Description = &value
+	case "category":
+		item.Category = &value
+	case "image_url":
+		item.ImageURL = &value
+	case "ingredients":
+		item.Ingredients = &value
+	case "allergens":
+		allergens := []string{}
+		for _, allergen := range strings.Split(value, ";") {
+			if allergen = strings.TrimSpace(allergen); allergen != "" {
+				allergens = append(allergens, allergen)
+			}
+		}
+		item.Allergens = &allergens
+	case "price":
+		if value == "" {
+			return nil
+		}
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("price must be a number")
+		}
+		item.Price = &price
+	case "prep_time", "calories":
+		if value == "" {
+			return nil
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number", column)
+		}
+		if column == "prep_time" {
+			item.PrepTime = &number
+		} else {
+			item.Calories = &number
+		}
+	case "is_available", "is_popular", "is_recommended", "customizable":
+		if value == "" {
+			return nil
+		}
+		flag, err := strconv.ParseBool(strings.ToLower(value))
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", column)
+		}
+		switch column {
+		case "is_available":
+			item.IsAvailable = &flag
+		case "is_popular":
+			item.IsPopular = &flag
+		case "is_recommended":
+			item.IsRecommended = &flag
+		default:
+			item.Customizable = &flag
+		}
+	}
+	return nil
+}
+
+// csvRecord returns the item's cells in menuTransferColumns order
+func (item MenuTransferItem) csvRecord() []string {
+	return []string{
+		item.ID,
+		item.SKU,
+		*item.Name,
+		*item.Description,
+		*item.Category,
+		strconv.FormatFloat(*item.Price, 'f', -1, 64),
+		*item.ImageURL,
+		strconv.FormatBool(*item.IsAvailable),
+		*item.Ingredients,
+		strconv.Itoa(*item.PrepTime),
+		strconv.FormatBool(*item.IsPopular),
+		strconv.FormatBool(*item.IsRecommended),
+		strconv.Itoa(*item.Calories),
+		strings.Join(*item.Allergens, ";"),
+		strconv.FormatBool(*item.Customizable),
+	}
+}
+
+// applyTo copies the fields set in the row onto menu
+func (item MenuTransferItem) applyTo(menu *models.Menu) {
+	if item.Name != nil {
+		menu.Name = strings.TrimSpace(*item.Name)
+	}
+	if item.Description != nil {
+		menu.Description = *item.Description
+	}
+	if item.Category != nil {
+		menu.Category = strings.TrimSpace(*item.Category)
+	}
+	if item.Price != nil {
+		menu.Price = *item.Price
+	}
+	if item.ImageURL != nil {
+		menu.ImageURL = *item.ImageURL
+	}
+	if item.IsAvailable != nil {
+		menu.IsAvailable = *item.IsAvailable
+	}
+	if item.Ingredients != nil {
+		menu.Ingredients = *item.Ingredients
+	}
+	if item.PrepTime != nil {
+		menu.PrepTime = *item.PrepTime
+	}
+	if item.IsPopular != nil {
+		menu.IsPopular = *item.IsPopular
+	}
+	if item.IsRecommended != nil {
+		menu.IsRecommended = *item.IsRecommended
+	}
+	if item.Calories != nil {
+		menu.Calories = *item.Calories
+	}
+	if item.Allergens != nil {
+		allergens, _ := json.Marshal(*item.Allergens)
+		menu.Allergens = string(allergens)
+	}
+	if item.Customizable != nil {
+		menu.Customizable = *item.Customizable
+	}
+}
+
+// menuTransferItem converts a menu item for export
+func menuTransferItem(menu *models.Menu) MenuTransferItem {
+	item := MenuTransferItem{
+		ID:            menu.ID,
+		Name:          &menu.Name,
+		Description:   &menu.Description,
+		Category:      &menu.Category,
+		Price:         &menu.Price,
+		ImageURL:      &menu.ImageURL,
+		IsAvailable:   &menu.IsAvailable,
+		Ingredients:   &menu.Ingredients,
+		PrepTime:      &menu.PrepTime,
+		IsPopular:     &menu.IsPopular,
+		IsRecommended: &menu.IsRecommended,
+		Calories:      &menu.Calories,
+		Customizable:  &menu.Customizable,
+	}
+	if menu.SKU != nil {
+		item.SKU = *menu.SKU
+	}
+
+	allergens := []string{}
+	if menu.Allergens != "" {
+		json.Unmarshal([]byte(menu.Allergens), &allergens)
+	}
+	item.Allergens = &allergens
+
+	return item
+}
+
+// validateImportedMenu lists what is wrong with a menu item after an import
+// row has been applied to it
+func validateImportedMenu(menu *models.Menu) []string {
+	var errs []string
+	if menu.Name == "" {
+		errs = append(errs, "name is required")
+	}
+	if menu.Category == "" {
+		errs = append(errs, "category is required")
+	} else if models.MenuCategorySlug(menu.Category) == "" {
+		errs = append(errs, "category must contain letters or digits")
+	}
+	if menu.Price <= 0 {
+		errs = append(errs, "price must be greater than 0")
+	}
+	if menu.PrepTime < 0 {
+		errs = append(errs, "prep_time cannot be negative")
+	}
+	if menu.Calories < 0 {
+		errs = append(errs, "calories cannot be negative")
+	}
+	return errs
+}
+
+func createImportedMenu(tx *gorm.DB, menu *models.Menu) error {
+	available := menu.IsAvailable
+	if err := assignMenuCategory(tx, menu); err != nil {
+		return err
+	}
+	if err := tx.Omit(clause.Associations).Create(menu).Error; err != nil {
+		return err
+	}
+	// is_available defaults to true, so an unavailable item needs a second write
+	if !available {
+		menu.IsAvailable = false
+		return tx.Model(&models.Menu{}).Where("id = ?", menu.ID).Update("is_available", false).Error
+	}
+	return nil
+}
+
+// updateImportedMenu saves an imported row over an existing menu item. Like
+// UpdateMenu, a new price or availability on a catalogue item becomes a
+// branch override. Archived items are restored.
+func updateImportedMenu(tx *gorm.DB, current, menu *models.Menu) error {
+	if err := assignMenuCategory(tx, menu); err != nil {
+		return err
+	}
+	updates := map[string]interface{}{
+		"sku":            menu.SKU,
+		"name":           menu.Name,
+		"description":    menu.Description,
+		"category":       menu.Category,
+		"category_id":    menu.CategoryID,
+		"price":          menu.Price,
+		"image_url":      menu.ImageURL,
+		"is_available":   menu.IsAvailable,
+		"ingredients":    menu.Ingredients,
+		"prep_time":      menu.PrepTime,
+		"is_popular":     menu.IsPopular,
+		"is_recommended": menu.IsRecommended,
+		"calories":       menu.Calories,
+		"allergens":      menu.Allergens,
+		"customizable":   menu.Customizable,
+		"deleted_at":     nil,
+	}
+	if current.CatalogItemID != nil {
+		if menu.Price != current.Price {
+			updates["price_overridden"] = true
+		}
+		if menu.IsAvailable != current.IsAvailable {
+			updates["availability_overridden"] = true
+		}
+	}
+
+	return tx.Unscoped().Model(&models.Menu{}).Where("id = ?", menu.ID).Updates(updates).Error
+}
+
+// menuSKUTaken reports whether another menu item of the cafe uses sku
+func menuSKUTaken(db *gorm.DB, cafeID, sku, exceptID string) bool {
+	var count int64
+	db.Unscoped().Model(&models.Menu{}).Where("cafe_id = ? AND sku = ? AND id <> ?", cafeID, sku, exceptID).Count(&count)
+	return count > 0
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"fmt"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type MenuOptionRequest struct {
+	Name        string  `json:"name"`
+	PriceDelta  float64 `json:"price_delta"`
+	IsAvailable *bool   `json:"is_available"`
+	SortOrder   int     `json:"sort_order"`
+}
+
+type CreateOptionGroupRequest struct {
+	Name       string              `json:"name"`
+	IsRequired bool                `json:"is_required"`
+	MinSelect  int                 `json:"min_select"`
+	MaxSelect  int                 `json:"max_select"`
+	SortOrder  int                 `json:"sort_order"`
+	Options    []MenuOptionRequest `json:"options"`
+}
+
+type UpdateOptionGroupRequest struct {
+	Name       *string `json:"name"`
+	IsRequired *bool   `json:"is_required"`
+	MinSelect  *int    `json:"min_select"`
+	MaxSelect  *int    `json:"max_select"`
+	SortOrder  *int    `json:"sort_order"`
+	// Options replaces all options of the group when present
+	Options *[]MenuOptionRequest `json:"options"`
+}
+
+// withMenuOptions preloads option groups and their options in display order
+func withMenuOptions(db *gorm.DB) *gorm.DB {
+	return db.Preload("OptionGroups", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort_order ASC, created_at ASC")
+	}).Preload("OptionGroups.Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort_order ASC, created_at ASC")
+	})
+}
+
+// CreateOptionGroup adds an option group with its options to a menu item
+func (h *MenuHandler) CreateOptionGroup(c *fiber.Ctx) error {
+	menu, ferr := h.ownedMenu(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	var req CreateOptionGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"message": err.Error(),
+		})
+	}
+
+	group := models.MenuOptionGroup{
+		ID:         uuid.New().String(),
+		MenuID:     menu.ID,
+		Name:       req.Name,
+		IsRequired: req.IsRequired,
+		MinSelect:  req.MinSelect,
+		MaxSelect:  req.MaxSelect,
+		SortOrder:  req.SortOrder,
+		Options:    buildMenuOptions(req.Options),
+	}
+	if group.MaxSelect == 0 {
+		group.MaxSelect = 1
+	}
+
+	if ferr := validateOptionGroup(&group); ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		return tx.Model(menu).Update("customizable", true).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to create option group",
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Option group created successfully",
+		"data":    group.ToResponse(),
+	})
+}
+
+// UpdateOptionGroup changes an option group. When options are sent they
+// replace the existing options of the group.
+func (h *MenuHandler) UpdateOptionGroup(c *fiber.Ctx) error {
+	menu, ferr := h.ownedMenu(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	var group models.MenuOptionGroup
+	err := h.db.Preload("Options").First(&group, "id = ? AND menu_id = ?", c.Params("groupId"), menu.ID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Option group not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to fetch option group",
+			"message": err.Error(),
+		})
+	}
+
+	var req UpdateOptionGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"message": err.Error(),
+		})
+	}
+
+	if req.Name != nil {
+		group.Name = *req.Name
+	}
+	if req.IsRequired != nil {
+		group.IsRequired = *req.IsRequired
+	}
+	if req.MinSelect != nil {
+		group.MinSelect = *req.MinSelect
+	}
+	if req.MaxSelect != nil {
+		group.MaxSelect = *req.MaxSelect
+	}
+	if req.SortOrder != nil {
+		group.SortOrder = *req.SortOrder
+	}
+	previous := group.Options
+	if req.Options != nil {
+		group.Options = buildMenuOptions(*req.Options)
+		for i := range group.Options {
+			group.Options[i].GroupID = group.ID
+		}
+	}
+
+	if ferr := validateOptionGroup(&group); ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.MenuOptionGroup{}).Where("id = ?", group.ID).Updates(map[string]interface{}{
+			"name":        group.Name,
+			"is_required": group.IsRequired,
+			"min_select":  group.MinSelect,
+			"max_select":  group.MaxSelect,
+			"sort_order":  group.SortOrder,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if req.Options == nil {
+			return nil
+		}
+		if err := tx.Where("group_id = ?", group.ID).Delete(&models.MenuOption{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&group.Options).Error; err != nil {
+			return err
+		}
+		return carryOverOptionRecipes(tx, previous, group.Options)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to update option group",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Option group updated successfully",
+		"data":    group.ToResponse(),
+	})
+}
+
+// DeleteOptionGroup removes an option group and its options from a menu item
+func (h *MenuHandler) DeleteOptionGroup(c *fiber.Ctx) error {
+	menu, ferr := h.ownedMenu(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND menu_id = ?", c.Params("groupId"), menu.ID).Delete(&models.MenuOptionGroup{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		var options []models.MenuOption
+		if err := tx.Where("group_id = ?", c.Params("groupId")).Find(&options).Error; err != nil {
+			return err
+		}
+		if err := carryOverOptionRecipes(tx, options, nil); err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", c.Params("groupId")).Delete(&models.MenuOption{}).Error; err != nil {
+			return err
+		}
+
+		var remaining int64
+		if err := tx.Model(&models.MenuOptionGroup{}).Where("menu_id = ?", menu.ID).Count(&remaining).Error; err != nil {
+			return err
+		}
+		return tx.Model(menu).Update("customizable", remaining > 0).Error
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Option group not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to delete option group",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Option group deleted successfully",
+	})
+}
+
+// ownedMenu returns the menu from the :id param if it belongs to the cafe the
+// caller acts on
+func (h *MenuHandler) ownedMenu(c *fiber.Ctx) (*models.Menu, *fiber.Error) {
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return nil, ferr
+	}
+
+	var menu models.Menu
+	err := h.db.Scopes(inCafe(cafe.ID)).First(&menu, "id = ?", c.Params("id")).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Menu not found")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch menu")
+	}
+
+	return &menu, nil
+}
+
+func buildMenuOptions(requests []MenuOptionRequest) []models.MenuOption {
+	options := make([]models.MenuOption, 0, len(requests))
+	for i, req := range requests {
+		option := models.MenuOption{
+			ID:          uuid.New().String(),
+			Name:        req.Name,
+			PriceDelta:  req.PriceDelta,
+			IsAvailable: true,
+			SortOrder:   req.SortOrder,
+		}
+		if req.IsAvailable != nil {
+			option.IsAvailable = *req.IsAvailable
+		}
+		if option.SortOrder == 0 {
+			option.SortOrder = i
+		}
+		options = append(options, option)
+	}
+	return options
+}
+
+// validateOptionGroup checks the selection limits against the options. A
+// required group must have at least one selection.
+func validateOptionGroup(group *models.MenuOptionGroup) *fiber.Error {
+	if group.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Option group name is required")
+	}
+	if len(group.Options) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Option group needs at least one option")
+	}
+	for _, option := range group.Options {
+		if option.Name == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Option name is required")
+		}
+	}
+
+	if group.IsRequired && group.MinSelect < 1 {
+		group.MinSelect = 1
+	}
+	if group.MinSelect < 0 || group.MaxSelect < 1 || group.MaxSelect < group.MinSelect {
+		return fiber.NewError(fiber.StatusBadRequest, "min_select must be between 0 and max_select, and max_select at least 1")
+	}
+	if group.MinSelect > len(group.Options) {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("min_select cannot exceed the %d option(s) of the group", len(group.Options)))
+	}
+	if group.MinSelect > 0 {
+		group.IsRequired = true
+	}
+
+	return nil
+}
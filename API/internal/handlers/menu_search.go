@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"siipcoffe-api/internal/database"
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Menu search limits
+const (
+	maxMenuSearchTerms   = 8
+	maxMenuSearchResults = 50
+	// menuSearchCandidates caps the rows ranked in memory without the index
+	menuSearchCandidates = 500
+	menuSnippetRunes     = 120
+)
+
+// The index marks matches with private use characters instead of tags, so
+// the owner's text in the snippet can be escaped before the marks become
+// <mark> tags
+const (
+	menuMatchStart = "\ue000"
+	menuMatchEnd   = "\ue001"
+)
+
+var menuMatchMarks = strings.NewReplacer(menuMatchStart, "<mark>", menuMatchEnd, "</mark>")
+
+// MenuSearchResult is a menu item found by search. Snippet is the best
+// matching text, HTML escaped, with the search terms wrapped in <mark> tags.
+type MenuSearchResult struct {
+	models.MenuResponse
+	Snippet string  `json:"snippet"`
+	Score   float64 `json:"score"`
+}
+
+// MenuSearchCafe is the cafe of a group of search results
+type MenuSearchCafe struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	LogoURL       string  `json:"logo_url"`
+	City          string  `json:"city"`
+	RatingAverage float64 `json:"rating_average"`
+	IsOpen        bool    `json:"is_open"`
+}
+
+// MenuSearchGroup is the search results of one cafe
+type MenuSearchGroup struct {
+	Cafe  MenuSearchCafe     `json:"cafe"`
+	Items []MenuSearchResult `json:"items"`
+}
+
+// menuSearchHit is a ranked match before its menu item is loaded
+type menuSearchHit struct {
+	MenuID  string
+	Score   float64
+	Snippet string
+}
+
+// SearchMenus finds menu items across cafes, best matches first. The FTS5
+// index ranks by where the terms appear, name first; without it the same
+// weighting is applied to LIKE matches. Results come as one list and
+// grouped by cafe, cafes ordered by their best match.
+func (h *MenuHandler) SearchMenus(c *fiber.Ctx) error {
+	query := strings.TrimSpace(c.Query("q"))
+	terms := menuSearchTerms(query)
+	if len(terms) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Search query is required",
+		})
+	}
+
+	filters, ferr := menuSearchFilters(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit < 1 || limit > maxMenuSearchResults {
+		limit = 20
+	}
+
+	var hits []menuSearchHit
+	var err error
+	if database.MenuSearchIndexed(h.db) {
+		hits, err = h.searchMenuIndex(terms, filters, limit)
+	} else {
+		hits, err = h.searchMenuLike(terms, filters, limit)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to search menus",
+			"message": err.Error(),
+		})
+	}
+
+	results, groups, err := h.loadMenuSearchHits(hits)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to search menus",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    results,
+		"cafes":   groups,
+		"count":   len(results),
+		"query":   query,
+	})
+}
+
+// searchMenuIndex ranks matches with the FTS5 index. Every term must match,
+// as a word or the start of one.
+func (h *MenuHandler) searchMenuIndex(terms []string, filters func(*gorm.DB) *gorm.DB, limit int) ([]menuSearchHit, error) {
+	match := make([]string, len(terms))
+	for i, term := range terms {
+		match[i] = `"` + term + `"*`
+	}
+
+	var hits []menuSearchHit
+	err := h.db.Table(database.MenuSearchTable).
+		Select("menu_search.menu_id, -bm25(menu_search, 0, 10, 5, 2, 1) AS score, snippet(menu_search, -1, ?, ?, '…', 12) AS snippet", menuMatchStart, menuMatchEnd).
+		Joins("JOIN menus ON menus.rowid = menu_search.rowid").
+		Where("menu_search MATCH ?", strings.Join(match, " ")).
+		Scopes(filters).
+		Order("score DESC").
+		Limit(limit).
+		Scan(&hits).Error
+	for i := range hits {
+		hits[i].Snippet = menuMatchMarks.Replace(html.EscapeString(hits[i].Snippet))
+	}
+	return hits, err
+}
+
+// searchMenuLike finds menu items containing every term and ranks them the
+// way the index weights its columns
+func (h *MenuHandler) searchMenuLike(terms []string, filters func(*gorm.DB) *gorm.DB, limit int) ([]menuSearchHit, error) {
+	query := h.db.Model(&models.Menu{}).Scopes(filters)
+	for _, term := range terms {
+		pattern := "%" + term + "%"
+		query = query.Where("LOWER(menus.name) LIKE ? OR LOWER(menus.category) LIKE ? OR LOWER(menus.ingredients) LIKE ? OR LOWER(menus.description) LIKE ?",
+			pattern, pattern, pattern, pattern)
+	}
+
+	var menus []models.Menu
+	if err := query.Limit(menuSearchCandidates).Find(&menus).Error; err != nil {
+		return nil, err
+	}
+
+	pattern := menuSearchPattern(terms)
+	hits := make([]menuSearchHit, 0, len(menus))
+	for _, menu := range menus {
+		fields := []struct {
+			text   string
+			weight float64
+		}{
+			{menu.Name, 10}, {menu.Category, 5}, {menu.Ingredients, 2}, {menu.Description, 1},
+		}
+
+		hit := menuSearchHit{MenuID: menu.ID}
+		for _, field := range fields {
+			matches := len(pattern.FindAllStringIndex(field.text, -1))
+			if matches == 0 {
+				continue
+			}
+			hit.Score += field.weight * float64(matches)
+			if hit.Snippet == "" {
+				hit.Snippet = menuSnippet(field.text, pattern)
+			}
+		}
+		hits = append(hits, hit)
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+// loadMenuSearchHits loads the menu items and cafes of ranked hits
+func (h *MenuHandler) loadMenuSearchHits(hits []menuSearchHit) ([]MenuSearchResult, []MenuSearchGroup, error) {
+	results := []MenuSearchResult{}
+	groups := []MenuSearchGroup{}
+	if len(hits) == 0 {
+		return results, groups, nil
+	}
+
+	menuIDs := make([]string, len(hits))
+	for i, hit := range hits {
+		menuIDs[i] = hit.MenuID
+	}
+	var menus []models.Menu
+	if err := h.db.Scopes(withMenuOptions).Where("id IN ?", menuIDs).Find(&menus).Error; err != nil {
+		return nil, nil, err
+	}
+	menusByID := make(map[string]*models.Menu, len(menus))
+	var cafeIDs []string
+	for i := range menus {
+		menusByID[menus[i].ID] = &menus[i]
+		cafeIDs = append(cafeIDs, menus[i].CafeID)
+	}
+
+	var cafes []models.Cafe
+	if err := h.db.Where("id IN ?", cafeIDs).Find(&cafes).Error; err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	groupIndex := make(map[string]int, len(cafes))
+	cafesByID := make(map[string]*models.Cafe, len(cafes))
+	for i := range cafes {
+		cafesByID[cafes[i].ID] = &cafes[i]
+	}
+
+	for _, hit := range hits {
+		menu := menusByID[hit.MenuID]
+		if menu == nil {
+			continue
+		}
+		cafe := cafesByID[menu.CafeID]
+		if cafe == nil {
+			continue
+		}
+
+		result := MenuSearchResult{MenuResponse: menu.ToResponse(), Snippet: hit.Snippet, Score: hit.Score}
+		results = append(results, result)
+
+		i, ok := groupIndex[cafe.ID]
+		if !ok {
+			i = len(groups)
+			groupIndex[cafe.ID] = i
+			groups = append(groups, MenuSearchGroup{
+				Cafe: MenuSearchCafe{
+					ID:            cafe.ID,
+					Name:          cafe.Name,
+					LogoURL:       cafe.LogoURL,
+					City:          cafe.City,
+					RatingAverage: cafe.RatingAverage,
+					IsOpen:        cafe.IsOpenAt(now),
+				},
+			})
+		}
+		groups[i].Items = append(groups[i].Items, result)
+	}
+	return results, groups, nil
+}
+
+// menuSearchFilters builds the filters of a search from its query parameters
+func menuSearchFilters(c *fiber.Ctx) (func(*gorm.DB) *gorm.DB, *fiber.Error) {
+	var prices [2]*float64
+	for i, name := range []string{"min_price", "max_price"} {
+		if value := c.Query(name); value != "" {
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil || price < 0 {
+				return nil, fiber.NewError(fiber.StatusBadRequest, name+" must be a non-negative number")
+			}
+			prices[i] = &price
+		}
+	}
+
+	var maxCalories *int
+	if value := c.Query("max_calories"); value != "" {
+		calories, err := strconv.Atoi(value)
+		if err != nil || calories < 0 {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "max_calories must be a non-negative whole number")
+		}
+		maxCalories = &calories
+	}
+
+	available := true
+	anyAvailability := false
+	switch value := c.Query("available", "true"); value {
+	case "any":
+		anyAvailability = true
+	default:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "available must be true, false or any")
+		}
+		available = parsed
+	}
+
+	var excluded []string
+	for _, allergen := range strings.Split(c.Query("exclude_allergens"), ",") {
+		if allergen = strings.ToLower(strings.TrimSpace(allergen)); allergen != "" {
+			excluded = append(excluded, allergen)
+		}
+	}
+
+	cafeID := c.Query("cafe_id")
+	category := c.Query("category")
+
+	return func(db *gorm.DB) *gorm.DB {
+		suspended := db.Session(&gorm.Session{NewDB: true}).Model(&models.Cafe{}).Select("id").Where("status = ?", models.CafeStatusSuspended)
+		inactive := db.Session(&gorm.Session{NewDB: true}).Model(&models.MenuCategory{}).Select("id").Where("is_active = ?", false)
+		db = db.Where("menus.deleted_at IS NULL AND menus.cafe_id NOT IN (?)", suspended).
+			Where("menus.category_id IS NULL OR menus.category_id NOT IN (?)", inactive)
+
+		if cafeID != "" {
+			db = db.Where("menus.cafe_id = ?", cafeID)
+		}
+		if category != "" {
+			db = db.Where("menus.category = ? OR menus.category_id = ?", category, category)
+		}
+		if prices[0] != nil {
+			db = db.Where("menus.price >= ?", *prices[0])
+		}
+		if prices[1] != nil {
+			db = db.Where("menus.price <= ?", *prices[1])
+		}
+		if maxCalories != nil {
+			db = db.Where("menus.calories <= ?", *maxCalories)
+		}
+		if !anyAvailability {
+			db = db.Where("menus.is_available = ?", available)
+		}
+		for _, allergen := range excluded {
+			db = db.Where("LOWER(menus.allergens) NOT LIKE ?", `%"`+allergen+`"%`)
+		}
+		return db
+	}, nil
+}
+
+// menuSearchTerms splits a search query into lowercase words
+func menuSearchTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxMenuSearchTerms {
+		words = words[:maxMenuSearchTerms]
+	}
+	return words
+}
+
+// menuSearchPattern matches any of the terms, ignoring case
+func menuSearchPattern(terms []string) *regexp.Regexp {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+}
+
+// menuSnippet highlights the matches in text, cut down to the part around
+// the first match when it is long
+func menuSnippet(text string, pattern *regexp.Regexp) string {
+	runes := []rune(text)
+	if len(runes) > menuSnippetRunes {
+		first := pattern.FindStringIndex(text)
+		start := 0
+		if first != nil {
+			start = len([]rune(text[:first[0]])) - menuSnippetRunes/4
+		}
+		if start < 0 {
+			start = 0
+		}
+		end := start + menuSnippetRunes
+		if end > len(runes) {
+			end = len(runes)
+		}
+		text = string(runes[start:end])
+		if start > 0 {
+			text = "…" + text
+		}
+		if end < len(runes) {
+			text += "…"
+		}
+	}
+
+	var snippet strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringIndex(text, -1) {
+		snippet.WriteString(html.EscapeString(text[last:match[0]]))
+		snippet.WriteString("<mark>" + html.EscapeString(text[match[0]:match[1]]) + "</mark>")
+		last = match[1]
+	}
+	snippet.WriteString(html.EscapeString(text[last:]))
+	return snippet.String()
+}
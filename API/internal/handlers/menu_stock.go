@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// refreshSoldOut recomputes the sold out flag of every menu item whose base
+// recipe uses one of the inventory items. Call it whenever their stock changes.
+func refreshSoldOut(tx *gorm.DB, inventoryIDs ...string) error {
+	if len(inventoryIDs) == 0 {
+		return nil
+	}
+
+	var menuIDs []string
+	err := tx.Model(&models.RecipeItem{}).
+		Distinct().
+		Where("inventory_id IN ? AND option_id IS NULL", inventoryIDs).
+		Pluck("menu_id", &menuIDs).Error
+	if err != nil {
+		return err
+	}
+
+	return refreshMenusSoldOut(tx, menuIDs...)
+}
+
+// refreshMenusSoldOut marks menu items sold out when an ingredient of their
+// base recipe cannot cover one more portion, and clears the mark once stock is
+// back. Options are checked when ordering, see checkMenuStock.
+func refreshMenusSoldOut(tx *gorm.DB, menuIDs ...string) error {
+	if len(menuIDs) == 0 {
+		return nil
+	}
+
+	var lines []models.RecipeItem
+	err := tx.Preload("Inventory").
+		Where("menu_id IN ? AND option_id IS NULL", menuIDs).
+		Find(&lines).Error
+	if err != nil {
+		return err
+	}
+
+	recipes := make(map[string][]models.RecipeItem, len(menuIDs))
+	for _, line := range lines {
+		recipes[line.MenuID] = append(recipes[line.MenuID], line)
+	}
+
+	for _, menuID := range menuIDs {
+		reason := soldOutReason(models.ShortIngredients(recipes[menuID], nil))
+		err := tx.Model(&models.Menu{}).Where("id = ?", menuID).Updates(map[string]interface{}{
+			"sold_out":        reason != "",
+			"sold_out_reason": reason,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkMenuStock rejects an order item whose menu is sold out or whose chosen
+// options need an ingredient that has run out
+func checkMenuStock(db *gorm.DB, menu *models.Menu, options []models.OrderItemOption) *fiber.Error {
+	if menu.SoldOut {
+		return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("%s is sold out (%s)", menu.Name, menu.SoldOutReason))
+	}
+	if len(options) == 0 {
+		return nil
+	}
+
+	optionIDs := make([]string, 0, len(options))
+	for _, option := range options {
+		optionIDs = append(optionIDs, option.OptionID)
+	}
+
+	var recipe []models.RecipeItem
+	if err := db.Preload("Inventory").Where("menu_id = ?", menu.ID).Find(&recipe).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check stock")
+	}
+	if short := models.ShortIngredients(recipe, optionIDs); len(short) > 0 {
+		return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("%s with these options is sold out (%s)", menu.Name, soldOutReason(short)))
+	}
+
+	return nil
+}
+
+// soldOutReason names the ingredients that ran out, e.g. "Out of Fresh Milk"
+func soldOutReason(short []models.Inventory) string {
+	if len(short) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(short))
+	for _, inventory := range short {
+		names = append(names, inventory.Name)
+	}
+	return "Out of " + strings.Join(names, ", ")
+}
@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"siipcoffe-api/internal/config"
 	"siipcoffe-api/internal/models"
 	"siipcoffe-api/pkg/gemini"
+	"siipcoffe-api/pkg/pricing"
+	"siipcoffe-api/pkg/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -17,13 +20,15 @@ type OrderHandler struct {
 	db           *gorm.DB
 	geminiClient *gemini.Client
 	cfg          *config.Config
+	events       *OrderEventHub
 }
 
-func NewOrderHandler(db *gorm.DB, geminiClient *gemini.Client, cfg *config.Config) *OrderHandler {
+func NewOrderHandler(db *gorm.DB, geminiClient *gemini.Client, cfg *config.Config, events *OrderEventHub) *OrderHandler {
 	return &OrderHandler{
 		db:           db,
 		geminiClient: geminiClient,
 		cfg:          cfg,
+		events:       events,
 	}
 }
 
@@ -34,14 +39,25 @@ type CreateOrderRequest struct {
 	CustomerPhone  string             `json:"customer_phone"`
 	TableNumber    string             `json:"table_number"`
 	DeliveryAddress string            `json:"delivery_address"`
+	DeliveryLat    *float64           `json:"delivery_lat"` // required for delivery orders
+	DeliveryLng    *float64           `json:"delivery_lng"`
 	Notes          string             `json:"notes"`
 	PaymentMethod  string             `json:"payment_method" validate:"required,oneof=crypto cash transfer"`
+	ScheduledFor   *time.Time         `json:"scheduled_for"` // optional pickup time for pre-orders
+}
+
+type QuoteOrderRequest struct {
+	Items       []OrderItemRequest `json:"items" validate:"required"`
+	OrderType   string             `json:"order_type" validate:"required,oneof=dine_in take_away delivery"`
+	DeliveryLat *float64           `json:"delivery_lat"` // optional, prices delivery by distance
+	DeliveryLng *float64           `json:"delivery_lng"`
 }
 
 type OrderItemRequest struct {
 	MenuID  string  `json:"menu_id" validate:"required"`
 	Quantity int    `json:"quantity" validate:"required,min=1"`
 	Notes   string  `json:"notes"`
+	Options []string `json:"options"` // IDs of the chosen menu options
 }
 
 func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
@@ -62,12 +78,93 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 		})
 	}
 
+	// Resolve menu items, cafe and price breakdown
+	cafe, orderItems, ferr := h.resolveCart(h.db, req.Items)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+	if !cafe.AcceptsOrderType(req.OrderType) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "This cafe does not take " + req.OrderType + " orders",
+			"accepted_order_types": cafe.Ordering().OrderTypes,
+		})
+	}
+
+	// Categories with serving hours are checked at the pickup time of pre-orders
+	servedAt := time.Now()
+	if req.ScheduledFor != nil {
+		servedAt = *req.ScheduledFor
+	}
+	if ferr := checkMenuCategories(h.db, cafe, orderItems, servedAt); ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	// Dine-in orders name one of the cafe's tables
+	var table *models.CafeTable
+	if req.OrderType == "dine_in" && req.TableNumber != "" {
+		if table, ferr = resolveTable(h.db, cafe.ID, req.TableNumber); ferr != nil {
+			return c.Status(ferr.Code).JSON(fiber.Map{
+				"error": ferr.Message,
+			})
+		}
+	}
+
+	// Delivery orders need a location inside the cafe's delivery area
+	var deliveryPoint *utils.Point
+	var deliveryDistance float64
+	if req.OrderType == "delivery" {
+		if req.DeliveryAddress == "" || req.DeliveryLat == nil || req.DeliveryLng == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "delivery_address, delivery_lat and delivery_lng are required for delivery orders",
+			})
+		}
+		deliveryPoint, deliveryDistance, ferr = resolveDeliveryPoint(cafe, req.DeliveryLat, req.DeliveryLng)
+		if ferr != nil {
+			return c.Status(ferr.Code).JSON(fiber.Map{
+				"error": ferr.Message,
+			})
+		}
+	}
+
+	breakdown, err := pricing.Calculate(cafe, sumItemTotals(orderItems), req.OrderType, deliveryDistance)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Order does not meet the minimum order amount",
+			"message": err.Error(),
+			"data": breakdown,
+		})
+	}
+
 	// Start transaction
 	tx := h.db.Begin()
 
+	var scheduledFor *time.Time
+	if req.ScheduledFor != nil {
+		pickup := req.ScheduledFor.UTC().Truncate(time.Minute)
+		if ferr := validateScheduledPickup(tx, cafe, orderItems, pickup, time.Now()); ferr != nil {
+			tx.Rollback()
+			return c.Status(ferr.Code).JSON(fiber.Map{
+				"error": ferr.Message,
+			})
+		}
+		scheduledFor = &pickup
+	} else if now := time.Now(); !cafe.IsOpenAt(now) {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Cafe is currently closed",
+			"message": "Schedule the order for a pickup time when the cafe is open",
+			"next_open_at": cafe.NextStatusChange(now),
+		})
+	}
+
 	// Create order
 	order := models.Order{
 		ID:             uuid.New().String(),
+		CafeID:         cafe.ID,
 		UserID:         userID,
 		OrderNumber:    h.generateOrderNumber(),
 		Status:         string(models.OrderStatusPending),
@@ -78,47 +175,23 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 		OrderType:      req.OrderType,
 		TableNumber:    req.TableNumber,
 		DeliveryAddress: req.DeliveryAddress,
+		ScheduledFor:   scheduledFor,
 		Notes:          req.Notes,
 	}
-
-	var totalAmount float64
-	var orderItems []models.OrderItem
-
-	// Process each item
-	for _, itemReq := range req.Items {
-		// Get menu item
-		var menu models.Menu
-		err := tx.First(&menu, "id = ? AND is_available = ?", itemReq.MenuID, true).Error
-		if err != nil {
-			tx.Rollback()
-			if err == gorm.ErrRecordNotFound {
-				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-					"error": fmt.Sprintf("Menu item %s not found or unavailable", itemReq.MenuID),
-				})
-			}
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to fetch menu item",
-				"message": err.Error(),
-			})
-		}
-
-		// Create order item
-		itemTotal := float64(itemReq.Quantity) * menu.Price
-		orderItem := models.OrderItem{
-			ID:         uuid.New().String(),
-			OrderID:    order.ID,
-			MenuID:     menu.ID,
-			Quantity:   itemReq.Quantity,
-			UnitPrice:  menu.Price,
-			TotalPrice: itemTotal,
-			Notes:      itemReq.Notes,
-		}
-
-		orderItems = append(orderItems, orderItem)
-		totalAmount += itemTotal
+	if table != nil {
+		order.TableNumber = table.Number
+		order.TableID = &table.ID
+	}
+	if deliveryPoint != nil {
+		order.DeliveryLat = deliveryPoint.Lat
+		order.DeliveryLng = deliveryPoint.Lng
+		order.DeliveryDistanceKm = deliveryDistance
 	}
+	breakdown.Apply(&order)
 
-	order.TotalAmount = totalAmount
+	for i := range orderItems {
+		orderItems[i].OrderID = order.ID
+	}
 
 	// Save order
 	if err := tx.Create(&order).Error; err != nil {
@@ -131,7 +204,7 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 
 	// Save order items
 	for _, item := range orderItems {
-		if err := tx.Create(&item).Error; err != nil {
+		if err := tx.Omit("Menu").Create(&item).Error; err != nil {
 			tx.Rollback()
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to create order item",
@@ -140,6 +213,15 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 		}
 	}
 
+	// Record the initial status in the order timeline
+	if err := recordOrderStatus(tx, order.ID, "", order.Status, statusActor{ID: userID, Role: c.Locals("user_role").(string)}, "Order placed"); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record order status",
+			"message": err.Error(),
+		})
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -149,7 +231,7 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 	}
 
 	// Load order with relations for response
-	if err := h.db.Preload("OrderItems.Menu").Preload("User").First(&order, "id = ?", order.ID).Error; err != nil {
+	if err := h.db.Preload("OrderItems.Menu").Preload("OrderItems.Options").Preload("User").Preload("StatusHistory", orderTimeline).First(&order, "id = ?", order.ID).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to load order details",
 			"message": err.Error(),
@@ -163,6 +245,206 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 	})
 }
 
+// QuoteOrder returns the price breakdown for a cart without placing the order
+func (h *OrderHandler) QuoteOrder(c *fiber.Ctx) error {
+	var req QuoteOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+			"message": err.Error(),
+		})
+	}
+
+	if len(req.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one item is required",
+		})
+	}
+
+	cafe, orderItems, ferr := h.resolveCart(h.db, req.Items)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+	if !cafe.AcceptsOrderType(req.OrderType) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "This cafe does not take " + req.OrderType + " orders",
+			"accepted_order_types": cafe.Ordering().OrderTypes,
+		})
+	}
+
+	items := make([]fiber.Map, 0, len(orderItems))
+	for _, item := range orderItems {
+		items = append(items, fiber.Map{
+			"menu_id":     item.MenuID,
+			"name":        item.Menu.Name,
+			"quantity":    item.Quantity,
+			"unit_price":  item.UnitPrice,
+			"total_price": item.TotalPrice,
+			"notes":       item.Notes,
+		})
+	}
+
+	var deliveryDistance float64
+	if req.OrderType == "delivery" {
+		if _, deliveryDistance, ferr = resolveDeliveryPoint(cafe, req.DeliveryLat, req.DeliveryLng); ferr != nil {
+			return c.Status(ferr.Code).JSON(fiber.Map{
+				"error": ferr.Message,
+			})
+		}
+	}
+
+	breakdown, err := pricing.Calculate(cafe, sumItemTotals(orderItems), req.OrderType, deliveryDistance)
+	meetsMinimum := err == nil
+	if err != nil && !errors.Is(err, pricing.ErrBelowMinimumOrder) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate price",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"cafe_id":       cafe.ID,
+			"order_type":    req.OrderType,
+			"items":         items,
+			"breakdown":     breakdown,
+			"meets_minimum": meetsMinimum,
+		},
+	})
+}
+
+// resolveCart loads the ordered menu items, checks they all belong to the same
+// active cafe and builds unsaved order items priced from the menu.
+func (h *OrderHandler) resolveCart(db *gorm.DB, items []OrderItemRequest) (*models.Cafe, []models.OrderItem, *fiber.Error) {
+	var cafeID string
+	var orderItems []models.OrderItem
+
+	for _, itemReq := range items {
+		if itemReq.Quantity < 1 {
+			return nil, nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Quantity for menu item %s must be at least 1", itemReq.MenuID))
+		}
+
+		var menu models.Menu
+		err := db.Scopes(withMenuOptions).First(&menu, "id = ? AND is_available = ?", itemReq.MenuID, true).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, nil, fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("Menu item %s not found or unavailable", itemReq.MenuID))
+			}
+			return nil, nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch menu item")
+		}
+
+		if cafeID == "" {
+			cafeID = menu.CafeID
+		} else if menu.CafeID != cafeID {
+			return nil, nil, fiber.NewError(fiber.StatusBadRequest, "All items in an order must come from the same cafe")
+		}
+
+		options, ferr := selectMenuOptions(&menu, itemReq.Options)
+		if ferr != nil {
+			return nil, nil, ferr
+		}
+		if ferr := checkMenuStock(db, &menu, options); ferr != nil {
+			return nil, nil, ferr
+		}
+
+		unitPrice := menu.Price
+		for _, option := range options {
+			unitPrice += option.PriceDelta
+		}
+		if unitPrice < 0 {
+			unitPrice = 0
+		}
+
+		orderItems = append(orderItems, models.OrderItem{
+			ID:         uuid.New().String(),
+			MenuID:     menu.ID,
+			Quantity:   itemReq.Quantity,
+			UnitPrice:  unitPrice,
+			TotalPrice: pricing.Round(float64(itemReq.Quantity) * unitPrice),
+			Notes:      itemReq.Notes,
+			Menu:       menu,
+			Options:    options,
+		})
+	}
+
+	var cafe models.Cafe
+	err := db.Where("id = ?", cafeID).First(&cafe).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, fiber.NewError(fiber.StatusNotFound, "Cafe not found")
+		}
+		return nil, nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch cafe")
+	}
+	if cafe.Status == models.CafeStatusSuspended {
+		return nil, nil, fiber.NewError(fiber.StatusForbidden, "This cafe is suspended and cannot take orders")
+	}
+	if cafe.Status != models.CafeStatusActive {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, "Cafe not found")
+	}
+
+	return &cafe, orderItems, nil
+}
+
+// selectMenuOptions checks the chosen option IDs against the menu's option
+// groups and returns them as unsaved order item options. Every group must get
+// between its minimum and maximum number of selections.
+func selectMenuOptions(menu *models.Menu, optionIDs []string) ([]models.OrderItemOption, *fiber.Error) {
+	chosen := make(map[string]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		if chosen[id] {
+			return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Option %s was chosen more than once for %s", id, menu.Name))
+		}
+		chosen[id] = true
+	}
+
+	var selected []models.OrderItemOption
+	for _, group := range menu.OptionGroups {
+		count := 0
+		for _, option := range group.Options {
+			if !chosen[option.ID] {
+				continue
+			}
+			if !option.IsAvailable {
+				return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%s is currently unavailable for %s", option.Name, menu.Name))
+			}
+			delete(chosen, option.ID)
+			count++
+			selected = append(selected, models.OrderItemOption{
+				ID:         uuid.New().String(),
+				OptionID:   option.ID,
+				GroupName:  group.Name,
+				OptionName: option.Name,
+				PriceDelta: option.PriceDelta,
+			})
+		}
+
+		if count < group.MinRequired() {
+			return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Choose at least %d %s for %s", group.MinRequired(), group.Name, menu.Name))
+		}
+		if count > group.MaxSelect {
+			return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Choose at most %d %s for %s", group.MaxSelect, group.Name, menu.Name))
+		}
+	}
+
+	// Anything left over does not belong to this menu item
+	for id := range chosen {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Option %s is not available for %s", id, menu.Name))
+	}
+
+	return selected, nil
+}
+
+func sumItemTotals(items []models.OrderItem) float64 {
+	var subtotal float64
+	for _, item := range items {
+		subtotal += item.TotalPrice
+	}
+	return subtotal
+}
+
 func (h *OrderHandler) GetUserOrders(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(string)
 
@@ -174,7 +456,7 @@ func (h *OrderHandler) GetUserOrders(c *fiber.Ctx) error {
 	var orders []models.Order
 	var total int64
 
-	query := h.db.Where("user_id = ?", userID).Preload("OrderItems.Menu").Preload("User")
+	query := h.db.Where("user_id = ?", userID).Preload("OrderItems.Menu").Preload("OrderItems.Options").Preload("User")
 
 	// Filter by status if provided
 	if status != "" {
@@ -211,22 +493,64 @@ func (h *OrderHandler) GetUserOrders(c *fiber.Ctx) error {
 	})
 }
 
-func (h *OrderHandler) GetOrderByID(c *fiber.Ctx) error {
-	userID := c.Locals("user_id").(string)
-	userRole := c.Locals("user_role").(string)
-	orderID := c.Params("id")
+// GetCafeOrders lists the orders of the cafe the caller owns or works for
+func (h *OrderHandler) GetCafeOrders(c *fiber.Ctx) error {
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
 
-	var order models.Order
-	query := h.db.Preload("OrderItems.Menu").Preload("User").Preload("Payment")
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+	status := c.Query("status")
+
+	var orders []models.Order
+	var total int64
+
+	query := h.db.Scopes(inCafe(cafe.ID)).Preload("OrderItems.Menu").Preload("OrderItems.Options").Preload("User")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
 
-	// Owners can see any order, customers can only see their own orders
-	if userRole != "owner" {
-		query = query.Where("id = ? AND user_id = ?", orderID, userID)
-	} else {
-		query = query.Where("id = ?", orderID)
+	query.Model(&models.Order{}).Count(&total)
+
+	offset := (page - 1) * limit
+	err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&orders).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch orders",
+			"message": err.Error(),
+		})
 	}
 
-	err := query.First(&order).Error
+	response := make([]models.OrderResponse, 0, len(orders))
+	for _, order := range orders {
+		response = append(response, order.ToResponse())
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": response,
+		"pagination": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (h *OrderHandler) GetOrderByID(c *fiber.Ctx) error {
+	orderID := c.Params("id")
+
+	// Owners see the orders of their own cafes, staff the orders of the cafe
+	// they work for, customers only their own orders
+	var order models.Order
+	err := h.db.Preload("OrderItems.Menu").Preload("OrderItems.Options").Preload("User").Preload("Payment").Preload("StatusHistory", orderTimeline).
+		Scopes(visibleOrders(c)).
+		Where("id = ?", orderID).
+		First(&order).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -247,9 +571,15 @@ func (h *OrderHandler) GetOrderByID(c *fiber.Ctx) error {
 
 func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 	orderID := c.Params("id")
+	actor := statusActor{
+		ID:   c.Locals("user_id").(string),
+		Role: actingRole(c),
+	}
 
 	var req struct {
-		Status string `json:"status" validate:"required,oneof=pending confirmed preparing ready completed cancelled"`
+		Status        string `json:"status" validate:"omitempty,oneof=pending confirmed preparing ready completed cancelled"`
+		Reason        string `json:"reason"`
+		EstimatedTime *int   `json:"estimated_time"` // minutes until the order is ready
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -259,9 +589,35 @@ func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 		})
 	}
 
+	if req.Status == "" && req.EstimatedTime == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Status or estimated_time is required",
+		})
+	}
+
+	if req.Status != "" && !models.IsValidOrderStatus(req.Status) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order status",
+			"status": req.Status,
+		})
+	}
+
+	if req.EstimatedTime != nil && *req.EstimatedTime < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Estimated time cannot be negative",
+		})
+	}
+
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
 	// Get order
 	var order models.Order
-	err := h.db.First(&order, "id = ?", orderID).Error
+	err := h.db.Scopes(inCafe(cafe.ID)).First(&order, "id = ?", orderID).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -274,19 +630,30 @@ func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update status
-	updates := map[string]interface{}{
-		"status": req.Status,
-	}
-
-	// Set completed_at if order is completed
-	if req.Status == string(models.OrderStatusCompleted) {
-		now := time.Now()
-		updates["completed_at"] = &now
-	}
-
-	err = h.db.Model(&order).Updates(updates).Error
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if req.Status != "" {
+			if err := changeOrderStatus(tx, &order, models.OrderStatus(req.Status), actor, req.Reason); err != nil {
+				return err
+			}
+		}
+		if req.EstimatedTime != nil && *req.EstimatedTime != order.EstimatedTime {
+			return updateOrderETA(tx, &order, *req.EstimatedTime)
+		}
+		return nil
+	})
 	if err != nil {
+		if errors.Is(err, errInvalidStatusTransition) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": fmt.Sprintf("Cannot change order status from %s to %s", order.Status, req.Status),
+				"current_status": order.Status,
+				"allowed_statuses": models.OrderStatus(order.Status).NextStatuses(),
+			})
+		}
+		if errors.Is(err, errStatusChanged) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Order status was changed by someone else, please reload the order",
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update order status",
 			"message": err.Error(),
@@ -294,7 +661,7 @@ func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 	}
 
 	// Refresh order data
-	h.db.Preload("OrderItems.Menu").Preload("User").First(&order, "id = ?", orderID)
+	h.db.Preload("OrderItems.Menu").Preload("OrderItems.Options").Preload("User").Preload("StatusHistory", orderTimeline).First(&order, "id = ?", orderID)
 
 	return c.JSON(fiber.Map{
 		"success": true,
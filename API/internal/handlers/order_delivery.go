@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+
+	"siipcoffe-api/internal/models"
+	"siipcoffe-api/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// resolveDeliveryPoint checks delivery coordinates against the cafe's
+// delivery area and returns the point with its distance from the cafe in km.
+// It returns a nil point when no coordinates were given.
+func resolveDeliveryPoint(cafe *models.Cafe, lat, lng *float64) (*utils.Point, float64, *fiber.Error) {
+	if lat == nil && lng == nil {
+		return nil, 0, nil
+	}
+	if lat == nil || lng == nil {
+		return nil, 0, fiber.NewError(fiber.StatusBadRequest, "delivery_lat and delivery_lng must be given together")
+	}
+	if *lat < -90 || *lat > 90 || *lng < -180 || *lng > 180 {
+		return nil, 0, fiber.NewError(fiber.StatusBadRequest, "Delivery coordinates are out of range")
+	}
+	if cafe.CoordinateLat == 0 && cafe.CoordinateLng == 0 {
+		return nil, 0, fiber.NewError(fiber.StatusBadRequest, "This cafe has not set its location and cannot deliver yet")
+	}
+
+	point := utils.Point{Lat: *lat, Lng: *lng}
+	distance := math.Round(cafe.DeliveryDistance(point)*100) / 100
+
+	if _, ok := cafe.DeliveryZoneFor(point); !ok {
+		return nil, 0, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("The delivery address is %.1f km from the cafe, outside its delivery area", distance))
+	}
+
+	return &point, distance, nil
+}
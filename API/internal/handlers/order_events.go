@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	// eventPollInterval is how often the event hub checks for new events
+	eventPollInterval = 2 * time.Second
+	// eventHeartbeatInterval keeps idle connections open through proxies
+	eventHeartbeatInterval = 15 * time.Second
+	// eventBatchSize limits how many events are read per query
+	eventBatchSize = 100
+	// eventGapTimeout is how long a skipped event ID is waited for. Event IDs
+	// are assigned on insert, so a transaction that commits late shows up
+	// behind events with higher IDs; one that rolls back leaves a gap for good.
+	eventGapTimeout = 30 * time.Second
+	// eventMaxGap bounds how many skipped IDs are tracked per poll
+	eventMaxGap = 1000
+	// eventSubscriberBuffer is how many events a slow stream may fall behind
+	// before it is closed; the client then resumes from its last event ID
+	eventSubscriberBuffer = 256
+)
+
+// eventFilter selects the order events a stream receives
+type eventFilter struct {
+	UserID  string
+	OrderID string
+	CafeID  string
+}
+
+// scope applies the filter to an order events query
+func (f eventFilter) scope(q *gorm.DB) *gorm.DB {
+	if f.UserID != "" {
+		q = q.Where("user_id = ?", f.UserID)
+	}
+	if f.OrderID != "" {
+		q = q.Where("order_id = ?", f.OrderID)
+	}
+	if f.CafeID != "" {
+		q = q.Where("cafe_id = ?", f.CafeID)
+	}
+	return q
+}
+
+// match reports whether an event passes the filter
+func (f eventFilter) match(event *models.OrderEvent) bool {
+	return (f.UserID == "" || event.UserID == f.UserID) &&
+		(f.OrderID == "" || event.OrderID == f.OrderID) &&
+		(f.CafeID == "" || event.CafeID == f.CafeID)
+}
+
+// eventSubscriber is an open stream waiting for events
+type eventSubscriber struct {
+	filter eventFilter
+	events chan models.OrderEvent
+}
+
+// OrderEventHub polls for new order events once for the whole process and
+// hands them to the open streams. Its cursor remembers skipped event IDs for
+// a while, so events committed out of ID order are still delivered.
+type OrderEventHub struct {
+	db       *gorm.DB
+	interval time.Duration
+	start    sync.Once
+
+	mu          sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+	lastID      uint64               // highest event ID seen
+	gaps        map[uint64]time.Time // skipped IDs that may still commit, by when they were noticed
+	idle        bool                 // no subscribers, the cursor is reset on the next one
+}
+
+func NewOrderEventHub(db *gorm.DB) *OrderEventHub {
+	return &OrderEventHub{
+		db:          quietDB(db),
+		interval:    eventPollInterval,
+		subscribers: make(map[*eventSubscriber]struct{}),
+		gaps:        make(map[uint64]time.Time),
+		idle:        true,
+	}
+}
+
+// subscribe registers a stream for the events committed from now on. The
+// poller starts with the first subscriber.
+func (h *OrderEventHub) subscribe(filter eventFilter) (*eventSubscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.idle {
+		var lastID uint64
+		if err := h.db.Model(&models.OrderEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&lastID).Error; err != nil {
+			return nil, err
+		}
+		h.lastID = lastID
+		h.gaps = make(map[uint64]time.Time)
+		h.idle = false
+	}
+
+	sub := &eventSubscriber{
+		filter: filter,
+		events: make(chan models.OrderEvent, eventSubscriberBuffer),
+	}
+	h.subscribers[sub] = struct{}{}
+
+	h.start.Do(func() {
+		go h.run()
+	})
+	return sub, nil
+}
+
+// unsubscribe removes a stream and closes its channel
+func (h *OrderEventHub) unsubscribe(sub *eventSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+func (h *OrderEventHub) remove(sub *eventSubscriber) {
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (h *OrderEventHub) run() {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := h.poll(); err != nil {
+			log.Printf("Order event poll failed: %v", err)
+		}
+	}
+}
+
+// poll reads the events after the cursor and the skipped IDs that have
+// committed since, and hands them to the matching subscribers
+func (h *OrderEventHub) poll() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.subscribers) == 0 {
+		h.idle = true
+		return nil
+	}
+
+	now := time.Now()
+	for id, noticed := range h.gaps {
+		if now.Sub(noticed) > eventGapTimeout {
+			delete(h.gaps, id)
+		}
+	}
+
+	for {
+		query := h.db.Where("id > ?", h.lastID)
+		if len(h.gaps) > 0 {
+			gaps := make([]uint64, 0, len(h.gaps))
+			for id := range h.gaps {
+				gaps = append(gaps, id)
+			}
+			query = query.Or("id IN ?", gaps)
+		}
+
+		var events []models.OrderEvent
+		if err := query.Order("id ASC").Limit(eventBatchSize).Find(&events).Error; err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if event.ID > h.lastID {
+				if event.ID-h.lastID <= eventMaxGap {
+					for id := h.lastID + 1; id < event.ID; id++ {
+						h.gaps[id] = now
+					}
+				}
+				h.lastID = event.ID
+			} else {
+				delete(h.gaps, event.ID)
+			}
+			h.dispatch(event)
+		}
+
+		if len(events) < eventBatchSize {
+			return nil
+		}
+	}
+}
+
+// dispatch hands an event to the matching subscribers. A subscriber that
+// cannot keep up is dropped rather than holding up everyone else.
+func (h *OrderEventHub) dispatch(event models.OrderEvent) {
+	for sub := range h.subscribers {
+		if !sub.filter.match(&event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// publishOrderEvent stores an event for live order streams. It runs inside
+// the caller's transaction so the event only exists if the change is committed.
+func publishOrderEvent(tx *gorm.DB, orderID, eventType string, data fiber.Map) error {
+	var order models.Order
+	err := tx.Select("id", "user_id", "cafe_id", "order_number").First(&order, "id = ?", orderID).Error
+	if err != nil {
+		return err
+	}
+
+	data["order_id"] = order.ID
+	data["order_number"] = order.OrderNumber
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	event := models.OrderEvent{
+		OrderID: order.ID,
+		UserID:  order.UserID,
+		CafeID:  order.CafeID,
+		Type:    eventType,
+		Data:    string(payload),
+	}
+
+	return tx.Create(&event).Error
+}
+
+// eventStream streams order events matching the filter to the client as
+// server-sent events. A reconnecting client first gets what it missed after
+// its last seen event; the catch-up looks back a little before that event,
+// so an event may be sent twice but none is skipped.
+func eventStream(c *fiber.Ctx, db *gorm.DB, hub *OrderEventHub, filter eventFilter) error {
+	lastID, err := lastEventID(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid last event id",
+		})
+	}
+
+	sub, err := hub.subscribe(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to open event stream",
+			"message": err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	db = quietDB(db)
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer hub.unsubscribe(sub)
+
+		// Tell the client how long to wait before reconnecting
+		fmt.Fprintf(w, "retry: %d\n\n", eventPollInterval.Milliseconds())
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		sent := make(map[uint64]bool)
+		if lastID > 0 {
+			missed, err := missedEvents(db, filter, lastID)
+			if err != nil {
+				return
+			}
+			for _, event := range missed {
+				writeEvent(w, event)
+				sent[event.ID] = true
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(eventHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-sub.events:
+				if !ok {
+					// Dropped for falling behind, the client resumes on reconnect
+					return
+				}
+				if sent[event.ID] {
+					continue
+				}
+				writeEvent(w, event)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+
+			// A failed flush means the client went away
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}))
+
+	return nil
+}
+
+// missedEvents returns the events after lastID, plus lower IDs created up to
+// eventGapTimeout before it that the client may not have seen
+func missedEvents(db *gorm.DB, filter eventFilter, lastID uint64) ([]models.OrderEvent, error) {
+	var last models.OrderEvent
+	since := time.Now().Add(-eventGapTimeout)
+	if err := db.Select("id", "created_at").First(&last, "id = ?", lastID).Error; err == nil {
+		since = last.CreatedAt.Add(-eventGapTimeout)
+	}
+
+	var events []models.OrderEvent
+	afterID := uint64(0)
+	for {
+		var batch []models.OrderEvent
+		err := filter.scope(db.Model(&models.OrderEvent{})).
+			Where("id > ?", afterID).
+			Where("id > ? OR (id < ? AND created_at >= ?)", lastID, lastID, since).
+			Order("id ASC").
+			Limit(eventBatchSize).
+			Find(&batch).Error
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, batch...)
+		if len(batch) < eventBatchSize {
+			return events, nil
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+}
+
+func writeEvent(w *bufio.Writer, event models.OrderEvent) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
+
+// quietDB returns a session that only logs slow queries and errors, for
+// polling loops that would otherwise flood the query log
+func quietDB(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Warn)})
+}
+
+// lastEventID reads the resume point from the Last-Event-ID header, which
+// browsers send on reconnect, or the last_event_id query parameter
+func lastEventID(c *fiber.Ctx) (uint64, error) {
+	value := c.Get("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// StreamOrders pushes status, ETA and payment updates for the caller's orders
+// as server-sent events. Pass order_id to follow a single order.
+func (h *OrderHandler) StreamOrders(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	orderID := c.Query("order_id")
+
+	if orderID != "" {
+		var count int64
+		h.db.Model(&models.Order{}).Where("id = ? AND user_id = ?", orderID, userID).Count(&count)
+		if count == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Order not found",
+			})
+		}
+	}
+
+	return eventStream(c, h.db, h.events, eventFilter{UserID: userID, OrderID: orderID})
+}
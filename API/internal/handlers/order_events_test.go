@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newEventTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "events.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.OrderEvent{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func createEvent(t *testing.T, db *gorm.DB, id uint64, cafeID string) {
+	t.Helper()
+
+	event := models.OrderEvent{ID: id, OrderID: "order-1", UserID: "user-1", CafeID: cafeID, Type: models.OrderEventStatus, Data: "{}"}
+	if err := db.Create(&event).Error; err != nil {
+		t.Fatalf("create event %d: %v", id, err)
+	}
+}
+
+// received drains the events handed to a subscriber so far
+func received(sub *eventSubscriber) []uint64 {
+	var ids []uint64
+	for {
+		select {
+		case event := <-sub.events:
+			ids = append(ids, event.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestOrderEventHubDeliversLateCommits(t *testing.T) {
+	db := newEventTestDB(t)
+	createEvent(t, db, 1, "cafe-1")
+
+	hub := NewOrderEventHub(db)
+	hub.start.Do(func() {}) // polled by hand
+	sub, err := hub.subscribe(eventFilter{CafeID: "cafe-1"})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	other, _ := hub.subscribe(eventFilter{CafeID: "cafe-2"})
+
+	// Event 2 is still in an open transaction when 3 commits
+	createEvent(t, db, 3, "cafe-1")
+	if err := hub.poll(); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if got := received(sub); len(got) != 1 || got[0] != 3 {
+		t.Fatalf("first poll delivered %v, want [3]", got)
+	}
+
+	createEvent(t, db, 2, "cafe-1")
+	createEvent(t, db, 4, "cafe-1")
+	if err := hub.poll(); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if got := received(sub); len(got) != 2 || got[0] != 2 || got[1] != 4 {
+		t.Errorf("second poll delivered %v, want [2 4]", got)
+	}
+	if got := received(other); len(got) != 0 {
+		t.Errorf("other cafe received %v", got)
+	}
+	if len(hub.gaps) != 0 {
+		t.Errorf("gaps left after the late event arrived: %v", hub.gaps)
+	}
+
+	// A skipped ID that never commits is given up after a while
+	createEvent(t, db, 6, "cafe-1")
+	hub.poll()
+	hub.gaps[5] = time.Now().Add(-2 * eventGapTimeout)
+	hub.poll()
+	if _, ok := hub.gaps[5]; ok {
+		t.Error("rolled back event ID is still waited for")
+	}
+}
+
+func TestOrderEventHubDropsSlowSubscribers(t *testing.T) {
+	db := newEventTestDB(t)
+
+	hub := NewOrderEventHub(db)
+	hub.start.Do(func() {})
+	sub, _ := hub.subscribe(eventFilter{})
+
+	for id := uint64(1); id <= eventSubscriberBuffer+1; id++ {
+		createEvent(t, db, id, "cafe-1")
+	}
+	if err := hub.poll(); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	if _, ok := hub.subscribers[sub]; ok {
+		t.Error("subscriber that fell behind is still registered")
+	}
+	hub.unsubscribe(sub) // safe after being dropped
+}
+
+func TestMissedEventsLooksBack(t *testing.T) {
+	db := newEventTestDB(t)
+	for _, id := range []uint64{1, 3, 2, 4} {
+		createEvent(t, db, id, "cafe-1")
+	}
+	createEvent(t, db, 5, "cafe-2")
+
+	// The client saw 3 before 2 committed
+	events, err := missedEvents(db, eventFilter{CafeID: "cafe-1"}, 3)
+	if err != nil {
+		t.Fatalf("missed events: %v", err)
+	}
+
+	var ids []uint64
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 4 {
+		t.Errorf("missed events = %v, want [1 2 4]", ids)
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// validateScheduledPickup checks a requested pickup time against the cafe's
+// business hours, the notice the kitchen needs and the capacity of the pickup
+// slot. Run it inside the order transaction so the slot count is current.
+func validateScheduledPickup(tx *gorm.DB, cafe *models.Cafe, items []models.OrderItem, pickup, now time.Time) *fiber.Error {
+	settings := cafe.ScheduledOrderSettings()
+
+	notice := settings.LeadMinutes
+	for _, item := range items {
+		if item.Menu.PrepTime > notice {
+			notice = item.Menu.PrepTime
+		}
+	}
+	if pickup.Before(now.Add(time.Duration(notice) * time.Minute)) {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Pickup time must be at least %d minutes from now", notice))
+	}
+	if pickup.After(now.AddDate(0, 0, settings.MaxDaysAhead)) {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Orders can be scheduled at most %d days ahead", settings.MaxDaysAhead))
+	}
+
+	if !cafe.IsOpenAt(pickup) {
+		return fiber.NewError(fiber.StatusBadRequest, "Cafe is closed at the requested pickup time")
+	}
+
+	if settings.SlotCapacity == 0 {
+		return nil
+	}
+
+	slot := time.Duration(settings.SlotMinutes) * time.Minute
+	slotStart := pickup.Truncate(slot)
+
+	var booked int64
+	err := tx.Model(&models.Order{}).
+		Where("cafe_id = ? AND status <> ?", cafe.ID, string(models.OrderStatusCancelled)).
+		Where("scheduled_for >= ? AND scheduled_for < ?", slotStart, slotStart.Add(slot)).
+		Count(&booked).Error
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check pickup slot")
+	}
+	if booked >= int64(settings.SlotCapacity) {
+		return fiber.NewError(fiber.StatusConflict, "The requested pickup slot is fully booked, please choose another time")
+	}
+
+	return nil
+}
+
+// kitchenReleaseAt is when a scheduled order should appear on the kitchen display
+func kitchenReleaseAt(scheduledFor time.Time, leadMinutes int) time.Time {
+	return scheduledFor.Add(-time.Duration(leadMinutes) * time.Minute)
+}
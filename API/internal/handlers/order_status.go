@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// errInvalidStatusTransition is returned when the order status table does not
+// allow moving from the current status to the requested one.
+var errInvalidStatusTransition = errors.New("invalid order status transition")
+
+// errStatusChanged is returned when the order was updated by someone else
+// between reading it and applying the transition.
+var errStatusChanged = errors.New("order status was changed concurrently")
+
+// statusActor identifies who performed a status transition
+type statusActor struct {
+	ID   string
+	Role string
+}
+
+// systemActor is used for transitions made by background jobs
+var systemActor = statusActor{ID: "system", Role: "system"}
+
+// changeOrderStatus moves an order to the next status if the transition table
+// allows it and records the change in the order status history.
+func changeOrderStatus(tx *gorm.DB, order *models.Order, next models.OrderStatus, actor statusActor, reason string) error {
+	current := models.OrderStatus(order.Status)
+	if !current.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s -> %s", errInvalidStatusTransition, current, next)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":     string(next),
+		"updated_at": now,
+	}
+
+	if next == models.OrderStatusCompleted {
+		updates["completed_at"] = &now
+	}
+
+	// Only update if nobody changed the status in the meantime
+	result := tx.Model(&models.Order{}).
+		Where("id = ? AND status = ?", order.ID, order.Status).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errStatusChanged
+	}
+
+	if err := recordOrderStatus(tx, order.ID, string(current), string(next), actor, reason); err != nil {
+		return err
+	}
+
+	if next == models.OrderStatusCancelled {
+		if err := releaseOrderReservations(tx, order.ID, actor); err != nil {
+			return err
+		}
+	}
+
+	// The ingredients are used once the cafe has accepted the order
+	if next == models.OrderStatusConfirmed || next == models.OrderStatusCompleted {
+		if err := deductOrderStock(tx, order, actor); err != nil {
+			return err
+		}
+	}
+
+	// A ready order has nothing left to make in the kitchen
+	if next == models.OrderStatusReady {
+		err := tx.Model(&models.OrderItem{}).
+			Where("order_id = ? AND kitchen_status <> ?", order.ID, models.KitchenStatusDone).
+			Update("kitchen_status", models.KitchenStatusDone).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	order.Status = string(next)
+	if next == models.OrderStatusCompleted {
+		order.CompletedAt = &now
+	}
+
+	return nil
+}
+
+// updateOrderETA changes the estimated preparation time of an order and
+// publishes it to live order streams
+func updateOrderETA(tx *gorm.DB, order *models.Order, minutes int) error {
+	err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("estimated_time", minutes).Error
+	if err != nil {
+		return err
+	}
+	order.EstimatedTime = minutes
+
+	return publishOrderEvent(tx, order.ID, models.OrderEventETA, fiber.Map{
+		"status":         order.Status,
+		"estimated_time": minutes,
+		"estimated_at":   time.Now().Add(time.Duration(minutes) * time.Minute),
+	})
+}
+
+// recordOrderStatus writes an entry to the order status history and publishes
+// it to live order streams
+func recordOrderStatus(tx *gorm.DB, orderID, from, to string, actor statusActor, reason string) error {
+	entry := models.OrderStatusHistory{
+		ID:         uuid.New().String(),
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		ChangedBy:  actor.ID,
+		ActorRole:  actor.Role,
+		Reason:     reason,
+	}
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return err
+	}
+
+	return publishOrderEvent(tx, orderID, models.OrderEventStatus, fiber.Map{
+		"status":          to,
+		"previous_status": from,
+		"reason":          reason,
+		"changed_at":      entry.CreatedAt,
+	})
+}
+
+// releaseOrderReservations gives back what a cancelled order was holding:
+// rewards redeemed on the order become available again and stock taken out
+// for the order is put back, see returnOrderStock.
+func releaseOrderReservations(tx *gorm.DB, orderID string, actor statusActor) error {
+	err := tx.Model(&models.MemberReward{}).
+		Where("order_id = ? AND status = ?", orderID, "used").
+		Updates(map[string]interface{}{
+			"status":   "available",
+			"used_at":  nil,
+			"order_id": "",
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	return returnOrderStock(tx, orderID, "cancellation", "Stock returned from cancelled order", actor)
+}
+
+// orderTimeline orders preloaded status history from oldest to newest
+func orderTimeline(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at ASC")
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"math"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// stockReasonSale is the reason of the movements that take an order's
+// ingredients out of stock
+const stockReasonSale = "sale"
+
+// orderStockLine is what an order has taken out of one inventory item for one
+// of its items
+type orderStockLine struct {
+	OrderItemID string
+	InventoryID string
+	CafeID      string
+	Sold        float64 // taken out by the sale
+	Quantity    float64 // still out, after what was returned
+	UnitCost    float64
+}
+
+// deductOrderStock takes the ingredients of an order out of stock using the
+// recipes of its menu items and the options chosen. Each order item gets one
+// "out" movement per inventory item, referencing the order. An order is only
+// deducted once, so completing a confirmed order does not use stock again.
+// Only movements of this path carry an order item, so a movement added by hand
+// against the order does not count as the deduction.
+// Stock may go below zero when the counts are off; it then shows as low stock.
+// Menu items that can no longer be made are marked sold out.
+func deductOrderStock(tx *gorm.DB, order *models.Order, actor statusActor) error {
+	var deducted int64
+	err := tx.Model(&models.StockMovement{}).
+		Where("reference_id = ? AND order_item_id <> '' AND type = ? AND reason = ?", order.ID, "out", stockReasonSale).
+		Count(&deducted).Error
+	if err != nil {
+		return err
+	}
+	if deducted > 0 {
+		return nil
+	}
+
+	var items []models.OrderItem
+	if err := tx.Preload("Options").Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return err
+	}
+
+	menuIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		menuIDs = append(menuIDs, item.MenuID)
+	}
+
+	var lines []models.RecipeItem
+	err = tx.Preload("Inventory").Where("menu_id IN ?", menuIDs).Order("created_at ASC").Find(&lines).Error
+	if err != nil {
+		return err
+	}
+
+	recipes := make(map[string][]models.RecipeItem)
+	inventories := make(map[string]models.Inventory)
+	for _, line := range lines {
+		// Deleted inventory items are no longer tracked
+		if line.Inventory.ID == "" {
+			continue
+		}
+		recipes[line.MenuID] = append(recipes[line.MenuID], line)
+		inventories[line.InventoryID] = line.Inventory
+	}
+
+	var used []string
+	for _, item := range items {
+		optionIDs := make([]string, 0, len(item.Options))
+		for _, option := range item.Options {
+			optionIDs = append(optionIDs, option.OptionID)
+		}
+
+		for inventoryID, perPortion := range models.RecipeUsage(recipes[item.MenuID], optionIDs) {
+			inventory := inventories[inventoryID]
+			quantity := perPortion * float64(item.Quantity)
+
+			movement := models.StockMovement{
+				ID:          uuid.New().String(),
+				InventoryID: inventoryID,
+				CafeID:      order.CafeID,
+				Type:        "out",
+				Quantity:    quantity,
+				UnitCost:    inventory.UnitCost,
+				TotalCost:   quantity * inventory.UnitCost,
+				Reason:      stockReasonSale,
+				ReferenceID: order.ID,
+				OrderItemID: item.ID,
+				Notes:       "Ingredients used for order",
+				PerformedBy: actor.ID,
+			}
+			if err := tx.Create(&movement).Error; err != nil {
+				return err
+			}
+
+			err := tx.Model(&models.Inventory{}).
+				Where("id = ?", inventoryID).
+				Update("current_stock", gorm.Expr("current_stock - ?", quantity)).Error
+			if err != nil {
+				return err
+			}
+			used = append(used, inventoryID)
+		}
+	}
+
+	return refreshSoldOut(tx, used...)
+}
+
+// returnOrderStock puts back everything an order still has out of stock.
+// Stock is returned by the net amount still out, so calling it more than once
+// is safe. Menu items sold out for lack of the returned stock come back.
+func returnOrderStock(tx *gorm.DB, orderID, reason, notes string, actor statusActor) error {
+	lines, err := orderStockLines(tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	var returned []string
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			continue
+		}
+		if err := returnStock(tx, orderID, line, line.Quantity, reason, notes, actor); err != nil {
+			return err
+		}
+		returned = append(returned, line.InventoryID)
+	}
+
+	return refreshSoldOut(tx, returned...)
+}
+
+// returnRefundedStock puts back the ingredients of refunded order items in
+// proportion to the quantity refunded, never more than is still out
+func returnRefundedStock(tx *gorm.DB, order *models.Order, refunded []models.RefundItem, actor statusActor) error {
+	ordered := make(map[string]int, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		ordered[item.ID] = item.Quantity
+	}
+	portions := make(map[string]int, len(refunded))
+	for _, item := range refunded {
+		portions[item.OrderItemID] += item.Quantity
+	}
+
+	lines, err := orderStockLines(tx, order.ID)
+	if err != nil {
+		return err
+	}
+
+	var returned []string
+	for _, line := range lines {
+		if portions[line.OrderItemID] == 0 || ordered[line.OrderItemID] == 0 || line.Sold <= 0 {
+			continue
+		}
+
+		quantity := math.Min(line.Sold/float64(ordered[line.OrderItemID])*float64(portions[line.OrderItemID]), line.Quantity)
+		if quantity <= 0 {
+			continue
+		}
+		if err := returnStock(tx, order.ID, line, quantity, "refund", "Stock returned from refunded items", actor); err != nil {
+			return err
+		}
+		returned = append(returned, line.InventoryID)
+	}
+
+	return refreshSoldOut(tx, returned...)
+}
+
+// orderStockLines sums the stock movements of an order per order item and
+// inventory item. Movements added by hand with the order as reference have no
+// order item.
+func orderStockLines(tx *gorm.DB, orderID string) ([]orderStockLine, error) {
+	var lines []orderStockLine
+	err := tx.Model(&models.StockMovement{}).
+		Select(`order_item_id, inventory_id, cafe_id,
+			SUM(CASE WHEN type = 'out' AND reason = ? AND order_item_id <> '' THEN quantity ELSE 0 END) AS sold,
+			SUM(CASE WHEN type = 'out' THEN quantity ELSE -quantity END) AS quantity,
+			MAX(CASE WHEN type = 'out' THEN unit_cost ELSE 0 END) AS unit_cost`, stockReasonSale).
+		Where("reference_id = ? AND type IN ?", orderID, []string{"out", "in"}).
+		Group("order_item_id, inventory_id, cafe_id").
+		Scan(&lines).Error
+	return lines, err
+}
+
+// returnStock writes an "in" movement for part of an order stock line and
+// adds it back to the inventory item
+func returnStock(tx *gorm.DB, orderID string, line orderStockLine, quantity float64, reason, notes string, actor statusActor) error {
+	movement := models.StockMovement{
+		ID:          uuid.New().String(),
+		InventoryID: line.InventoryID,
+		CafeID:      line.CafeID,
+		Type:        "in",
+		Quantity:    quantity,
+		UnitCost:    line.UnitCost,
+		TotalCost:   quantity * line.UnitCost,
+		Reason:      reason,
+		ReferenceID: orderID,
+		OrderItemID: line.OrderItemID,
+		Notes:       notes,
+		PerformedBy: actor.ID,
+	}
+	if err := tx.Create(&movement).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Inventory{}).
+		Where("id = ?", line.InventoryID).
+		Update("current_stock", gorm.Expr("current_stock + ?", quantity)).Error
+}
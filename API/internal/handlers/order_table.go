@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"time"
+
+	"siipcoffe-api/internal/models"
+	"siipcoffe-api/pkg/pricing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TableOrderRequest is a round of orders placed on a table's tab
+type TableOrderRequest struct {
+	Items         []OrderItemRequest `json:"items"`
+	CustomerName  string             `json:"customer_name"`
+	CustomerPhone string             `json:"customer_phone"`
+	Notes         string             `json:"notes"`
+}
+
+// CreateTableOrder places a dine-in order on the tab of the session token in
+// the request. Guests can order without an account; a logged in customer's
+// order is also linked to their account.
+func (h *OrderHandler) CreateTableOrder(c *fiber.Ctx) error {
+	session, ferr := tableSessionFromRequest(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	var req TableOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"message": err.Error(),
+		})
+	}
+	if len(req.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one item is required",
+		})
+	}
+
+	var table models.CafeTable
+	if err := h.db.Where("id = ? AND is_active = ?", session.TableID, true).First(&table).Error; err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "This table is not taking orders, ask the staff for help",
+		})
+	}
+
+	cafe, orderItems, ferr := h.resolveCart(h.db, req.Items)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+	if cafe.ID != session.CafeID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "All items must come from the cafe of this table",
+		})
+	}
+	if !cafe.AcceptsOrderType("dine_in") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":                "This cafe does not take dine_in orders",
+			"accepted_order_types": cafe.Ordering().OrderTypes,
+		})
+	}
+	if now := time.Now(); !cafe.IsOpenAt(now) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":        "Cafe is currently closed",
+			"next_open_at": cafe.NextStatusChange(now),
+		})
+	}
+	if ferr := checkMenuCategories(h.db, cafe, orderItems, time.Now()); ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	breakdown, err := pricing.Calculate(cafe, sumItemTotals(orderItems), "dine_in", 0)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Order does not meet the minimum order amount",
+			"message": err.Error(),
+			"data":    breakdown,
+		})
+	}
+
+	// Guests order without an account
+	actor := statusActor{ID: "guest", Role: "guest"}
+	userID, _ := c.Locals("user_id").(string)
+	if userID != "" {
+		actor = statusActor{ID: userID, Role: c.Locals("user_role").(string)}
+	}
+
+	order := models.Order{
+		ID:             uuid.New().String(),
+		CafeID:         cafe.ID,
+		UserID:         userID,
+		OrderNumber:    h.generateOrderNumber(),
+		Status:         string(models.OrderStatusPending),
+		PaymentStatus:  string(models.PaymentStatusPending),
+		CustomerName:   req.CustomerName,
+		CustomerPhone:  req.CustomerPhone,
+		OrderType:      "dine_in",
+		TableNumber:    table.Number,
+		TableID:        &table.ID,
+		TableSessionID: &session.ID,
+		Notes:          req.Notes,
+	}
+	if order.CustomerName == "" {
+		order.CustomerName = "Table " + table.Number
+	}
+	breakdown.Apply(&order)
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		for _, item := range orderItems {
+			item.OrderID = order.ID
+			if err := tx.Omit("Menu").Create(&item).Error; err != nil {
+				return err
+			}
+		}
+		return recordOrderStatus(tx, order.ID, "", order.Status, actor, "Ordered at table "+table.Number)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to create order",
+			"message": err.Error(),
+		})
+	}
+
+	h.db.Preload("OrderItems.Menu").Preload("OrderItems.Options").Preload("StatusHistory", orderTimeline).First(&order, "id = ?", order.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Order added to your tab",
+		"data":    order.ToResponse(),
+	})
+}
+
+// resolveTable finds the table a dine-in order names. Cafes that have not set
+// up their tables keep taking any table number, so it returns nil for them.
+func resolveTable(db *gorm.DB, cafeID, number string) (*models.CafeTable, *fiber.Error) {
+	var tables int64
+	db.Model(&models.CafeTable{}).Where("cafe_id = ?", cafeID).Count(&tables)
+	if tables == 0 {
+		return nil, nil
+	}
+
+	var table models.CafeTable
+	if err := db.Where("cafe_id = ? AND number = ? AND is_active = ?", cafeID, number, true).First(&table).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Table "+number+" does not exist at this cafe")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to get table")
+	}
+	return &table, nil
+}
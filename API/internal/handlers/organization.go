@@ -0,0 +1,707 @@
+package handlers
+
+import (
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OrganizationHandler struct {
+	db *gorm.DB
+}
+
+func NewOrganizationHandler(db *gorm.DB) *OrganizationHandler {
+	return &OrganizationHandler{db: db}
+}
+
+type OrganizationRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	LogoURL     string `json:"logo_url"`
+}
+
+type CreateCatalogItemRequest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Category    string  `json:"category"`
+	Price       float64 `json:"price"`
+	ImageURL    string  `json:"image_url"`
+	IsAvailable *bool   `json:"is_available"`
+	Ingredients string  `json:"ingredients"`
+	PrepTime    int     `json:"prep_time"`
+}
+
+type UpdateCatalogItemRequest struct {
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	Category    *string  `json:"category"`
+	Price       *float64 `json:"price"`
+	ImageURL    *string  `json:"image_url"`
+	IsAvailable *bool    `json:"is_available"`
+	Ingredients *string  `json:"ingredients"`
+	PrepTime    *int     `json:"prep_time"`
+}
+
+// BranchOverrideRequest sets or clears a branch's own price and availability
+// for a catalogue item
+type BranchOverrideRequest struct {
+	Price             *float64 `json:"price"`
+	IsAvailable       *bool    `json:"is_available"`
+	ResetPrice        bool     `json:"reset_price"`
+	ResetAvailability bool     `json:"reset_availability"`
+}
+
+// CreateOrganization creates the owner's brand and adds all their cafes to it
+// as branches
+func (h *OrganizationHandler) CreateOrganization(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var req OrganizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Organization name is required",
+		})
+	}
+
+	var count int64
+	h.db.Model(&models.Organization{}).Where("owner_id = ?", userID).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   "You already have an organization",
+		})
+	}
+
+	org := models.Organization{
+		ID:          uuid.New().String(),
+		OwnerID:     userID,
+		Name:        req.Name,
+		Description: req.Description,
+		LogoURL:     req.LogoURL,
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Cafe{}).Where("owner_id = ?", userID).Update("organization_id", org.ID).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to create organization",
+		})
+	}
+
+	h.db.Preload("Branches").First(&org, "id = ?", org.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    org.ToResponse(),
+	})
+}
+
+// GetOrganization returns the owner's brand with its branches
+func (h *OrganizationHandler) GetOrganization(c *fiber.Ctx) error {
+	org, ferr := h.ownerOrganization(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    org.ToResponse(),
+	})
+}
+
+// UpdateOrganization changes the brand details
+func (h *OrganizationHandler) UpdateOrganization(c *fiber.Ctx) error {
+	org, ferr := h.ownerOrganization(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var req OrganizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		updates["name"] = req.Name
+		org.Name = req.Name
+	}
+	if req.Description != "" {
+		updates["description"] = req.Description
+		org.Description = req.Description
+	}
+	if req.LogoURL != "" {
+		updates["logo_url"] = req.LogoURL
+		org.LogoURL = req.LogoURL
+	}
+
+	if err := h.db.Model(&models.Organization{}).Where("id = ?", org.ID).Updates(updates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to update organization",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    org.ToResponse(),
+	})
+}
+
+// ListBranches lists the owner's cafes, for choosing the X-Cafe-ID of later
+// requests
+func (h *OrganizationHandler) ListBranches(c *fiber.Ctx) error {
+	var cafes []models.Cafe
+	if err := h.db.Where("owner_id = ?", c.Locals("user_id").(string)).Order("created_at ASC").Find(&cafes).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to get branches",
+		})
+	}
+
+	branches := make([]models.BranchSummary, 0, len(cafes))
+	for _, cafe := range cafes {
+		branches = append(branches, cafe.ToBranchSummary())
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    branches,
+	})
+}
+
+// GetCatalog lists the brand catalogue with how each branch sells the items
+func (h *OrganizationHandler) GetCatalog(c *fiber.Ctx) error {
+	org, ferr := h.ownerOrganization(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var items []models.CatalogItem
+	err := h.db.Preload("BranchMenus").Where("organization_id = ?", org.ID).Order("category ASC, name ASC").Find(&items).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to get catalog",
+		})
+	}
+
+	responses := make([]models.CatalogItemResponse, 0, len(items))
+	for _, item := range items {
+		responses = append(responses, item.ToResponse())
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    responses,
+	})
+}
+
+// CreateCatalogItem adds an item to the brand catalogue and to the menu of
+// every branch
+func (h *OrganizationHandler) CreateCatalogItem(c *fiber.Ctx) error {
+	org, ferr := h.ownerOrganization(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var req CreateCatalogItemRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+	if req.Name == "" || req.Category == "" || req.Price <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Name, category, and price are required",
+		})
+	}
+
+	item := models.CatalogItem{
+		ID:             uuid.New().String(),
+		OrganizationID: org.ID,
+		Name:           req.Name,
+		Description:    req.Description,
+		Category:       req.Category,
+		Price:          req.Price,
+		ImageURL:       req.ImageURL,
+		IsAvailable:    true,
+		Ingredients:    req.Ingredients,
+		PrepTime:       req.PrepTime,
+	}
+	if req.IsAvailable != nil {
+		item.IsAvailable = *req.IsAvailable
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		for _, branch := range org.Branches {
+			if err := createBranchMenu(tx, &item, branch.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to create catalog item",
+		})
+	}
+
+	h.db.Preload("BranchMenus").First(&item, "id = ?", item.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    item.ToResponse(),
+	})
+}
+
+// UpdateCatalogItem changes a catalogue item and the branch menus that follow
+// it. Branch price and availability overrides are kept.
+func (h *OrganizationHandler) UpdateCatalogItem(c *fiber.Ctx) error {
+	org, ferr := h.ownerOrganization(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var item models.CatalogItem
+	if err := h.db.Preload("BranchMenus").First(&item, "id = ? AND organization_id = ?", c.Params("id"), org.ID).Error; err != nil {
+		return catalogItemError(c, err)
+	}
+
+	var req UpdateCatalogItemRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+	if req.Price != nil && *req.Price <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Price must be greater than 0",
+		})
+	}
+
+	if req.Name != nil {
+		item.Name = *req.Name
+	}
+	if req.Description != nil {
+		item.Description = *req.Description
+	}
+	if req.Category != nil {
+		item.Category = *req.Category
+	}
+	if req.Price != nil {
+		item.Price = *req.Price
+	}
+	if req.ImageURL != nil {
+		item.ImageURL = *req.ImageURL
+	}
+	if req.IsAvailable != nil {
+		item.IsAvailable = *req.IsAvailable
+	}
+	if req.Ingredients != nil {
+		item.Ingredients = *req.Ingredients
+	}
+	if req.PrepTime != nil {
+		item.PrepTime = *req.PrepTime
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.CatalogItem{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+			"name":         item.Name,
+			"description":  item.Description,
+			"category":     item.Category,
+			"price":        item.Price,
+			"image_url":    item.ImageURL,
+			"is_available": item.IsAvailable,
+			"ingredients":  item.Ingredients,
+			"prep_time":    item.PrepTime,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range item.BranchMenus {
+			menu := &item.BranchMenus[i]
+			item.ApplyTo(menu)
+			if err := saveBranchMenu(tx, menu); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to update catalog item",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    item.ToResponse(),
+	})
+}
+
+// DeleteCatalogItem removes an item from the catalogue. Branch menus are
+// unlinked and made unavailable, like deleting a menu, so past orders keep
+// their item.
+func (h *OrganizationHandler) DeleteCatalogItem(c *fiber.Ctx) error {
+	org, ferr := h.ownerOrganization(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var item models.CatalogItem
+	if err := h.db.First(&item, "id = ? AND organization_id = ?", c.Params("id"), org.ID).Error; err != nil {
+		return catalogItemError(c, err)
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Menu{}).Where("catalog_item_id = ?", item.ID).Updates(map[string]interface{}{
+			"catalog_item_id": nil,
+			"is_available":    false,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&item).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to delete catalog item",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Catalog item deleted successfully",
+	})
+}
+
+// SetBranchOverride sets a branch's own price or availability for a
+// catalogue item, or resets them to follow the catalogue again
+func (h *OrganizationHandler) SetBranchOverride(c *fiber.Ctx) error {
+	org, ferr := h.ownerOrganization(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var item models.CatalogItem
+	if err := h.db.First(&item, "id = ? AND organization_id = ?", c.Params("id"), org.ID).Error; err != nil {
+		return catalogItemError(c, err)
+	}
+
+	var menu models.Menu
+	err := h.db.First(&menu, "catalog_item_id = ? AND cafe_id = ?", item.ID, c.Params("cafeId")).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"error":   "Branch does not sell this catalog item",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to get branch menu",
+		})
+	}
+
+	var req BranchOverrideRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+	if req.Price != nil && *req.Price <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Price must be greater than 0",
+		})
+	}
+
+	if req.ResetPrice {
+		menu.PriceOverridden = false
+	} else if req.Price != nil {
+		menu.Price = *req.Price
+		menu.PriceOverridden = true
+	}
+	if req.ResetAvailability {
+		menu.AvailabilityOverridden = false
+	} else if req.IsAvailable != nil {
+		menu.IsAvailable = *req.IsAvailable
+		menu.AvailabilityOverridden = true
+	}
+	item.ApplyTo(&menu)
+
+	if err := saveBranchMenu(h.db, &menu); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to update branch menu",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    menu.ToResponse(),
+	})
+}
+
+// GetConsolidatedReport sums up sales across all branches of the brand
+func (h *OrganizationHandler) GetConsolidatedReport(c *fiber.Ctx) error {
+	org, ferr := h.ownerOrganization(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	startDate := c.Query("start_date", "")
+	endDate := c.Query("end_date", "")
+	inPeriod := func(q *gorm.DB, column string) *gorm.DB {
+		if startDate != "" && endDate != "" {
+			return q.Where(column+" BETWEEN ? AND ?", startDate, endDate)
+		}
+		// Default to last 30 days
+		return q.Where(column+" >= ?", time.Now().AddDate(0, 0, -30))
+	}
+
+	branchIDs := make([]string, 0, len(org.Branches))
+	for _, branch := range org.Branches {
+		branchIDs = append(branchIDs, branch.ID)
+	}
+
+	// Sales per branch, cancelled orders excluded
+	var sales []struct {
+		CafeID  string
+		Orders  int64
+		Revenue float64
+	}
+	inPeriod(h.db.Model(&models.Order{}), "created_at").
+		Select("cafe_id, COUNT(*) as orders, COALESCE(SUM(total_amount), 0) as revenue").
+		Where("cafe_id IN ? AND status <> ?", branchIDs, string(models.OrderStatusCancelled)).
+		Group("cafe_id").
+		Scan(&sales)
+
+	var refunds []struct {
+		CafeID string
+		Amount float64
+	}
+	inPeriod(h.db.Model(&models.Refund{}), "created_at").
+		Select("cafe_id, COALESCE(SUM(amount), 0) as amount").
+		Where("cafe_id IN ? AND status = ?", branchIDs, models.RefundStatusCompleted).
+		Group("cafe_id").
+		Scan(&refunds)
+
+	salesByBranch := make(map[string]int, len(sales))
+	for i, row := range sales {
+		salesByBranch[row.CafeID] = i
+	}
+	refundsByBranch := make(map[string]float64, len(refunds))
+	for _, row := range refunds {
+		refundsByBranch[row.CafeID] = row.Amount
+	}
+
+	var totalOrders int64
+	var totalRevenue, totalRefunds float64
+	branches := make([]fiber.Map, 0, len(org.Branches))
+	for _, branch := range org.Branches {
+		var orders int64
+		var revenue float64
+		if i, ok := salesByBranch[branch.ID]; ok {
+			orders, revenue = sales[i].Orders, sales[i].Revenue
+		}
+		refunded := refundsByBranch[branch.ID]
+
+		totalOrders += orders
+		totalRevenue += revenue
+		totalRefunds += refunded
+
+		branches = append(branches, fiber.Map{
+			"cafe_id":             branch.ID,
+			"name":                branch.Name,
+			"city":                branch.City,
+			"total_orders":        orders,
+			"total_revenue":       revenue,
+			"total_refunds":       refunded,
+			"net_revenue":         revenue - refunded,
+			"average_order_value": averageOrderValue(revenue, orders),
+		})
+	}
+
+	// Best sellers across branches, catalogue items counted once for the brand
+	type topItem struct {
+		ItemID   string  `json:"item_id"`
+		Name     string  `json:"name"`
+		Quantity int64   `json:"quantity"`
+		Revenue  float64 `json:"revenue"`
+	}
+	topItems := []topItem{}
+	inPeriod(h.db.Table("order_items"), "orders.created_at").
+		Select("COALESCE(menus.catalog_item_id, menus.id) as item_id, MAX(menus.name) as name, SUM(order_items.quantity) as quantity, SUM(order_items.total_price) as revenue").
+		Joins("JOIN menus ON menus.id = order_items.menu_id").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.cafe_id IN ? AND orders.status <> ?", branchIDs, string(models.OrderStatusCancelled)).
+		Group("COALESCE(menus.catalog_item_id, menus.id)").
+		Order("quantity DESC").
+		Limit(10).
+		Scan(&topItems)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"organization_id": org.ID,
+			"summary": fiber.Map{
+				"branches":            len(org.Branches),
+				"total_orders":        totalOrders,
+				"total_revenue":       totalRevenue,
+				"total_refunds":       totalRefunds,
+				"net_revenue":         totalRevenue - totalRefunds,
+				"average_order_value": averageOrderValue(totalRevenue, totalOrders),
+			},
+			"branches":  branches,
+			"top_items": topItems,
+		},
+	})
+}
+
+// ownerOrganization returns the caller's organization with its branches
+func (h *OrganizationHandler) ownerOrganization(c *fiber.Ctx) (*models.Organization, *fiber.Error) {
+	var org models.Organization
+	err := h.db.Preload("Branches", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).First(&org, "owner_id = ?", c.Locals("user_id").(string)).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Organization not found, create one first")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to get organization")
+	}
+	return &org, nil
+}
+
+// syncCatalogToBranch adds the catalogue items a branch does not sell yet to
+// its menu
+func syncCatalogToBranch(tx *gorm.DB, organizationID, cafeID string) error {
+	var items []models.CatalogItem
+	linked := tx.Model(&models.Menu{}).Select("catalog_item_id").Where("cafe_id = ? AND catalog_item_id IS NOT NULL", cafeID)
+	if err := tx.Where("organization_id = ? AND id NOT IN (?)", organizationID, linked).Find(&items).Error; err != nil {
+		return err
+	}
+	for i := range items {
+		if err := createBranchMenu(tx, &items[i], cafeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createBranchMenu adds a catalogue item to a branch menu
+func createBranchMenu(tx *gorm.DB, item *models.CatalogItem, cafeID string) error {
+	menu := models.Menu{
+		ID:     uuid.New().String(),
+		CafeID: cafeID,
+	}
+	item.ApplyTo(&menu)
+	if err := assignMenuCategory(tx, &menu); err != nil {
+		return err
+	}
+	if err := tx.Create(&menu).Error; err != nil {
+		return err
+	}
+	if !menu.IsAvailable {
+		// is_available defaults to true on insert
+		return tx.Model(&models.Menu{}).Where("id = ?", menu.ID).Update("is_available", false).Error
+	}
+	return nil
+}
+
+// saveBranchMenu writes the catalogue fields and overrides of a branch menu
+func saveBranchMenu(tx *gorm.DB, menu *models.Menu) error {
+	if err := assignMenuCategory(tx, menu); err != nil {
+		return err
+	}
+	return tx.Model(&models.Menu{}).Where("id = ?", menu.ID).Updates(map[string]interface{}{
+		"name":                    menu.Name,
+		"description":             menu.Description,
+		"category":                menu.Category,
+		"category_id":             menu.CategoryID,
+		"price":                   menu.Price,
+		"image_url":               menu.ImageURL,
+		"is_available":            menu.IsAvailable,
+		"ingredients":             menu.Ingredients,
+		"prep_time":               menu.PrepTime,
+		"price_overridden":        menu.PriceOverridden,
+		"availability_overridden": menu.AvailabilityOverridden,
+	}).Error
+}
+
+func catalogItemError(c *fiber.Ctx, err error) error {
+	if err == gorm.ErrRecordNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Catalog item not found",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"error":   "Failed to get catalog item",
+	})
+}
+
+func averageOrderValue(revenue float64, orders int64) float64 {
+	if orders == 0 {
+		return 0
+	}
+	return revenue / float64(orders)
+}
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"siipcoffe-api/internal/config"
 	"siipcoffe-api/internal/models"
+	"siipcoffe-api/pkg/gateway"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -13,20 +15,22 @@ import (
 )
 
 type PaymentHandler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db        *gorm.DB
+	cfg       *config.Config
+	providers *gateway.Registry
 }
 
-func NewPaymentHandler(db *gorm.DB, cfg *config.Config) *PaymentHandler {
+func NewPaymentHandler(db *gorm.DB, cfg *config.Config, providers *gateway.Registry) *PaymentHandler {
 	return &PaymentHandler{
-		db:  db,
-		cfg: cfg,
+		db:        db,
+		cfg:       cfg,
+		providers: providers,
 	}
 }
 
 type ProcessPaymentRequest struct {
 	OrderID string  `json:"order_id" validate:"required"`
-	Method  string  `json:"method" validate:"required,oneof=crypto cash transfer"`
+	Method  string  `json:"method" validate:"required"` // any registered provider: crypto, cash, transfer
 	Amount  float64 `json:"amount" validate:"required,gt=0"`
 }
 
@@ -47,6 +51,14 @@ func (h *PaymentHandler) ProcessPayment(c *fiber.Ctx) error {
 		})
 	}
 
+	provider, ok := h.providers.Get(req.Method)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid payment method",
+			"supported_methods": h.providers.Methods(),
+		})
+	}
+
 	// Get order
 	var order models.Order
 	err := h.db.Where("id = ? AND user_id = ?", req.OrderID, userID).First(&order).Error
@@ -71,6 +83,26 @@ func (h *PaymentHandler) ProcessPayment(c *fiber.Ctx) error {
 		})
 	}
 
+	if models.OrderStatus(order.Status) == models.OrderStatusCancelled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Order has been cancelled",
+		})
+	}
+
+	var cafe models.Cafe
+	h.db.Select("id", "status", "settings").First(&cafe, "id = ?", order.CafeID)
+	if cafe.Status == models.CafeStatusSuspended {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "This cafe is suspended and cannot take payments",
+		})
+	}
+	if !cafe.AcceptsPaymentMethod(req.Method) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "This cafe does not accept " + req.Method + " payments",
+			"accepted_methods": cafe.Ordering().PaymentMethods,
+		})
+	}
+
 	// Create payment record
 	payment := models.Payment{
 		ID:            uuid.New().String(),
@@ -79,106 +111,78 @@ func (h *PaymentHandler) ProcessPayment(c *fiber.Ctx) error {
 		Method:        req.Method,
 		Status:        string(models.PaymentStatusPending),
 		TransactionID: h.generateTransactionID(),
+		ExpiresAt:     h.paymentExpiry(provider, order.CafeID),
 	}
 
-	if err := h.db.Create(&payment).Error; err != nil {
+	// Only one active payment per order. The check and the insert share a
+	// transaction that starts by writing the order, so concurrent requests
+	// for the same order run one after the other; the unique index on active
+	// payments catches anything that still slips through.
+	var active models.Payment
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("payment_method", req.Method).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Where("order_id = ? AND status IN ?", order.ID, models.ActivePaymentStatuses).First(&active).Error
+		if err == nil {
+			return errActivePayment
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		return tx.Create(&payment).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		h.db.Where("order_id = ? AND status IN ?", order.ID, models.ActivePaymentStatuses).First(&active)
+		err = errActivePayment
+	}
+	if err != nil {
+		if errors.Is(err, errActivePayment) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Order already has an active payment",
+				"payment_id": active.ID,
+				"payment_status": active.Status,
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create payment",
 			"message": err.Error(),
 		})
 	}
 
-	// Update order with payment info
-	h.db.Model(&order).Updates(map[string]interface{}{
-		"payment_method": req.Method,
-		"payment_id":     payment.ID,
-	})
-
-	// Process payment based on method
-	var response interface{}
-	switch req.Method {
-	case "crypto":
-		response = h.processCryptoPayment(payment, order)
-	case "cash":
-		response = h.processCashPayment(payment, order)
-	case "transfer":
-		response = h.processBankTransfer(payment, order)
-	default:
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid payment method",
+	// Let the provider prepare the payment
+	instructions, err := provider.Initiate(&payment, &order)
+	if err != nil {
+		h.db.Model(&payment).Update("status", string(models.PaymentStatusFailed))
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to initiate payment",
+			"message": err.Error(),
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Payment processed successfully",
-		"data": response,
-	})
-}
-
-func (h *PaymentHandler) processCryptoPayment(payment models.Payment, order models.Order) interface{} {
-	// Generate unique wallet address for this payment
-	walletAddress := h.generateCryptoAddress()
-
-	// Update payment with crypto info
 	h.db.Model(&payment).Updates(map[string]interface{}{
-		"crypto_address": walletAddress,
+		"crypto_address":     payment.CryptoAddress,
+		"provider_reference": payment.ProviderReference,
 	})
 
-	// Calculate crypto amount (simplified - in production, use real exchange rates)
-	cryptoAmount := payment.Amount / 100000000 // Convert to BTC (example)
-
-	return fiber.Map{
-		"payment_id":     payment.ID,
-		"transaction_id": payment.TransactionID,
-		"method":         "crypto",
-		"wallet_address": walletAddress,
-		"crypto_amount":  cryptoAmount,
-		"fiat_amount":    payment.Amount,
-		"currency":       "IDR",
-		"qr_code":        fmt.Sprintf("bitcoin:%s?amount=%.8f", walletAddress, cryptoAmount),
-		"status":         "awaiting_payment",
-		"expires_at":     "2024-01-01T23:59:59Z", // 24 hours from now
+	if payment.ExpiresAt != nil {
+		instructions["expires_at"] = payment.ExpiresAt
 	}
-}
-
-func (h *PaymentHandler) processCashPayment(payment models.Payment, order models.Order) interface{} {
-	// For cash payment, mark as pending confirmation
-	h.db.Model(&payment).Updates(map[string]interface{}{
-		"status": string(models.PaymentStatusPending),
-	})
 
-	return fiber.Map{
+	// Update order with payment info
+	h.db.Model(&order).Updates(map[string]interface{}{
+		"payment_method": req.Method,
 		"payment_id":     payment.ID,
-		"transaction_id": payment.TransactionID,
-		"method":         "cash",
-		"amount":         payment.Amount,
-		"currency":       "IDR",
-		"status":         "pending_confirmation",
-		"instructions":   "Silakan bayar di kasir saat menerima pesanan",
-	}
-}
-
-func (h *PaymentHandler) processBankTransfer(payment models.Payment, order models.Order) interface{} {
-	// Generate virtual account
-	virtualAccount := h.generateVirtualAccount()
-
-	h.db.Model(&payment).Updates(map[string]interface{}{
-		"status": string(models.PaymentStatusPending),
 	})
 
-	return fiber.Map{
-		"payment_id":      payment.ID,
-		"transaction_id":  payment.TransactionID,
-		"method":          "bank_transfer",
-		"virtual_account": virtualAccount,
-		"amount":          payment.Amount,
-		"currency":        "IDR",
-		"status":          "pending_transfer",
-		"bank_name":       "Bank SiipCoffee",
-		"account_name":    "SiipCoffee Cafe",
-		"expires_at":      "2024-01-01T23:59:59Z", // 24 hours from now
-	}
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Payment processed successfully",
+		"data": instructions,
+	})
 }
 
 func (h *PaymentHandler) GetPaymentStatus(c *fiber.Ctx) error {
@@ -187,7 +191,7 @@ func (h *PaymentHandler) GetPaymentStatus(c *fiber.Ctx) error {
 
 	// Get order with payment
 	var order models.Order
-	err := h.db.Preload("Payment").Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error
+	err := h.db.Preload("Payment").Preload("Payment.Refunds.Items").Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -216,16 +220,114 @@ func (h *PaymentHandler) GetPaymentStatus(c *fiber.Ctx) error {
 			"amount":          order.Payment.Amount,
 			"transaction_id":  order.Payment.TransactionID,
 			"confirmed_at":    order.Payment.ConfirmedAt,
+			"expires_at":      order.Payment.ExpiresAt,
+			"refunded_amount": order.Payment.RefundedAmount,
+			"refunds":         order.Payment.Refunds,
+		},
+	})
+}
+
+// HandleWebhook receives signed payment updates from a payment provider
+func (h *PaymentHandler) HandleWebhook(c *fiber.Ctx) error {
+	provider, ok := h.providers.Get(c.Params("provider"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unknown payment provider",
+		})
+	}
+
+	event, err := provider.ParseWebhook(c.Body(), c.Get(gateway.SignatureHeader))
+	if err != nil {
+		if errors.Is(err, gateway.ErrInvalidSignature) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid webhook signature",
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid webhook payload",
+			"message": err.Error(),
+		})
+	}
+
+	query := h.db.Where("method = ?", provider.Method())
+	if event.PaymentID != "" {
+		query = query.Where("id = ?", event.PaymentID)
+	} else {
+		query = query.Where("transaction_id = ?", event.TransactionID)
+	}
+
+	var payment models.Payment
+	if err := query.First(&payment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Payment not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch payment",
+			"message": err.Error(),
+		})
+	}
+
+	var changed bool
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if event.Status == gateway.EventStatusPaid {
+			changed, err = markPaymentPaid(tx, &payment, event.Reference)
+		} else {
+			changed, err = markPaymentFailed(tx, &payment, event.Reference)
+		}
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errPaymentNotPending) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Payment is no longer pending",
+				"payment_status": payment.Status,
+			})
+		}
+		if errors.Is(err, errOrderCancelled) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Order has been cancelled",
+				"payment_status": payment.Status,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update payment",
+			"message": err.Error(),
+		})
+	}
+
+	message := "Payment updated successfully"
+	if !changed {
+		message = "Payment update already processed"
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": message,
+		"data": fiber.Map{
+			"payment_id":     payment.ID,
+			"order_id":       payment.OrderID,
+			"payment_status": payment.Status,
 		},
 	})
 }
 
+// ConfirmPayment lets the cafe mark a payment as paid by hand, e.g. cash received at the counter (owner, managers and cashiers)
 func (h *PaymentHandler) ConfirmPayment(c *fiber.Ctx) error {
-	// This endpoint would be called by payment gateway webhook or admin
 	paymentID := c.Params("paymentId")
 
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
 	var payment models.Payment
-	err := h.db.First(&payment, "id = ?", paymentID).Error
+	cafeOrders := h.db.Model(&models.Order{}).Select("id").Where("cafe_id = ?", cafe.ID)
+	err := h.db.First(&payment, "id = ? AND order_id IN (?)", paymentID, cafeOrders).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -238,22 +340,163 @@ func (h *PaymentHandler) ConfirmPayment(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update payment status
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		_, err := markPaymentPaid(tx, &payment, "manual:"+c.Locals("user_id").(string))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errPaymentNotPending) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Payment is no longer pending",
+				"payment_status": payment.Status,
+			})
+		}
+		if errors.Is(err, errOrderCancelled) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Order has been cancelled",
+				"payment_status": payment.Status,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to confirm payment",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Payment confirmed successfully",
+	})
+}
+
+// errActivePayment is returned when an order already has an active payment
+var errActivePayment = errors.New("order already has an active payment")
+
+// errPaymentNotPending is returned when a payment update arrives for a
+// payment that already reached a different final status.
+var errPaymentNotPending = errors.New("payment is not pending")
+
+// errOrderCancelled is returned when a payment arrives for an order that has
+// been cancelled
+var errOrderCancelled = errors.New("order is cancelled")
+
+// markPaymentPaid marks a pending payment and its order as paid. It reports
+// false without error when the payment was already paid.
+func markPaymentPaid(tx *gorm.DB, payment *models.Payment, reference string) (bool, error) {
+	if payment.Status == string(models.PaymentStatusPaid) {
+		return false, nil
+	}
+
+	// A cancelled order no longer takes payments; the gateway is told so and
+	// the payment stays pending until it expires
+	var order models.Order
+	if err := tx.Select("id", "status").First(&order, "id = ?", payment.OrderID).Error; err != nil {
+		return false, err
+	}
+	if models.OrderStatus(order.Status) == models.OrderStatusCancelled {
+		return false, errOrderCancelled
+	}
+
 	now := time.Now()
-	h.db.Model(&payment).Updates(map[string]interface{}{
+	updates := map[string]interface{}{
 		"status":       string(models.PaymentStatusPaid),
 		"confirmed_at": &now,
-	})
+	}
+	if reference != "" {
+		updates["provider_reference"] = reference
+	}
 
-	// Update order payment status
-	h.db.Model(&models.Order{}).Where("id = ?", payment.OrderID).Updates(map[string]interface{}{
+	result := tx.Model(&models.Payment{}).
+		Where("id = ? AND status = ?", payment.ID, string(models.PaymentStatusPending)).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, errPaymentNotPending
+	}
+
+	err := tx.Model(&models.Order{}).Where("id = ?", payment.OrderID).Updates(map[string]interface{}{
 		"payment_status": string(models.PaymentStatusPaid),
+		"payment_id":     payment.ID,
+	}).Error
+	if err != nil {
+		return false, err
+	}
+
+	payment.Status = string(models.PaymentStatusPaid)
+	payment.ConfirmedAt = &now
+
+	err = publishOrderEvent(tx, payment.OrderID, models.OrderEventPayment, fiber.Map{
+		"payment_id":     payment.ID,
+		"payment_status": payment.Status,
+		"method":         payment.Method,
+		"amount":         payment.Amount,
+		"confirmed_at":   payment.ConfirmedAt,
 	})
+	return err == nil, err
+}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Payment confirmed successfully",
+// markPaymentFailed marks a pending payment and its order payment as failed.
+// It reports false without error when the payment had already failed.
+func markPaymentFailed(tx *gorm.DB, payment *models.Payment, reference string) (bool, error) {
+	if payment.Status == string(models.PaymentStatusFailed) {
+		return false, nil
+	}
+
+	updates := map[string]interface{}{
+		"status": string(models.PaymentStatusFailed),
+	}
+	if reference != "" {
+		updates["provider_reference"] = reference
+	}
+
+	result := tx.Model(&models.Payment{}).
+		Where("id = ? AND status = ?", payment.ID, string(models.PaymentStatusPending)).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, errPaymentNotPending
+	}
+
+	err := tx.Model(&models.Order{}).
+		Where("id = ? AND payment_id = ?", payment.OrderID, payment.ID).
+		Update("payment_status", string(models.PaymentStatusFailed)).Error
+	if err != nil {
+		return false, err
+	}
+
+	payment.Status = string(models.PaymentStatusFailed)
+
+	err = publishOrderEvent(tx, payment.OrderID, models.OrderEventPayment, fiber.Map{
+		"payment_id":     payment.ID,
+		"payment_status": payment.Status,
+		"method":         payment.Method,
+		"amount":         payment.Amount,
 	})
+	return err == nil, err
+}
+
+// paymentExpiry returns when a new payment expires, using the cafe's setting
+// for the method if present and the provider default otherwise
+func (h *PaymentHandler) paymentExpiry(provider gateway.Provider, cafeID string) *time.Time {
+	window := provider.Expiry()
+
+	var cafe models.Cafe
+	if err := h.db.Select("id", "settings").First(&cafe, "id = ?", cafeID).Error; err == nil {
+		if custom, ok := cafe.PaymentExpiry(provider.Method()); ok {
+			window = custom
+		}
+	}
+
+	if window <= 0 {
+		return nil
+	}
+
+	expiresAt := time.Now().Add(window)
+	return &expiresAt
 }
 
 func (h *PaymentHandler) generateTransactionID() string {
@@ -261,14 +504,3 @@ func (h *PaymentHandler) generateTransactionID() string {
 	random := uuid.New().String()[:8]
 	return fmt.Sprintf("TXN-%s-%s", timestamp, random)
 }
-
-func (h *PaymentHandler) generateCryptoAddress() string {
-	// In production, generate actual cryptocurrency address
-	// For demo purposes, return a mock address
-	return fmt.Sprintf("bc1q%s", uuid.New().String()[:32])
-}
-
-func (h *PaymentHandler) generateVirtualAccount() string {
-	// Generate virtual account number
-	return fmt.Sprintf("8808%08d", uuid.New().ID())
-}
\ No newline at end of file
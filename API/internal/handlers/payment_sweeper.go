@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// sweepBatchSize limits how many expired payments are handled per sweep
+const sweepBatchSize = 100
+
+// PaymentSweeper periodically expires pending payments that are past their
+// expiry time and cancels the unpaid orders they belong to.
+type PaymentSweeper struct {
+	db       *gorm.DB
+	interval time.Duration
+}
+
+func NewPaymentSweeper(db *gorm.DB, interval time.Duration) *PaymentSweeper {
+	return &PaymentSweeper{
+		db:       db,
+		interval: interval,
+	}
+}
+
+// Start runs the sweeper in the background
+func (s *PaymentSweeper) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if expired, err := s.Sweep(); err != nil {
+				log.Printf("Payment sweeper failed: %v", err)
+			} else if expired > 0 {
+				log.Printf("Payment sweeper expired %d payment(s)", expired)
+			}
+		}
+	}()
+}
+
+// Sweep expires all pending payments past their expiry time and returns how
+// many were expired. A payment that fails to expire is logged and skipped, so
+// it does not hold up the others; the next sweep tries it again.
+func (s *PaymentSweeper) Sweep() (int, error) {
+	expired := 0
+	var skipped []string
+
+	for {
+		query := quietDB(s.db).Where("status = ? AND expires_at IS NOT NULL AND expires_at < ?", string(models.PaymentStatusPending), time.Now())
+		if len(skipped) > 0 {
+			query = query.Where("id NOT IN ?", skipped)
+		}
+
+		var payments []models.Payment
+		err := query.Order("expires_at ASC").
+			Limit(sweepBatchSize).
+			Find(&payments).Error
+		if err != nil {
+			return expired, err
+		}
+
+		for i := range payments {
+			err := s.db.Transaction(func(tx *gorm.DB) error {
+				return expirePayment(tx, &payments[i])
+			})
+			if err != nil {
+				log.Printf("Payment sweeper could not expire payment %s: %v", payments[i].ID, err)
+				skipped = append(skipped, payments[i].ID)
+				continue
+			}
+			expired++
+		}
+
+		if len(payments) < sweepBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// expirePayment marks a pending payment as expired. When it is the current
+// payment of an order that is still pending, the order is cancelled, which
+// also releases its reserved stock and redeemed rewards.
+func expirePayment(tx *gorm.DB, payment *models.Payment) error {
+	result := tx.Model(&models.Payment{}).
+		Where("id = ? AND status = ?", payment.ID, string(models.PaymentStatusPending)).
+		Update("status", string(models.PaymentStatusExpired))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Paid or failed in the meantime
+		return nil
+	}
+	payment.Status = string(models.PaymentStatusExpired)
+
+	var order models.Order
+	if err := tx.First(&order, "id = ?", payment.OrderID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	// The customer may have started another payment for the same order
+	if order.PaymentID != payment.ID || order.PaymentStatus == string(models.PaymentStatusPaid) {
+		return nil
+	}
+
+	err := tx.Model(&order).Update("payment_status", string(models.PaymentStatusExpired)).Error
+	if err != nil {
+		return err
+	}
+
+	err = publishOrderEvent(tx, order.ID, models.OrderEventPayment, fiber.Map{
+		"payment_id":     payment.ID,
+		"payment_status": payment.Status,
+		"method":         payment.Method,
+		"amount":         payment.Amount,
+	})
+	if err != nil {
+		return err
+	}
+
+	if models.OrderStatus(order.Status) != models.OrderStatusPending {
+		return nil
+	}
+
+	return changeOrderStatus(tx, &order, models.OrderStatusCancelled, systemActor, "Payment expired")
+}
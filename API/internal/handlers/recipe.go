@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RecipeItemRequest struct {
+	InventoryID string  `json:"inventory_id"`
+	OptionID    *string `json:"option_id"` // only used when this option is chosen
+	Quantity    float64 `json:"quantity"`
+}
+
+type UpdateRecipeRequest struct {
+	Items []RecipeItemRequest `json:"items"`
+}
+
+// GetRecipe returns the inventory items one portion of a menu item uses (owner only)
+func (h *MenuHandler) GetRecipe(c *fiber.Ctx) error {
+	menu, ferr := h.ownedMenu(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	recipe, err := h.menuRecipe(menu.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to fetch recipe",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    recipeResponse(menu, recipe),
+	})
+}
+
+// UpdateRecipe replaces the recipe of a menu item (owner only)
+func (h *MenuHandler) UpdateRecipe(c *fiber.Ctx) error {
+	menu, ferr := h.ownedMenu(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	var req UpdateRecipeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"message": err.Error(),
+		})
+	}
+
+	recipe, ferr := h.buildRecipe(menu, req.Items)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("menu_id = ?", menu.ID).Delete(&models.RecipeItem{}).Error; err != nil {
+			return err
+		}
+		if len(recipe) > 0 {
+			if err := tx.Omit("Inventory").Create(&recipe).Error; err != nil {
+				return err
+			}
+		}
+		return refreshMenusSoldOut(tx, menu.ID)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to update recipe",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Recipe updated successfully",
+		"data":    recipeResponse(menu, recipe),
+	})
+}
+
+// buildRecipe checks the requested recipe lines against the cafe's inventory
+// and the menu's options. Base lines must use a positive quantity; option
+// lines may be negative to take out part of the base recipe.
+func (h *MenuHandler) buildRecipe(menu *models.Menu, requests []RecipeItemRequest) ([]models.RecipeItem, *fiber.Error) {
+	inventoryIDs := make([]string, 0, len(requests))
+	for _, req := range requests {
+		inventoryIDs = append(inventoryIDs, req.InventoryID)
+	}
+
+	var inventories []models.Inventory
+	if err := h.db.Scopes(inCafe(menu.CafeID)).Where("id IN ?", inventoryIDs).Find(&inventories).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch inventory items")
+	}
+	inventoryByID := make(map[string]models.Inventory, len(inventories))
+	for _, inventory := range inventories {
+		inventoryByID[inventory.ID] = inventory
+	}
+
+	var optionIDs []string
+	err := h.db.Model(&models.MenuOption{}).
+		Joins("JOIN menu_option_groups ON menu_option_groups.id = menu_options.group_id AND menu_option_groups.deleted_at IS NULL").
+		Where("menu_option_groups.menu_id = ?", menu.ID).
+		Pluck("menu_options.id", &optionIDs).Error
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch menu options")
+	}
+	options := make(map[string]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		options[id] = true
+	}
+
+	recipe := make([]models.RecipeItem, 0, len(requests))
+	seen := make(map[string]bool, len(requests))
+	for _, req := range requests {
+		inventory, ok := inventoryByID[req.InventoryID]
+		if !ok {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Inventory item "+req.InventoryID+" not found")
+		}
+
+		key := req.InventoryID
+		if req.OptionID != nil && *req.OptionID != "" {
+			if !options[*req.OptionID] {
+				return nil, fiber.NewError(fiber.StatusBadRequest, "Option "+*req.OptionID+" does not belong to this menu")
+			}
+			if req.Quantity == 0 {
+				return nil, fiber.NewError(fiber.StatusBadRequest, "Quantity of "+inventory.Name+" must not be zero")
+			}
+			key += "/" + *req.OptionID
+		} else {
+			req.OptionID = nil
+			if req.Quantity <= 0 {
+				return nil, fiber.NewError(fiber.StatusBadRequest, "Quantity of "+inventory.Name+" must be greater than zero")
+			}
+		}
+
+		if seen[key] {
+			return nil, fiber.NewError(fiber.StatusBadRequest, inventory.Name+" is listed more than once")
+		}
+		seen[key] = true
+
+		recipe = append(recipe, models.RecipeItem{
+			ID:          uuid.New().String(),
+			MenuID:      menu.ID,
+			OptionID:    req.OptionID,
+			InventoryID: inventory.ID,
+			Quantity:    req.Quantity,
+			Inventory:   inventory,
+		})
+	}
+
+	return recipe, nil
+}
+
+// menuRecipe loads the recipe lines of a menu item, base lines first
+func (h *MenuHandler) menuRecipe(menuID string) ([]models.RecipeItem, error) {
+	var recipe []models.RecipeItem
+	err := h.db.Preload("Inventory").
+		Where("menu_id = ?", menuID).
+		Order("option_id IS NOT NULL, created_at ASC").
+		Find(&recipe).Error
+	return recipe, err
+}
+
+// recipeResponse lists the recipe lines with the ingredient cost of a portion
+// without options
+func recipeResponse(menu *models.Menu, recipe []models.RecipeItem) fiber.Map {
+	items := make([]models.RecipeItemResponse, 0, len(recipe))
+	var cost float64
+	for _, line := range recipe {
+		items = append(items, line.ToResponse())
+		if line.OptionID == nil {
+			cost += line.Quantity * line.Inventory.UnitCost
+		}
+	}
+
+	return fiber.Map{
+		"menu_id":      menu.ID,
+		"items":        items,
+		"portion_cost": cost,
+	}
+}
+
+// carryOverOptionRecipes moves the recipe lines of replaced options to the new
+// option with the same name and drops the lines of options that are gone
+func carryOverOptionRecipes(tx *gorm.DB, previous, current []models.MenuOption) error {
+	byName := make(map[string]string, len(current))
+	for _, option := range current {
+		byName[option.Name] = option.ID
+	}
+
+	for _, option := range previous {
+		lines := tx.Model(&models.RecipeItem{}).Where("option_id = ?", option.ID)
+		var err error
+		if id, ok := byName[option.Name]; ok {
+			err = lines.Update("option_id", id).Error
+		} else {
+			err = lines.Delete(&models.RecipeItem{}).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+
+	"siipcoffe-api/internal/models"
+	"siipcoffe-api/pkg/gateway"
+	"siipcoffe-api/pkg/pricing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// errRefundConflict is returned when the payment was refunded by someone else
+// while a refund was being processed
+var errRefundConflict = errors.New("payment was refunded concurrently")
+
+type RefundItemRequest struct {
+	OrderItemID string `json:"order_item_id" validate:"required"`
+	Quantity    int    `json:"quantity" validate:"required,gt=0"`
+}
+
+type RefundPaymentRequest struct {
+	Items  []RefundItemRequest `json:"items"` // empty = refund everything not refunded yet
+	Reason string              `json:"reason"`
+}
+
+// RefundPayment refunds a paid payment in full or for selected order items (owner only)
+func (h *PaymentHandler) RefundPayment(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	paymentID := c.Params("paymentId")
+
+	var req RefundPaymentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+			"message": err.Error(),
+		})
+	}
+
+	var payment models.Payment
+	err := h.db.First(&payment, "id = ?", paymentID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Payment not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch payment",
+			"message": err.Error(),
+		})
+	}
+
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"error": ferr.Message,
+		})
+	}
+
+	var order models.Order
+	err = h.db.Preload("OrderItems").
+		Scopes(inCafe(cafe.ID)).
+		Where("id = ?", payment.OrderID).
+		First(&order).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Payment not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch order",
+			"message": err.Error(),
+		})
+	}
+
+	if payment.Status != string(models.PaymentStatusPaid) && payment.Status != string(models.PaymentStatusPartiallyRefunded) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Only paid payments can be refunded",
+			"payment_status": payment.Status,
+		})
+	}
+
+	provider, ok := h.providers.Get(payment.Method)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Payment method does not support refunds",
+			"method": payment.Method,
+		})
+	}
+
+	// A refund left pending by an earlier request is finished first; the
+	// provider recognises it by its ID, so the money is only sent once
+	var pending models.Refund
+	err = h.db.Preload("Items").Where("payment_id = ? AND status = ?", payment.ID, models.RefundStatusPending).First(&pending).Error
+	if err == nil {
+		return h.sendRefund(c, provider, &payment, &order, &pending)
+	}
+	if err != gorm.ErrRecordNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check pending refunds",
+			"message": err.Error(),
+		})
+	}
+
+	remaining := pricing.Round(payment.Amount - payment.RefundedAmount)
+
+	refund := models.Refund{
+		ID:         uuid.New().String(),
+		PaymentID:  payment.ID,
+		OrderID:    order.ID,
+		CafeID:     order.CafeID,
+		Method:     payment.Method,
+		Reason:     req.Reason,
+		Status:     models.RefundStatusPending,
+		RefundedBy: userID,
+	}
+
+	if len(req.Items) == 0 {
+		refund.Amount = remaining
+	} else {
+		items, amount, fiberErr := h.refundItems(&order, req.Items)
+		if fiberErr != nil {
+			return c.Status(fiberErr.Code).JSON(fiber.Map{
+				"error": fiberErr.Message,
+			})
+		}
+		refund.Items = items
+		refund.Amount = math.Min(amount, remaining)
+	}
+
+	if refund.Amount <= 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Nothing left to refund",
+			"refunded_amount": payment.RefundedAmount,
+		})
+	}
+
+	// Reserve the amount and record the refund before any money moves
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// Only apply the refund if nobody refunded this payment in the meantime
+		result := tx.Model(&models.Payment{}).
+			Where("id = ? AND refunded_amount = ?", payment.ID, payment.RefundedAmount).
+			Update("refunded_amount", pricing.Round(payment.RefundedAmount+refund.Amount))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefundConflict
+		}
+
+		return tx.Create(&refund).Error
+	})
+	if err != nil {
+		if errors.Is(err, errRefundConflict) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Payment was refunded by another request, please retry",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to refund payment",
+			"message": err.Error(),
+		})
+	}
+
+	return h.sendRefund(c, provider, &payment, &order, &refund)
+}
+
+// sendRefund asks the provider to send a pending refund and records the
+// outcome. A failed refund gives its amount back to the payment; a completed
+// one updates the payment status and reverses points and stock.
+func (h *PaymentHandler) sendRefund(c *fiber.Ctx, provider gateway.Provider, payment *models.Payment, order *models.Order, refund *models.Refund) error {
+	reference, err := provider.Refund(payment, refund.Amount, refund.ID)
+	if err != nil {
+		failErr := h.db.Transaction(func(tx *gorm.DB) error {
+			return failRefund(tx, refund)
+		})
+		if failErr != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to record failed refund",
+				"message": failErr.Error(),
+			})
+		}
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Payment provider could not refund the payment",
+			"message": err.Error(),
+		})
+	}
+
+	actor := statusActor{ID: c.Locals("user_id").(string), Role: actingRole(c)}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		return completeRefund(tx, payment, order, refund, reference, actor)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Refund was sent but could not be recorded, retry to finish it",
+			"message": err.Error(),
+			"refund_id": refund.ID,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Payment refunded successfully",
+		"data": fiber.Map{
+			"refund":          refund,
+			"payment_status":  payment.Status,
+			"refunded_amount": payment.RefundedAmount,
+			"remaining":       pricing.Round(payment.Amount - payment.RefundedAmount),
+		},
+	})
+}
+
+// failRefund marks a pending refund as failed and releases its amount
+func failRefund(tx *gorm.DB, refund *models.Refund) error {
+	result := tx.Model(&models.Refund{}).
+		Where("id = ? AND status = ?", refund.ID, models.RefundStatusPending).
+		Update("status", models.RefundStatusFailed)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Finished by another request
+		return nil
+	}
+	refund.Status = models.RefundStatusFailed
+
+	return tx.Model(&models.Payment{}).
+		Where("id = ?", refund.PaymentID).
+		Update("refunded_amount", gorm.Expr("refunded_amount - ?", refund.Amount)).Error
+}
+
+// completeRefund marks a pending refund as completed once the provider has
+// sent the money, then updates the payment and order and takes back the
+// points and stock of what was refunded
+func completeRefund(tx *gorm.DB, payment *models.Payment, order *models.Order, refund *models.Refund, reference string, actor statusActor) error {
+	result := tx.Model(&models.Refund{}).
+		Where("id = ? AND status = ?", refund.ID, models.RefundStatusPending).
+		Updates(map[string]interface{}{
+			"status":             models.RefundStatusCompleted,
+			"provider_reference": reference,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	completed := result.RowsAffected > 0
+
+	// The reserved amount is already part of refunded_amount
+	if err := tx.First(payment, "id = ?", payment.ID).Error; err != nil {
+		return err
+	}
+	if !completed {
+		// Finished by another request
+		return tx.First(refund, "id = ?", refund.ID).Error
+	}
+	refund.Status = models.RefundStatusCompleted
+	refund.ProviderReference = reference
+
+	status := models.PaymentStatusPartiallyRefunded
+	if pricing.Round(payment.RefundedAmount) >= pricing.Round(payment.Amount) {
+		status = models.PaymentStatusRefunded
+	}
+
+	if err := tx.Model(payment).Update("status", string(status)).Error; err != nil {
+		return err
+	}
+	payment.Status = string(status)
+
+	err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("payment_status", string(status)).Error
+	if err != nil {
+		return err
+	}
+
+	share := refund.Amount / payment.Amount
+	points, err := reverseOrderPoints(tx, order.ID, refund.ID, share, status == models.PaymentStatusRefunded)
+	if err != nil {
+		return err
+	}
+	refund.PointsReversed = points
+	if err := tx.Model(refund).Update("points_reversed", points).Error; err != nil {
+		return err
+	}
+
+	// Ingredients of the refunded items go back into stock
+	if len(refund.Items) == 0 || status == models.PaymentStatusRefunded {
+		err = returnOrderStock(tx, order.ID, "refund", "Stock returned from refunded order", actor)
+	} else {
+		err = returnRefundedStock(tx, order, refund.Items, actor)
+	}
+	if err != nil {
+		return err
+	}
+
+	return publishOrderEvent(tx, order.ID, models.OrderEventPayment, fiber.Map{
+		"payment_id":      payment.ID,
+		"payment_status":  payment.Status,
+		"method":          payment.Method,
+		"amount":          payment.Amount,
+		"refunded_amount": payment.RefundedAmount,
+	})
+}
+
+// refundItems builds the refund lines for the requested order items. Each line
+// includes the item's share of tax and service charge; the delivery fee is only
+// returned by a full refund.
+func (h *PaymentHandler) refundItems(order *models.Order, requested []RefundItemRequest) ([]models.RefundItem, float64, *fiber.Error) {
+	orderItems := make(map[string]models.OrderItem, len(order.OrderItems))
+	itemIDs := make([]string, 0, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		orderItems[item.ID] = item
+		itemIDs = append(itemIDs, item.ID)
+	}
+
+	// Quantities already refunded per order item
+	var previous []struct {
+		OrderItemID string
+		Quantity    int
+	}
+	h.db.Model(&models.RefundItem{}).
+		Select("refund_items.order_item_id, SUM(refund_items.quantity) AS quantity").
+		Joins("JOIN refunds ON refunds.id = refund_items.refund_id").
+		Where("refund_items.order_item_id IN ? AND refunds.status <> ?", itemIDs, models.RefundStatusFailed).
+		Group("refund_items.order_item_id").
+		Scan(&previous)
+
+	refunded := make(map[string]int, len(previous))
+	for _, p := range previous {
+		refunded[p.OrderItemID] = p.Quantity
+	}
+
+	chargeRate := 1.0
+	if order.SubtotalAmount > 0 {
+		chargeRate = (order.SubtotalAmount + order.ServiceCharge + order.TaxAmount) / order.SubtotalAmount
+	}
+
+	var items []models.RefundItem
+	var total float64
+	for _, req := range requested {
+		item, ok := orderItems[req.OrderItemID]
+		if !ok {
+			return nil, 0, fiber.NewError(fiber.StatusBadRequest, "Order item "+req.OrderItemID+" does not belong to this order")
+		}
+		if req.Quantity < 1 {
+			return nil, 0, fiber.NewError(fiber.StatusBadRequest, "Refund quantity must be at least 1")
+		}
+		if refunded[item.ID]+req.Quantity > item.Quantity {
+			return nil, 0, fiber.NewError(fiber.StatusConflict, "Refund quantity exceeds the quantity left to refund for order item "+item.ID)
+		}
+		refunded[item.ID] += req.Quantity
+
+		amount := pricing.Round(item.UnitPrice * float64(req.Quantity) * chargeRate)
+		items = append(items, models.RefundItem{
+			ID:          uuid.New().String(),
+			OrderItemID: item.ID,
+			Quantity:    req.Quantity,
+			Amount:      amount,
+		})
+		total += amount
+	}
+
+	return items, total, nil
+}
+
+// reverseOrderPoints takes back the loyalty points earned on an order in
+// proportion to the refunded share of the payment. The final refund reverses
+// whatever is left so the order ends with no points.
+func reverseOrderPoints(tx *gorm.DB, orderID, refundID string, share float64, final bool) (int, error) {
+	var earned []models.LoyaltyTransaction
+	err := tx.Where("order_id = ? AND type = ?", orderID, "earned").Find(&earned).Error
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, e := range earned {
+		var alreadyReversed int
+		tx.Model(&models.LoyaltyTransaction{}).
+			Select("COALESCE(-SUM(points), 0)").
+			Where("order_id = ? AND member_id = ? AND type = ?", orderID, e.MemberID, "refunded").
+			Scan(&alreadyReversed)
+
+		left := e.Points - alreadyReversed
+		points := int(math.Round(float64(e.Points) * share))
+		if final || points > left {
+			points = left
+		}
+		if points <= 0 {
+			continue
+		}
+
+		var member models.LoyaltyMember
+		if err := tx.First(&member, "id = ?", e.MemberID).Error; err != nil {
+			return 0, err
+		}
+
+		balance := member.CurrentPoints - points
+		if balance < 0 {
+			balance = 0
+		}
+
+		err := tx.Model(&member).Updates(map[string]interface{}{
+			"current_points": balance,
+			"total_earned":   gorm.Expr("total_earned - ?", points),
+		}).Error
+		if err != nil {
+			return 0, err
+		}
+
+		transaction := models.LoyaltyTransaction{
+			ID:           uuid.New().String(),
+			ProgramID:    e.ProgramID,
+			MemberID:     e.MemberID,
+			CafeID:       e.CafeID,
+			OrderID:      orderID,
+			Type:         "refunded",
+			Points:       -points,
+			BalanceAfter: balance,
+			Description:  "Points reversed for refunded order",
+			ReferenceID:  refundID,
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return 0, err
+		}
+
+		total += points
+	}
+
+	return total, nil
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ReviewHandler struct {
+	db *gorm.DB
+}
+
+func NewReviewHandler(db *gorm.DB) *ReviewHandler {
+	return &ReviewHandler{db: db}
+}
+
+type ReviewReplyRequest struct {
+	Reply string `json:"reply"`
+}
+
+type ReportReviewRequest struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+// ReportedReview is a review in the moderation queue with its reports
+type ReportedReview struct {
+	Review  models.CafeReviewResponse `json:"review"`
+	Reports []models.ReviewReport     `json:"reports"`
+}
+
+// ReplyToReview posts or replaces the cafe's public reply to a review (owner
+// only). An empty reply removes it.
+func (h *ReviewHandler) ReplyToReview(c *fiber.Ctx) error {
+	var req ReviewReplyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var review models.CafeReview
+	if err := h.db.Scopes(inCafe(cafe.ID)).First(&review, "id = ?", c.Params("id")).Error; err != nil {
+		return reviewError(c, err)
+	}
+
+	updates := map[string]interface{}{
+		"owner_reply":    req.Reply,
+		"owner_reply_at": nil,
+	}
+	if req.Reply != "" {
+		updates["owner_reply_at"] = time.Now()
+	}
+
+	if err := h.db.Model(&models.CafeReview{}).Where("id = ?", review.ID).Updates(updates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to save reply",
+		})
+	}
+
+	h.db.Preload("User").Preload("ItemRatings.Menu").First(&review, "id = ?", review.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    review.ToResponse(),
+	})
+}
+
+// ReportReview puts a review in the moderation queue. Each user can report a
+// review once.
+func (h *ReviewHandler) ReportReview(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var req ReportReviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+	if !models.IsValidReportReason(req.Reason) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success":       false,
+			"error":         "Invalid report reason",
+			"valid_reasons": models.ReportReasons,
+		})
+	}
+
+	var review models.CafeReview
+	if err := h.db.Where("id = ? AND status = ?", c.Params("id"), models.ReviewStatusPublished).First(&review).Error; err != nil {
+		return reviewError(c, err)
+	}
+	if review.UserID == userID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "You cannot report your own review",
+		})
+	}
+
+	var existing int64
+	h.db.Model(&models.ReviewReport{}).Where("review_id = ? AND reporter_id = ?", review.ID, userID).Count(&existing)
+	if existing > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   "You have already reported this review",
+		})
+	}
+
+	report := models.ReviewReport{
+		ID:         uuid.New().String(),
+		ReviewID:   review.ID,
+		ReporterID: userID,
+		Reason:     req.Reason,
+		Details:    req.Details,
+		Status:     models.ReportStatusOpen,
+	}
+	if err := h.db.Create(&report).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to report review",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Review reported, a moderator will look at it",
+		"data":    report,
+	})
+}
+
+// GetReportQueue lists reported reviews with their reports, most reported
+// first (admin only)
+func (h *ReviewHandler) GetReportQueue(c *fiber.Ctx) error {
+	status := c.Query("status", models.ReportStatusOpen)
+
+	var queue []struct {
+		ReviewID string
+		Reports  int64
+	}
+	err := h.db.Model(&models.ReviewReport{}).
+		Select("review_id, COUNT(*) as reports").
+		Where("status = ?", status).
+		Group("review_id").
+		Order("reports DESC, MIN(created_at) ASC").
+		Scan(&queue).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to get reported reviews",
+		})
+	}
+
+	reviewIDs := make([]string, 0, len(queue))
+	for _, row := range queue {
+		reviewIDs = append(reviewIDs, row.ReviewID)
+	}
+
+	var reviews []models.CafeReview
+	h.db.Preload("User").Preload("ItemRatings.Menu").Where("id IN ?", reviewIDs).Find(&reviews)
+	var reports []models.ReviewReport
+	h.db.Where("review_id IN ? AND status = ?", reviewIDs, status).Order("created_at ASC").Find(&reports)
+
+	reviewsByID := make(map[string]*models.CafeReview, len(reviews))
+	for i := range reviews {
+		reviewsByID[reviews[i].ID] = &reviews[i]
+	}
+	reportsByReview := make(map[string][]models.ReviewReport, len(queue))
+	for _, report := range reports {
+		reportsByReview[report.ReviewID] = append(reportsByReview[report.ReviewID], report)
+	}
+
+	result := make([]ReportedReview, 0, len(queue))
+	for _, row := range queue {
+		review, ok := reviewsByID[row.ReviewID]
+		if !ok {
+			continue
+		}
+		result = append(result, ReportedReview{
+			Review:  review.ToResponse(),
+			Reports: reportsByReview[row.ReviewID],
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// HideReview takes a review down and removes it from the cafe rating. Its
+// open reports are marked as actioned (admin only).
+func (h *ReviewHandler) HideReview(c *fiber.Ctx) error {
+	return h.moderate(c, models.ReviewStatusPublished, models.ReviewStatusHidden, -1, models.ReportStatusActioned)
+}
+
+// RestoreReview publishes a hidden review again (admin only)
+func (h *ReviewHandler) RestoreReview(c *fiber.Ctx) error {
+	return h.moderate(c, models.ReviewStatusHidden, models.ReviewStatusPublished, 1, "")
+}
+
+// DismissReports closes the open reports of a review and keeps it published
+// (admin only)
+func (h *ReviewHandler) DismissReports(c *fiber.Ctx) error {
+	var review models.CafeReview
+	if err := h.db.First(&review, "id = ?", c.Params("id")).Error; err != nil {
+		return reviewError(c, err)
+	}
+
+	if err := resolveReviewReports(h.db, review.ID, c.Locals("user_id").(string), models.ReportStatusDismissed); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to dismiss reports",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Reports dismissed",
+	})
+}
+
+// moderate moves a review from one status to another, keeping the cafe rating
+// in step, and resolves its open reports when reportStatus is set
+func (h *ReviewHandler) moderate(c *fiber.Ctx, from, to string, ratingDelta int, reportStatus string) error {
+	var review models.CafeReview
+	if err := h.db.First(&review, "id = ?", c.Params("id")).Error; err != nil {
+		return reviewError(c, err)
+	}
+	if review.Status != from {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   "Review is already " + review.Status,
+		})
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Only the request that moves the review adjusts the rating
+		result := tx.Model(&models.CafeReview{}).Where("id = ? AND status = ?", review.ID, from).Update("status", to)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return fiber.NewError(fiber.StatusConflict, "Review is no longer "+from)
+		}
+		if err := updateCafeRating(tx, review.CafeID, review.Rating, ratingDelta); err != nil {
+			return err
+		}
+		if reportStatus != "" {
+			return resolveReviewReports(tx, review.ID, c.Locals("user_id").(string), reportStatus)
+		}
+		return nil
+	})
+	if err != nil {
+		if ferr, ok := err.(*fiber.Error); ok {
+			return c.Status(ferr.Code).JSON(fiber.Map{
+				"success": false,
+				"error":   ferr.Message,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to update review",
+		})
+	}
+
+	h.db.Preload("User").Preload("ItemRatings.Menu").First(&review, "id = ?", review.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    review.ToResponse(),
+	})
+}
+
+// resolveReviewReports closes the open reports of a review
+func resolveReviewReports(tx *gorm.DB, reviewID, adminID, status string) error {
+	return tx.Model(&models.ReviewReport{}).
+		Where("review_id = ? AND status = ?", reviewID, models.ReportStatusOpen).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"resolved_by": adminID,
+			"resolved_at": time.Now(),
+		}).Error
+}
+
+func reviewError(c *fiber.Ctx, err error) error {
+	if err == gorm.ErrRecordNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Review not found",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"error":   "Failed to get review",
+	})
+}
@@ -0,0 +1,465 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"siipcoffe-api/internal/config"
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// staffInvitationTTL is how long an invitation can be accepted
+const staffInvitationTTL = 7 * 24 * time.Hour
+
+type StaffHandler struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewStaffHandler(db *gorm.DB, cfg *config.Config) *StaffHandler {
+	return &StaffHandler{db: db, cfg: cfg}
+}
+
+type InviteStaffRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+type UpdateStaffRequest struct {
+	Role     *string `json:"role"`
+	IsActive *bool   `json:"is_active"`
+}
+
+// actingRole is the role recorded for changes the caller makes on behalf of a
+// cafe: their staff role for staff, otherwise their user role
+func actingRole(c *fiber.Ctx) string {
+	role := c.Locals("user_role").(string)
+	if staffRole, _ := c.Locals("staff_role").(string); staffRole != "" && role != "owner" {
+		return staffRole
+	}
+	return role
+}
+
+// ListStaff lists the cafe's staff and pending invitations (owner only)
+func (h *StaffHandler) ListStaff(c *fiber.Ctx) error {
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var staff []models.CafeStaff
+	if err := h.db.Preload("User").Where("cafe_id = ?", cafe.ID).Order("is_active DESC, joined_at ASC").Find(&staff).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to get staff",
+		})
+	}
+
+	var invitations []models.StaffInvitation
+	err := h.db.Where("cafe_id = ? AND status = ? AND expires_at > ?", cafe.ID, models.InvitationStatusPending, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to get staff invitations",
+		})
+	}
+
+	staffResponses := make([]models.CafeStaffResponse, 0, len(staff))
+	for _, member := range staff {
+		staffResponses = append(staffResponses, member.ToResponse())
+	}
+	invitationResponses := make([]models.StaffInvitationResponse, 0, len(invitations))
+	for _, invitation := range invitations {
+		invitationResponses = append(invitationResponses, invitation.ToResponse())
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"staff":       staffResponses,
+			"invitations": invitationResponses,
+		},
+	})
+}
+
+// InviteStaff invites someone by email to join the cafe's staff with a role.
+// Inviting the same email again updates the role and renews the invitation.
+func (h *StaffHandler) InviteStaff(c *fiber.Ctx) error {
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var req InviteStaffRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == "" || !strings.Contains(email, "@") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "A valid email is required",
+		})
+	}
+	if !models.IsValidStaffRole(req.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid staff role",
+			"roles":   models.StaffRoles,
+		})
+	}
+
+	var activeCount int64
+	h.db.Model(&models.CafeStaff{}).
+		Joins("JOIN users ON users.id = cafe_staffs.user_id").
+		Where("cafe_staffs.cafe_id = ? AND cafe_staffs.is_active = ? AND LOWER(users.email) = ?", cafe.ID, true, email).
+		Count(&activeCount)
+	if activeCount > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   "This person is already on your staff, change their role instead",
+		})
+	}
+
+	var invitation models.StaffInvitation
+	err := h.db.Where("cafe_id = ? AND email = ? AND status = ?", cafe.ID, email, models.InvitationStatusPending).First(&invitation).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to check existing invitations",
+		})
+	}
+
+	// Inviting again replaces the token, so an earlier one stops working
+	token, err := newSecretToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to create invitation",
+		})
+	}
+
+	invitation.Role = req.Role
+	invitation.Token = token
+	invitation.InvitedBy = c.Locals("user_id").(string)
+	invitation.ExpiresAt = time.Now().Add(staffInvitationTTL)
+	if invitation.ID == "" {
+		invitation.ID = uuid.New().String()
+		invitation.CafeID = cafe.ID
+		invitation.Email = email
+		invitation.Status = models.InvitationStatusPending
+	}
+
+	if err := h.db.Save(&invitation).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to create invitation",
+		})
+	}
+
+	invitation.Cafe = *cafe
+	response := invitation.ToResponse()
+	response.Token = invitation.Token
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Invitation sent",
+		"data":    response,
+	})
+}
+
+// RevokeInvitation cancels a pending invitation (owner only)
+func (h *StaffHandler) RevokeInvitation(c *fiber.Ctx) error {
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	result := h.db.Model(&models.StaffInvitation{}).
+		Where("id = ? AND cafe_id = ? AND status = ?", c.Params("id"), cafe.ID, models.InvitationStatusPending).
+		Update("status", models.InvitationStatusRevoked)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to revoke invitation",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invitation not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Invitation revoked",
+	})
+}
+
+// UpdateStaff changes a staff member's role or deactivates them (owner only).
+// Deactivated staff lose access immediately.
+func (h *StaffHandler) UpdateStaff(c *fiber.Ctx) error {
+	cafe, ferr := actingCafe(h.db, c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"error":   ferr.Message,
+		})
+	}
+
+	var req UpdateStaffRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+	if req.Role != nil && !models.IsValidStaffRole(*req.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid staff role",
+			"roles":   models.StaffRoles,
+		})
+	}
+
+	var staff models.CafeStaff
+	if err := h.db.Preload("User").First(&staff, "id = ? AND cafe_id = ?", c.Params("id"), cafe.ID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"error":   "Staff member not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to get staff member",
+		})
+	}
+
+	updates := make(map[string]interface{})
+	if req.Role != nil {
+		staff.Role = *req.Role
+		updates["role"] = staff.Role
+	}
+	if req.IsActive != nil {
+		if *req.IsActive && !staff.IsActive {
+			// Someone can only work for one cafe at a time
+			var count int64
+			h.db.Model(&models.CafeStaff{}).Where("user_id = ? AND is_active = ? AND id <> ?", staff.UserID, true, staff.ID).Count(&count)
+			if count > 0 {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"success": false,
+					"error":   "This person now works for another cafe",
+				})
+			}
+		}
+		staff.IsActive = *req.IsActive
+		updates["is_active"] = staff.IsActive
+	}
+
+	if len(updates) > 0 {
+		if err := h.db.Model(&models.CafeStaff{}).Where("id = ?", staff.ID).Updates(updates).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"error":   "Failed to update staff member",
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Staff member updated",
+		"data":    staff.ToResponse(),
+	})
+}
+
+// GetMyInvitations lists the pending invitations of the token given, if they
+// were sent to the caller's email
+func (h *StaffHandler) GetMyInvitations(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invitation token is required",
+		})
+	}
+
+	var invitations []models.StaffInvitation
+	err := h.db.Preload("Cafe").
+		Where("email = ? AND token = ? AND status = ? AND expires_at > ?", strings.ToLower(user.Email), token, models.InvitationStatusPending, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to get invitations",
+		})
+	}
+
+	responses := make([]models.StaffInvitationResponse, 0, len(invitations))
+	for _, invitation := range invitations {
+		responses = append(responses, invitation.ToResponse())
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    responses,
+	})
+}
+
+// AcceptInvitation joins the cafe's staff and returns a new token carrying the
+// cafe and staff role. The invitation token proves the caller was invited, the
+// account email alone is not verified.
+func (h *StaffHandler) AcceptInvitation(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var req AcceptInvitationRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invitation token is required",
+		})
+	}
+
+	if user.Role == "owner" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"error":   "Cafe owners cannot join another cafe's staff",
+		})
+	}
+
+	var invitation models.StaffInvitation
+	err := h.db.Preload("Cafe").
+		Where("id = ? AND email = ? AND token = ? AND status = ?", c.Params("id"), strings.ToLower(user.Email), req.Token, models.InvitationStatusPending).
+		First(&invitation).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invitation not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to get invitation",
+		})
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invitation has expired, ask the cafe to invite you again",
+		})
+	}
+
+	var staff models.CafeStaff
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var elsewhere int64
+		if err := tx.Model(&models.CafeStaff{}).Where("user_id = ? AND is_active = ? AND cafe_id <> ?", user.ID, true, invitation.CafeID).Count(&elsewhere).Error; err != nil {
+			return err
+		}
+		if elsewhere > 0 {
+			return fiber.NewError(fiber.StatusConflict, "You already work for another cafe")
+		}
+
+		now := time.Now()
+		err := tx.Where("cafe_id = ? AND user_id = ?", invitation.CafeID, user.ID).First(&staff).Error
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			staff = models.CafeStaff{
+				ID:       uuid.New().String(),
+				CafeID:   invitation.CafeID,
+				UserID:   user.ID,
+				Role:     invitation.Role,
+				IsActive: true,
+				JoinedAt: now,
+			}
+			if err := tx.Create(&staff).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			staff.Role = invitation.Role
+			staff.IsActive = true
+			staff.JoinedAt = now
+			err := tx.Model(&models.CafeStaff{}).Where("id = ?", staff.ID).Updates(map[string]interface{}{
+				"role":      staff.Role,
+				"is_active": true,
+				"joined_at": now,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// Only one request can claim the token
+		result := tx.Model(&models.StaffInvitation{}).
+			Where("id = ? AND token = ? AND status = ?", invitation.ID, req.Token, models.InvitationStatusPending).
+			Updates(map[string]interface{}{
+				"status":      models.InvitationStatusAccepted,
+				"token":       "",
+				"accepted_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return fiber.NewError(fiber.StatusConflict, "Invitation was already used")
+		}
+		return nil
+	})
+	if err != nil {
+		if ferr, ok := err.(*fiber.Error); ok {
+			return c.Status(ferr.Code).JSON(fiber.Map{
+				"success": false,
+				"error":   ferr.Message,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to accept invitation",
+		})
+	}
+
+	token, expiresAt, err := generateJWT(h.db, h.cfg, user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to generate token",
+		})
+	}
+
+	staff.User = *user
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "You joined " + invitation.Cafe.Name,
+		"data": fiber.Map{
+			"staff":      staff.ToResponse(),
+			"token":      token,
+			"expires_at": expiresAt,
+		},
+	})
+}
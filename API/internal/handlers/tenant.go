@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"siipcoffe-api/internal/middleware"
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// actingCafe returns the cafe the caller acts on. Routes resolve it once with
+// the ResolveCafe middleware; without it the cafe is looked up the same way.
+func actingCafe(db *gorm.DB, c *fiber.Ctx) (*models.Cafe, *fiber.Error) {
+	if cafe := middleware.CurrentCafe(c); cafe != nil {
+		return cafe, nil
+	}
+	return middleware.LookupCafe(db, c)
+}
+
+// inCafe limits a query on a table with a cafe_id column to one cafe
+func inCafe(cafeID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("cafe_id = ?", cafeID)
+	}
+}
+
+// ownerCafeIDs is a subquery of the IDs of every cafe an owner has
+func ownerCafeIDs(db *gorm.DB, ownerID string) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Model(&models.Cafe{}).Select("id").Where("owner_id = ?", ownerID)
+}
+
+// visibleOrders limits an order query to what the caller may see: their own
+// orders plus, for owners, the orders of all their cafes and, for staff, the
+// orders of the cafes they work for
+func visibleOrders(c *fiber.Ctx) func(*gorm.DB) *gorm.DB {
+	userID := c.Locals("user_id").(string)
+	role := c.Locals("user_role").(string)
+	staffCafeID, _ := c.Locals("cafe_id").(string)
+
+	return func(db *gorm.DB) *gorm.DB {
+		switch {
+		case role == models.RoleOwner:
+			return db.Where("user_id = ? OR cafe_id IN (?)", userID, ownerCafeIDs(db, userID))
+		case staffCafeID != "":
+			staffCafes := db.Session(&gorm.Session{NewDB: true}).Model(&models.CafeStaff{}).Select("cafe_id").Where("user_id = ? AND is_active = ?", userID, true)
+			return db.Where("user_id = ? OR cafe_id IN (?)", userID, staffCafes)
+		default:
+			return db.Where("user_id = ?", userID)
+		}
+	}
+}
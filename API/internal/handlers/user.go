@@ -239,6 +239,7 @@ func (h *UserHandler) GetUserOrders(c *fiber.Ctx) error {
 	err := query.Preload("Cafe").
 		Preload("OrderItems").
 		Preload("OrderItems.Menu").
+		Preload("OrderItems.Options").
 		Offset(offset).
 		Limit(limit).
 		Order("created_at DESC").
@@ -360,7 +361,7 @@ func (h *UserHandler) AddToFavorites(c *fiber.Ctx) error {
 
 	// Check if cafe exists
 	var cafe models.Cafe
-	err := h.db.Where("id = ? AND status = ?", cafeID, "active").First(&cafe).Error
+	err := h.db.Where("id = ? AND status = ?", cafeID, models.CafeStatusActive).First(&cafe).Error
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
@@ -16,6 +16,10 @@ func Authenticate(jwtSecret string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Get token from header
 		authHeader := c.Get("Authorization")
+		if authHeader == "" && isEventStream(c) && c.Query("token") != "" {
+			// Browsers' EventSource cannot set headers, so event streams may pass the token in the query
+			authHeader = "Bearer " + c.Query("token")
+		}
 		if authHeader == "" {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Authorization header required",
@@ -78,14 +82,37 @@ func Authenticate(jwtSecret string) fiber.Handler {
 			})
 		}
 
+		// Staff tokens also carry the cafe they work for and their staff role
+		cafeID, _ := claims["cafe_id"].(string)
+		staffRole, _ := claims["staff_role"].(string)
+
 		// Store user info in context
 		c.Locals("user_id", userID)
 		c.Locals("user_role", userRole)
+		c.Locals("cafe_id", cafeID)
+		c.Locals("staff_role", staffRole)
 
 		return c.Next()
 	}
 }
 
+// OptionalAuthenticate verifies the JWT token like Authenticate when the
+// request carries one and lets anonymous requests through
+func OptionalAuthenticate(jwtSecret string) fiber.Handler {
+	authenticate := Authenticate(jwtSecret)
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") == "" {
+			return c.Next()
+		}
+		return authenticate(c)
+	}
+}
+
+// isEventStream reports whether the request is a server-sent events subscription
+func isEventStream(c *fiber.Ctx) bool {
+	return c.Method() == fiber.MethodGet && strings.Contains(c.Get(fiber.HeaderAccept), "text/event-stream")
+}
+
 // LoadUser middleware to load user from database
 func LoadUser(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -146,4 +173,51 @@ func RequireAnyRole(roles ...string) fiber.Handler {
 			"user_role": userRole,
 		})
 	}
-}
\ No newline at end of file
+}
+
+// RequireCafeRole middleware allows cafe owners and staff whose token carries
+// one of the given staff roles. Staff membership is checked against the
+// database so deactivated staff lose access before their token expires.
+func RequireCafeRole(db *gorm.DB, staffRoles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Locals("user_role").(string) == "owner" {
+			return c.Next()
+		}
+
+		cafeID := c.Locals("cafe_id").(string)
+		staffRole := c.Locals("staff_role").(string)
+
+		allowed := false
+		for _, role := range staffRoles {
+			if staffRole == role {
+				allowed = true
+				break
+			}
+		}
+		if cafeID == "" || !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Insufficient permissions",
+				"required_roles": append([]string{"owner"}, staffRoles...),
+				"staff_role": staffRole,
+			})
+		}
+
+		var count int64
+		err := db.Model(&models.CafeStaff{}).
+			Where("cafe_id = ? AND user_id = ? AND role = ? AND is_active = ?", cafeID, c.Locals("user_id").(string), staffRole, true).
+			Count(&count).Error
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+				"message": err.Error(),
+			})
+		}
+		if count == 0 {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Staff membership is no longer active, please log in again",
+			})
+		}
+
+		return c.Next()
+	}
+}
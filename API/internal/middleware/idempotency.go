@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// IdempotencyHeader is the request header that carries the client's key
+	IdempotencyHeader = "Idempotency-Key"
+	// IdempotencyReplayedHeader is set on responses replayed from a stored key
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	idempotencyKeyTTL    = 24 * time.Hour
+	maxIdempotencyKeyLen = 255
+)
+
+// Idempotency middleware makes a write endpoint safe to retry. The first
+// request with a given Idempotency-Key stores its response; repeats of the same
+// request get the stored response back, and a reused key with a different body
+// is rejected. Requests without the header are handled normally.
+func Idempotency(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyHeader)
+		if key == "" {
+			return c.Next()
+		}
+
+		if len(key) > maxIdempotencyKeyLen {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Idempotency key is too long",
+			})
+		}
+
+		userID := c.Locals("user_id").(string)
+		hash := requestHash(c)
+
+		record := models.IdempotencyKey{
+			ID:          uuid.New().String(),
+			UserID:      userID,
+			Key:         key,
+			Method:      c.Method(),
+			Path:        c.Path(),
+			RequestHash: hash,
+			Status:      models.IdempotencyStatusProcessing,
+			ExpiresAt:   time.Now().Add(idempotencyKeyTTL),
+		}
+
+		claimed, err := claimIdempotencyKey(db, &record)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+				"message": err.Error(),
+			})
+		}
+
+		if !claimed {
+			var existing models.IdempotencyKey
+			err := db.Where("user_id = ? AND idempotency_key = ?", userID, key).First(&existing).Error
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Database error",
+					"message": err.Error(),
+				})
+			}
+
+			if existing.RequestHash != hash {
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+					"error": "Idempotency key was already used for a different request",
+				})
+			}
+
+			if existing.Status != models.IdempotencyStatusCompleted {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "A request with this idempotency key is still being processed",
+				})
+			}
+
+			c.Set(IdempotencyReplayedHeader, "true")
+			if existing.ContentType != "" {
+				c.Set(fiber.HeaderContentType, existing.ContentType)
+			}
+			return c.Status(existing.ResponseCode).SendString(existing.ResponseBody)
+		}
+
+		err = c.Next()
+
+		// Server errors are not stored so the client can retry with the same key
+		status := c.Response().StatusCode()
+		if err != nil || status >= fiber.StatusInternalServerError {
+			db.Delete(&record)
+			return err
+		}
+
+		db.Model(&record).Updates(map[string]interface{}{
+			"status":        models.IdempotencyStatusCompleted,
+			"response_code": status,
+			"response_body": string(c.Response().Body()),
+			"content_type":  string(c.Response().Header.ContentType()),
+		})
+
+		return nil
+	}
+}
+
+// claimIdempotencyKey inserts the record unless the user already used the key.
+// An expired record is replaced. It reports whether the record was inserted.
+func claimIdempotencyKey(db *gorm.DB, record *models.IdempotencyKey) (bool, error) {
+	err := db.Where("user_id = ? AND idempotency_key = ? AND expires_at < ?", record.UserID, record.Key, time.Now()).
+		Delete(&models.IdempotencyKey{}).Error
+	if err != nil {
+		return false, err
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// requestHash fingerprints a request by method, path and body
+func requestHash(c *fiber.Ctx) string {
+	sum := sha256.New()
+	sum.Write([]byte(c.Method()))
+	sum.Write([]byte{0})
+	sum.Write([]byte(c.Path()))
+	sum.Write([]byte{0})
+	sum.Write(c.Body())
+	return hex.EncodeToString(sum.Sum(nil))
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"siipcoffe-api/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// BranchHeader selects which of an owner's branches a request acts on
+const BranchHeader = "X-Cafe-ID"
+
+// ResolveCafe middleware resolves the cafe the caller acts on and stores it
+// for the handler, which reads it with CurrentCafe. Owners with more than one
+// branch pick it with the X-Cafe-ID header; staff act on the cafe carried in
+// their token. Use it after RequireRole("owner") or RequireCafeRole so the
+// caller's access has been checked.
+func ResolveCafe(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cafe, ferr := LookupCafe(db, c)
+		if ferr != nil {
+			return c.Status(ferr.Code).JSON(fiber.Map{
+				"error": ferr.Message,
+			})
+		}
+
+		c.Locals("cafe", cafe)
+		return c.Next()
+	}
+}
+
+// CurrentCafe returns the cafe resolved by ResolveCafe, or nil when the route
+// does not use it
+func CurrentCafe(c *fiber.Ctx) *models.Cafe {
+	cafe, _ := c.Locals("cafe").(*models.Cafe)
+	return cafe
+}
+
+// LookupCafe finds the cafe the caller acts on, see ResolveCafe
+func LookupCafe(db *gorm.DB, c *fiber.Ctx) (*models.Cafe, *fiber.Error) {
+	userID := c.Locals("user_id").(string)
+
+	var query *gorm.DB
+	if c.Locals("user_role").(string) == models.RoleOwner {
+		query = db.Where("owner_id = ?", userID)
+		if branchID := c.Get(BranchHeader); branchID != "" {
+			query = query.Where("id = ?", branchID)
+		} else {
+			var branches int64
+			if err := db.Model(&models.Cafe{}).Where("owner_id = ?", userID).Count(&branches).Error; err != nil {
+				return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to get cafe")
+			}
+			if branches > 1 {
+				return nil, fiber.NewError(fiber.StatusBadRequest, "You have more than one branch, select one with the "+BranchHeader+" header")
+			}
+		}
+	} else {
+		cafeID, _ := c.Locals("cafe_id").(string)
+		if cafeID == "" {
+			return nil, fiber.NewError(fiber.StatusForbidden, "You do not work for a cafe")
+		}
+		query = db.Where("id = ?", cafeID)
+	}
+
+	var cafe models.Cafe
+	if err := query.First(&cafe).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Cafe not found")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to get cafe")
+	}
+
+	return &cafe, nil
+}
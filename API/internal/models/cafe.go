@@ -1,14 +1,19 @@
 package models
 
 import (
+	"sort"
 	"time"
 
+	"siipcoffe-api/pkg/businesshours"
+	"siipcoffe-api/pkg/utils"
+
 	"gorm.io/gorm"
 )
 
 type Cafe struct {
 	ID                   string         `json:"id" gorm:"primaryKey;type:char(36)"`
 	OwnerID              string         `json:"owner_id" gorm:"not null;index"`
+	OrganizationID       *string        `json:"organization_id" gorm:"index"` // brand this cafe is a branch of
 	Name                 string         `json:"name" gorm:"not null"`
 	Description          string         `json:"description"`
 	LogoURL              string         `json:"logo_url"`
@@ -20,22 +25,32 @@ type Cafe struct {
 	Phone                string         `json:"phone"`
 	Email                string         `json:"email"`
 	Website              string         `json:"website"`
-	CoordinateLat        float64        `json:"coordinate_lat"`
-	CoordinateLng        float64        `json:"coordinate_lng"`
-	BusinessHours        string         `json:"business_hours"` // JSON string
-	IsOpen               bool           `json:"is_open" gorm:"default:true"`
+	CoordinateLat        float64        `json:"coordinate_lat" gorm:"index:idx_cafe_location"`
+	CoordinateLng        float64        `json:"coordinate_lng" gorm:"index:idx_cafe_location"`
+	BusinessHours        businesshours.Schedule `json:"business_hours" gorm:"type:text"`
+	Timezone             string         `json:"timezone" gorm:"default:'Asia/Jakarta'"` // IANA name, business hours are in this timezone
+	OpenOverride         *bool          `json:"open_override"` // manual open/closed status set by the owner, nil follows the schedule
+	OpenOverrideUntil    *time.Time     `json:"open_override_until"` // when the manual status ends, nil until changed again
 	IsVerified           bool           `json:"is_verified" gorm:"default:false"`
+	VerifiedAt           *time.Time     `json:"verified_at"`
 	RatingAverage        float64        `json:"rating_average" gorm:"default:0"`
 	RatingCount          int            `json:"rating_count" gorm:"default:0"`
+	Rating1Count         int            `json:"rating_1_count" gorm:"default:0"` // star histogram of published reviews
+	Rating2Count         int            `json:"rating_2_count" gorm:"default:0"`
+	Rating3Count         int            `json:"rating_3_count" gorm:"default:0"`
+	Rating4Count         int            `json:"rating_4_count" gorm:"default:0"`
+	Rating5Count         int            `json:"rating_5_count" gorm:"default:0"`
 	TaxPercentage        float64        `json:"tax_percentage" gorm:"default:10"`
 	ServiceChargePercentage float64     `json:"service_charge_percentage" gorm:"default:0"`
 	DeliveryFee          float64        `json:"delivery_fee" gorm:"default:0"`
 	MinOrderAmount       float64        `json:"min_order_amount" gorm:"default:0"`
 	MaxDeliveryDistance  float64        `json:"max_delivery_distance" gorm:"default:5"` // in km
-	Features             string         `json:"features"` // JSON string: ["wifi", "outdoor_seating", "delivery", "take_away"]
-	SocialMedia          string         `json:"social_media"` // JSON string
-	Settings             string         `json:"settings"` // JSON string for custom settings
+	Features             CafeFeatures   `json:"features" gorm:"type:text"` // stored as a JSON array, see CafeFeatureList
+	SocialMedia          SocialMedia    `json:"social_media" gorm:"type:text"`
+	Settings             CafeSettings   `json:"settings" gorm:"type:text"` // versioned, see CafeSettingsVersion
 	Status               string         `json:"status" gorm:"default:'active'"` // active, inactive, suspended
+	SuspendedAt          *time.Time     `json:"suspended_at"`
+	SuspensionReason     string         `json:"suspension_reason"`
 	CreatedAt            time.Time      `json:"created_at"`
 	UpdatedAt            time.Time      `json:"updated_at"`
 	DeletedAt            gorm.DeletedAt `json:"-" gorm:"index"`
@@ -49,14 +64,48 @@ type Cafe struct {
 	Staff []CafeStaff `json:"staff,omitempty" gorm:"foreignKey:CafeID"`
 }
 
+// Cafe statuses. Only active cafes are listed and take orders.
+const (
+	CafeStatusActive    = "active"
+	CafeStatusInactive  = "inactive"
+	CafeStatusSuspended = "suspended"
+)
+
+// Cafe moderation actions taken by platform admins
+const (
+	ModerationActionVerify    = "verify"
+	ModerationActionUnverify  = "unverify"
+	ModerationActionSuspend   = "suspend"
+	ModerationActionReinstate = "reinstate"
+)
+
+// CafeModerationLog records a platform admin's decision on a cafe
+type CafeModerationLog struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:char(36)"`
+	CafeID         string    `json:"cafe_id" gorm:"not null;index"`
+	AdminID        string    `json:"admin_id" gorm:"not null;index"`
+	Action         string    `json:"action" gorm:"not null"` // verify, unverify, suspend, reinstate
+	Reason         string    `json:"reason"`
+	PreviousStatus string    `json:"previous_status"`
+	NewStatus      string    `json:"new_status"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	// Relations
+	Admin *User `json:"admin,omitempty" gorm:"foreignKey:AdminID"`
+}
+
 type CafeReview struct {
 	ID         string         `json:"id" gorm:"primaryKey;type:char(36)"`
 	CafeID     string         `json:"cafe_id" gorm:"not null;index"`
 	UserID     string         `json:"user_id" gorm:"not null;index"`
+	OrderID    *string        `json:"order_id" gorm:"uniqueIndex"` // the completed order being reviewed
 	Rating     int            `json:"rating" gorm:"not null;check:rating >= 1 AND rating <= 5"`
 	Comment    string         `json:"comment"`
 	Images     string         `json:"images"` // JSON array of image URLs
-	IsVerified bool           `json:"is_verified" gorm:"default:false"`
+	IsVerified bool           `json:"is_verified" gorm:"default:false"` // tied to a completed order
+	Status     string         `json:"status" gorm:"default:'published';index"` // published, hidden
+	OwnerReply string         `json:"owner_reply"`
+	OwnerReplyAt *time.Time   `json:"owner_reply_at"`
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
@@ -64,6 +113,201 @@ type CafeReview struct {
 	// Relations
 	Cafe Cafe `json:"cafe,omitempty" gorm:"foreignKey:CafeID"`
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	ItemRatings []ReviewItemRating `json:"item_ratings,omitempty" gorm:"foreignKey:ReviewID"`
+}
+
+// CafeSettings are the custom settings stored as JSON in Cafe.Settings
+type CafeSettings struct {
+	// Version is the layout the settings were stored with
+	Version int `json:"version"`
+	// Ordering limits the order types and payment methods the cafe accepts
+	Ordering *OrderingSettings `json:"ordering,omitempty"`
+	// PaymentExpiryMinutes overrides the payment expiry window per payment method
+	PaymentExpiryMinutes map[string]int `json:"payment_expiry_minutes,omitempty"`
+	// ScheduledOrders configures pre-orders for a later pickup time
+	ScheduledOrders *ScheduledOrderSettings `json:"scheduled_orders,omitempty"`
+	// Delivery configures delivery zones and distance based fees
+	Delivery *DeliverySettings `json:"delivery,omitempty"`
+}
+
+// ScheduledOrderSettings controls when and how many pre-orders a cafe accepts
+type ScheduledOrderSettings struct {
+	// LeadMinutes is how long before pickup an order appears on the kitchen
+	// display. It is also the minimum notice for a scheduled order.
+	LeadMinutes int `json:"lead_minutes"`
+	// SlotMinutes is the length of a pickup slot
+	SlotMinutes int `json:"slot_minutes"`
+	// SlotCapacity is the maximum number of scheduled orders per slot, 0 for no limit
+	SlotCapacity int `json:"slot_capacity"`
+	// MaxDaysAhead is how far in advance an order can be scheduled
+	MaxDaysAhead int `json:"max_days_ahead"`
+}
+
+// DeliverySettings limits where a cafe delivers and what it charges
+type DeliverySettings struct {
+	// Zones are the areas the cafe delivers to. Without zones the cafe
+	// delivers within MaxDeliveryDistance of its coordinates.
+	Zones []DeliveryZone `json:"zones,omitempty"`
+	// FeeTiers price delivery by distance. Without tiers the flat
+	// DeliveryFee applies.
+	FeeTiers []DeliveryFeeTier `json:"fee_tiers,omitempty"`
+}
+
+// DeliveryZone is either a radius around the cafe or a polygon on the map
+type DeliveryZone struct {
+	Name     string        `json:"name"`
+	RadiusKm float64       `json:"radius_km,omitempty"`
+	Polygon  []utils.Point `json:"polygon,omitempty"`
+}
+
+// DeliveryFeeTier charges Fee for deliveries up to UpToKm from the cafe
+type DeliveryFeeTier struct {
+	UpToKm float64 `json:"up_to_km"`
+	Fee    float64 `json:"fee"`
+}
+
+// Defaults for scheduled orders when a cafe has not configured them
+const (
+	DefaultScheduleLeadMinutes  = 30
+	DefaultScheduleSlotMinutes  = 15
+	DefaultScheduleSlotCapacity = 10
+	DefaultScheduleMaxDaysAhead = 7
+)
+
+// GetSettings returns the cafe settings
+func (c *Cafe) GetSettings() CafeSettings {
+	return c.Settings
+}
+
+// SetSettings replaces the cafe settings after validating them
+func (c *Cafe) SetSettings(settings CafeSettings) error {
+	if settings.Ordering != nil {
+		if err := settings.Ordering.Validate(); err != nil {
+			return err
+		}
+	}
+	settings.Version = CafeSettingsVersion
+	c.Settings = settings
+	return nil
+}
+
+// PaymentExpiry returns the cafe's payment expiry window for a method, if configured
+func (c *Cafe) PaymentExpiry(method string) (time.Duration, bool) {
+	minutes, ok := c.GetSettings().PaymentExpiryMinutes[method]
+	if !ok || minutes <= 0 {
+		return 0, false
+	}
+	return time.Duration(minutes) * time.Minute, true
+}
+
+// ScheduledOrderSettings returns the cafe's pre-order settings with defaults
+// filled in
+func (c *Cafe) ScheduledOrderSettings() ScheduledOrderSettings {
+	settings := ScheduledOrderSettings{
+		LeadMinutes:  DefaultScheduleLeadMinutes,
+		SlotMinutes:  DefaultScheduleSlotMinutes,
+		SlotCapacity: DefaultScheduleSlotCapacity,
+		MaxDaysAhead: DefaultScheduleMaxDaysAhead,
+	}
+	if configured := c.GetSettings().ScheduledOrders; configured != nil {
+		settings = *configured
+		if settings.SlotMinutes <= 0 {
+			settings.SlotMinutes = DefaultScheduleSlotMinutes
+		}
+		if settings.MaxDaysAhead <= 0 {
+			settings.MaxDaysAhead = DefaultScheduleMaxDaysAhead
+		}
+	}
+	return settings
+}
+
+// DeliveryDistance returns the distance in km from the cafe to a delivery point
+func (c *Cafe) DeliveryDistance(point utils.Point) float64 {
+	return utils.CalculateDistance(c.CoordinateLat, c.CoordinateLng, point.Lat, point.Lng)
+}
+
+// DeliveryZoneFor returns the delivery zone covering a point and whether the
+// cafe delivers there. Without zones the point only has to be within
+// MaxDeliveryDistance, and the returned zone name is empty.
+func (c *Cafe) DeliveryZoneFor(point utils.Point) (string, bool) {
+	distance := c.DeliveryDistance(point)
+
+	settings := c.GetSettings().Delivery
+	if settings == nil || len(settings.Zones) == 0 {
+		return "", c.MaxDeliveryDistance <= 0 || distance <= c.MaxDeliveryDistance
+	}
+
+	for _, zone := range settings.Zones {
+		if len(zone.Polygon) > 0 {
+			if utils.PointInPolygon(point, zone.Polygon) {
+				return zone.Name, true
+			}
+			continue
+		}
+		if distance <= zone.RadiusKm {
+			return zone.Name, true
+		}
+	}
+	return "", false
+}
+
+// DeliveryFeeFor returns the delivery fee for a distance in km. The first fee
+// tier covering the distance applies, the last tier beyond all of them, and
+// the flat DeliveryFee when no tiers are configured.
+func (c *Cafe) DeliveryFeeFor(distanceKm float64) float64 {
+	settings := c.GetSettings().Delivery
+	if settings == nil || len(settings.FeeTiers) == 0 {
+		return c.DeliveryFee
+	}
+
+	tiers := append([]DeliveryFeeTier(nil), settings.FeeTiers...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].UpToKm < tiers[j].UpToKm })
+	for _, tier := range tiers {
+		if distanceKm <= tier.UpToKm {
+			return tier.Fee
+		}
+	}
+	return tiers[len(tiers)-1].Fee
+}
+
+// Location returns the cafe's timezone
+func (c *Cafe) Location() *time.Location {
+	return businesshours.Location(c.Timezone)
+}
+
+// IsOpenAt reports whether the cafe is open at t. A manual status set by the
+// owner wins until it expires; otherwise the business hours decide.
+func (c *Cafe) IsOpenAt(t time.Time) bool {
+	if c.OpenOverride != nil && (c.OpenOverrideUntil == nil || t.Before(*c.OpenOverrideUntil)) {
+		return *c.OpenOverride
+	}
+	return c.BusinessHours.IsOpenAt(t.In(c.Location()))
+}
+
+// NextStatusChange returns when the cafe next opens or closes after t, or nil
+// when that is not known
+func (c *Cafe) NextStatusChange(t time.Time) *time.Time {
+	if c.OpenOverride != nil && (c.OpenOverrideUntil == nil || t.Before(*c.OpenOverrideUntil)) {
+		return c.OpenOverrideUntil
+	}
+	return c.BusinessHours.NextChange(t.In(c.Location()))
+}
+
+// SetOpenOverride sets a manual open/closed status. Without an end time it
+// lasts until the business hours next change. Setting the status the
+// schedule already has clears the override.
+func (c *Cafe) SetOpenOverride(open bool, until *time.Time, now time.Time) {
+	if until == nil && c.BusinessHours.IsOpenAt(now.In(c.Location())) == open {
+		c.OpenOverride = nil
+		c.OpenOverrideUntil = nil
+		return
+	}
+
+	if until == nil {
+		until = c.BusinessHours.NextChange(now.In(c.Location()))
+	}
+	c.OpenOverride = &open
+	c.OpenOverrideUntil = until
 }
 
 type CafeStaff struct {
@@ -82,9 +326,105 @@ type CafeStaff struct {
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// Staff roles
+const (
+	StaffRoleManager = "manager"
+	StaffRoleBarista = "barista"
+	StaffRoleCashier = "cashier"
+	StaffRoleWaiter  = "waiter"
+)
+
+// StaffRoles are all roles a staff member can have
+var StaffRoles = []string{StaffRoleManager, StaffRoleBarista, StaffRoleCashier, StaffRoleWaiter}
+
+// IsValidStaffRole reports whether role is a known staff role
+func IsValidStaffRole(role string) bool {
+	for _, r := range StaffRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Staff invitation statuses
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusRevoked  = "revoked"
+)
+
+// StaffInvitation invites whoever registers with Email to join a cafe's
+// staff. It is accepted by the user logged in with that email who also has
+// Token, which the owner hands to the invitee; emails are not verified.
+type StaffInvitation struct {
+	ID         string     `json:"id" gorm:"primaryKey;type:char(36)"`
+	CafeID     string     `json:"cafe_id" gorm:"not null;index"`
+	Email      string     `json:"email" gorm:"not null;index"`
+	Token      string     `json:"-" gorm:"index"` // single use, cleared once accepted
+	Role       string     `json:"role" gorm:"not null"`
+	InvitedBy  string     `json:"invited_by"`
+	Status     string     `json:"status" gorm:"default:'pending'"` // pending, accepted, revoked
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	// Relations
+	Cafe Cafe `json:"cafe,omitempty" gorm:"foreignKey:CafeID"`
+}
+
+type CafeStaffResponse struct {
+	ID       string       `json:"id"`
+	CafeID   string       `json:"cafe_id"`
+	UserID   string       `json:"user_id"`
+	Role     string       `json:"role"`
+	IsActive bool         `json:"is_active"`
+	JoinedAt time.Time    `json:"joined_at"`
+	User     UserResponse `json:"user"`
+}
+
+type StaffInvitationResponse struct {
+	ID        string    `json:"id"`
+	CafeID    string    `json:"cafe_id"`
+	CafeName  string    `json:"cafe_name,omitempty"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
+	Token     string    `json:"token,omitempty"` // only when the invitation is sent
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *CafeStaff) ToResponse() CafeStaffResponse {
+	return CafeStaffResponse{
+		ID:       s.ID,
+		CafeID:   s.CafeID,
+		UserID:   s.UserID,
+		Role:     s.Role,
+		IsActive: s.IsActive,
+		JoinedAt: s.JoinedAt,
+		User:     s.User.ToResponse(),
+	}
+}
+
+func (i *StaffInvitation) ToResponse() StaffInvitationResponse {
+	return StaffInvitationResponse{
+		ID:        i.ID,
+		CafeID:    i.CafeID,
+		CafeName:  i.Cafe.Name,
+		Email:     i.Email,
+		Role:      i.Role,
+		Status:    i.Status,
+		ExpiresAt: i.ExpiresAt,
+		CreatedAt: i.CreatedAt,
+	}
+}
+
 type CafeResponse struct {
 	ID                   string    `json:"id"`
 	OwnerID              string    `json:"owner_id"`
+	OrganizationID       *string   `json:"organization_id,omitempty"`
 	Name                 string    `json:"name"`
 	Description          string    `json:"description"`
 	LogoURL              string    `json:"logo_url"`
@@ -98,19 +438,31 @@ type CafeResponse struct {
 	Website              string    `json:"website"`
 	CoordinateLat        float64   `json:"coordinate_lat"`
 	CoordinateLng        float64   `json:"coordinate_lng"`
-	BusinessHours        string    `json:"business_hours"`
-	IsOpen               bool      `json:"is_open"`
+	DistanceKm           *float64  `json:"distance_km,omitempty"` // only set when searching by location
+	BusinessHours        businesshours.Schedule `json:"business_hours"`
+	Timezone             string    `json:"timezone"`
+	IsOpen               bool      `json:"is_open"` // computed from business hours and the manual status
+	NextStatusChange     *time.Time `json:"next_status_change"`
+	OpenOverride         *bool     `json:"open_override"`
+	OpenOverrideUntil    *time.Time `json:"open_override_until"`
 	IsVerified           bool      `json:"is_verified"`
+	VerifiedAt           *time.Time `json:"verified_at,omitempty"`
 	RatingAverage        float64   `json:"rating_average"`
 	RatingCount          int       `json:"rating_count"`
+	RatingHistogram      map[int]int `json:"rating_histogram"`
 	TaxPercentage        float64   `json:"tax_percentage"`
 	ServiceChargePercentage float64 `json:"service_charge_percentage"`
 	DeliveryFee          float64   `json:"delivery_fee"`
 	MinOrderAmount       float64   `json:"min_order_amount"`
 	MaxDeliveryDistance  float64   `json:"max_delivery_distance"`
-	Features             string    `json:"features"`
-	SocialMedia          string    `json:"social_media"`
+	Features             CafeFeatures `json:"features"`
+	SocialMedia          SocialMedia `json:"social_media"`
+	Ordering             OrderingSettings `json:"ordering"`
+	PaymentExpiryMinutes map[string]int `json:"payment_expiry_minutes,omitempty"`
+	ScheduledOrders      ScheduledOrderSettings `json:"scheduled_orders"`
+	Delivery             *DeliverySettings `json:"delivery,omitempty"`
 	Status               string    `json:"status"`
+	SuspensionReason     string    `json:"suspension_reason,omitempty"`
 	CreatedAt            time.Time `json:"created_at"`
 }
 
@@ -118,18 +470,25 @@ type CafeReviewResponse struct {
 	ID         string    `json:"id"`
 	CafeID     string    `json:"cafe_id"`
 	UserID     string    `json:"user_id"`
+	OrderID    *string   `json:"order_id,omitempty"`
 	Rating     int       `json:"rating"`
 	Comment    string    `json:"comment"`
 	Images     string    `json:"images"`
 	IsVerified bool      `json:"is_verified"`
+	Status     string    `json:"status"`
+	OwnerReply string    `json:"owner_reply,omitempty"`
+	OwnerReplyAt *time.Time `json:"owner_reply_at,omitempty"`
+	ItemRatings []ReviewItemRatingResponse `json:"item_ratings,omitempty"`
 	CreatedAt  time.Time `json:"created_at"`
 	User       UserResponse `json:"user,omitempty"`
 }
 
 func (c *Cafe) ToResponse() CafeResponse {
+	now := time.Now()
 	return CafeResponse{
 		ID:                      c.ID,
 		OwnerID:                 c.OwnerID,
+		OrganizationID:          c.OrganizationID,
 		Name:                    c.Name,
 		Description:             c.Description,
 		LogoURL:                 c.LogoURL,
@@ -144,32 +503,59 @@ func (c *Cafe) ToResponse() CafeResponse {
 		CoordinateLat:           c.CoordinateLat,
 		CoordinateLng:           c.CoordinateLng,
 		BusinessHours:           c.BusinessHours,
-		IsOpen:                  c.IsOpen,
+		Timezone:                c.Location().String(),
+		IsOpen:                  c.IsOpenAt(now),
+		NextStatusChange:        c.NextStatusChange(now),
+		OpenOverride:            c.OpenOverride,
+		OpenOverrideUntil:       c.OpenOverrideUntil,
 		IsVerified:              c.IsVerified,
+		VerifiedAt:              c.VerifiedAt,
 		RatingAverage:           c.RatingAverage,
 		RatingCount:             c.RatingCount,
+		RatingHistogram:         c.RatingHistogram(),
 		TaxPercentage:           c.TaxPercentage,
 		ServiceChargePercentage: c.ServiceChargePercentage,
 		DeliveryFee:             c.DeliveryFee,
 		MinOrderAmount:          c.MinOrderAmount,
 		MaxDeliveryDistance:     c.MaxDeliveryDistance,
-		Features:                c.Features,
+		Features:                c.featureList(),
 		SocialMedia:             c.SocialMedia,
+		Ordering:                c.Ordering(),
+		PaymentExpiryMinutes:    c.GetSettings().PaymentExpiryMinutes,
+		ScheduledOrders:         c.ScheduledOrderSettings(),
+		Delivery:                c.GetSettings().Delivery,
 		Status:                  c.Status,
+		SuspensionReason:        c.SuspensionReason,
 		CreatedAt:               c.CreatedAt,
 	}
 }
 
+// featureList returns the features, never nil so they encode as a list
+func (c *Cafe) featureList() CafeFeatures {
+	if c.Features == nil {
+		return CafeFeatures{}
+	}
+	return c.Features
+}
+
 func (cr *CafeReview) ToResponse() CafeReviewResponse {
-	return CafeReviewResponse{
+	response := CafeReviewResponse{
 		ID:         cr.ID,
 		CafeID:     cr.CafeID,
 		UserID:     cr.UserID,
+		OrderID:    cr.OrderID,
 		Rating:     cr.Rating,
 		Comment:    cr.Comment,
 		Images:     cr.Images,
 		IsVerified: cr.IsVerified,
+		Status:     cr.Status,
+		OwnerReply: cr.OwnerReply,
+		OwnerReplyAt: cr.OwnerReplyAt,
 		CreatedAt:  cr.CreatedAt,
 		User:       cr.User.ToResponse(),
 	}
+	for _, item := range cr.ItemRatings {
+		response.ItemRatings = append(response.ItemRatings, item.ToResponse())
+	}
+	return response
 }
\ No newline at end of file
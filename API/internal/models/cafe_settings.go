@@ -0,0 +1,343 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Cafe features shown on listings and used to filter them
+const (
+	FeatureWifi             = "wifi"
+	FeatureAC               = "ac"
+	FeatureOutdoorSeating   = "outdoor_seating"
+	FeatureParking          = "parking"
+	FeatureDelivery         = "delivery"
+	FeatureTakeAway         = "take_away"
+	FeaturePowerOutlets     = "power_outlets"
+	FeatureSmokingArea      = "smoking_area"
+	FeaturePetFriendly      = "pet_friendly"
+	FeatureKidsFriendly     = "kids_friendly"
+	FeatureLiveMusic        = "live_music"
+	FeatureMeetingRoom      = "meeting_room"
+	FeaturePrayerRoom       = "prayer_room"
+	FeatureWheelchairAccess = "wheelchair_access"
+)
+
+// CafeFeatureList lists the features a cafe can have
+var CafeFeatureList = []string{
+	FeatureWifi, FeatureAC, FeatureOutdoorSeating, FeatureParking, FeatureDelivery,
+	FeatureTakeAway, FeaturePowerOutlets, FeatureSmokingArea, FeaturePetFriendly,
+	FeatureKidsFriendly, FeatureLiveMusic, FeatureMeetingRoom, FeaturePrayerRoom,
+	FeatureWheelchairAccess,
+}
+
+// Order types and payment methods a cafe can accept
+var (
+	OrderTypes     = []string{"dine_in", "take_away", "delivery"}
+	PaymentMethods = []string{"cash", "transfer", "crypto"}
+)
+
+// IsPaymentMethod reports whether a cafe can accept the payment method
+func IsPaymentMethod(method string) bool {
+	return containsString(PaymentMethods, method)
+}
+
+// CafeSettingsVersion is the current layout of CafeSettings. Settings stored
+// with an older version are upgraded when they are read.
+//
+//	0: unversioned settings with payment expiry, scheduled orders and delivery
+//	1: adds the ordering section
+const CafeSettingsVersion = 1
+
+var (
+	socialHandlePattern = regexp.MustCompile(`^@?[A-Za-z0-9._-]{1,50}$`)
+	phonePattern        = regexp.MustCompile(`^\+?[0-9]{8,15}$`)
+)
+
+// CafeFeatures is the list of features of a cafe, stored as a JSON array
+type CafeFeatures []string
+
+// UnmarshalJSON accepts a JSON array or, as older clients send it, a JSON
+// array encoded as a string
+func (f *CafeFeatures) UnmarshalJSON(data []byte) error {
+	inner, err := unquoteJSON(data)
+	if err != nil {
+		return err
+	}
+	if inner == nil {
+		*f = nil
+		return nil
+	}
+
+	var features []string
+	if err := json.Unmarshal(inner, &features); err != nil {
+		return fmt.Errorf("features must be a list of feature names")
+	}
+	*f = features
+	return nil
+}
+
+// Value stores the features as JSON text
+func (f CafeFeatures) Value() (driver.Value, error) {
+	if f == nil {
+		f = CafeFeatures{}
+	}
+	data, err := json.Marshal([]string(f))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads features stored as JSON text. Values that are not a JSON array
+// read as no features.
+func (f *CafeFeatures) Scan(value interface{}) error {
+	*f = CafeFeatures{}
+	if data, ok := scanText(value); ok {
+		json.Unmarshal(data, (*[]string)(f))
+	}
+	return nil
+}
+
+// Validate checks every feature is known and listed once
+func (f CafeFeatures) Validate() error {
+	seen := make(map[string]bool, len(f))
+	for _, feature := range f {
+		if !IsValidCafeFeature(feature) {
+			return fmt.Errorf("unknown feature %q", feature)
+		}
+		if seen[feature] {
+			return fmt.Errorf("feature %q is listed more than once", feature)
+		}
+		seen[feature] = true
+	}
+	return nil
+}
+
+// Has reports whether the cafe has the feature
+func (f CafeFeatures) Has(feature string) bool {
+	for _, existing := range f {
+		if existing == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidCafeFeature reports whether feature is a known cafe feature
+func IsValidCafeFeature(feature string) bool {
+	return containsString(CafeFeatureList, feature)
+}
+
+// SocialMedia are a cafe's social media accounts. Accounts are handles such as
+// "@siipcoffe" or full profile URLs; WhatsApp is a phone number.
+type SocialMedia struct {
+	Instagram string `json:"instagram,omitempty"`
+	Facebook  string `json:"facebook,omitempty"`
+	Twitter   string `json:"twitter,omitempty"`
+	TikTok    string `json:"tiktok,omitempty"`
+	YouTube   string `json:"youtube,omitempty"`
+	WhatsApp  string `json:"whatsapp,omitempty"`
+}
+
+// socialMediaFields has the same fields as SocialMedia without its JSON methods
+type socialMediaFields SocialMedia
+
+// UnmarshalJSON accepts a JSON object or a JSON object encoded as a string
+func (s *SocialMedia) UnmarshalJSON(data []byte) error {
+	inner, err := unquoteJSON(data)
+	if err != nil {
+		return err
+	}
+	*s = SocialMedia{}
+	if inner == nil {
+		return nil
+	}
+	if err := json.Unmarshal(inner, (*socialMediaFields)(s)); err != nil {
+		return fmt.Errorf("social_media must be an object of account names")
+	}
+	return nil
+}
+
+// Value stores the accounts as JSON text
+func (s SocialMedia) Value() (driver.Value, error) {
+	data, err := json.Marshal(socialMediaFields(s))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads accounts stored as JSON text. Values that are not a JSON object
+// read as no accounts.
+func (s *SocialMedia) Scan(value interface{}) error {
+	*s = SocialMedia{}
+	if data, ok := scanText(value); ok {
+		json.Unmarshal(data, (*socialMediaFields)(s))
+	}
+	return nil
+}
+
+// Validate checks the accounts are handles or profile URLs and the WhatsApp
+// number is a phone number
+func (s SocialMedia) Validate() error {
+	accounts := []struct{ name, value string }{
+		{"instagram", s.Instagram},
+		{"facebook", s.Facebook},
+		{"twitter", s.Twitter},
+		{"tiktok", s.TikTok},
+		{"youtube", s.YouTube},
+	}
+	for _, account := range accounts {
+		if account.value == "" || socialHandlePattern.MatchString(account.value) {
+			continue
+		}
+		if u, err := url.Parse(account.value); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			continue
+		}
+		return fmt.Errorf("%s must be a handle or a profile URL", account.name)
+	}
+
+	if s.WhatsApp != "" {
+		number := strings.NewReplacer(" ", "", "-", "").Replace(s.WhatsApp)
+		if !phonePattern.MatchString(number) {
+			return fmt.Errorf("whatsapp must be a phone number")
+		}
+	}
+	return nil
+}
+
+// OrderingSettings limits how a cafe can be ordered from and paid. Empty
+// lists accept everything.
+type OrderingSettings struct {
+	OrderTypes     []string `json:"order_types,omitempty"`
+	PaymentMethods []string `json:"payment_methods,omitempty"`
+}
+
+// Validate checks the order types and payment methods are known
+func (o OrderingSettings) Validate() error {
+	for _, orderType := range o.OrderTypes {
+		if !containsString(OrderTypes, orderType) {
+			return fmt.Errorf("unknown order type %q", orderType)
+		}
+	}
+	for _, method := range o.PaymentMethods {
+		if !containsString(PaymentMethods, method) {
+			return fmt.Errorf("unknown payment method %q", method)
+		}
+	}
+	return nil
+}
+
+// Value stores the settings as JSON text, stamped with the current version
+func (s CafeSettings) Value() (driver.Value, error) {
+	s.Version = CafeSettingsVersion
+	data, err := json.Marshal(cafeSettingsFields(s))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads settings stored as JSON text and upgrades older versions. Empty
+// or invalid JSON gives empty settings.
+func (s *CafeSettings) Scan(value interface{}) error {
+	*s = CafeSettings{}
+	if data, ok := scanText(value); ok {
+		json.Unmarshal(data, (*cafeSettingsFields)(s))
+	}
+	s.upgrade()
+	return nil
+}
+
+// cafeSettingsFields has the same fields as CafeSettings without its
+// database methods
+type cafeSettingsFields CafeSettings
+
+// upgrade moves settings of an older version to the current layout
+func (s *CafeSettings) upgrade() {
+	// Version 0 had no ordering section, which means every order type and
+	// payment method is accepted, so there is nothing to convert
+	s.Version = CafeSettingsVersion
+}
+
+// Ordering returns the cafe's ordering settings with every order type and
+// payment method filled in when the cafe has not limited them
+func (c *Cafe) Ordering() OrderingSettings {
+	ordering := OrderingSettings{
+		OrderTypes:     OrderTypes,
+		PaymentMethods: PaymentMethods,
+	}
+	if configured := c.Settings.Ordering; configured != nil {
+		if len(configured.OrderTypes) > 0 {
+			ordering.OrderTypes = configured.OrderTypes
+		}
+		if len(configured.PaymentMethods) > 0 {
+			ordering.PaymentMethods = configured.PaymentMethods
+		}
+	}
+	return ordering
+}
+
+// AcceptsOrderType reports whether the cafe takes orders of the given type
+func (c *Cafe) AcceptsOrderType(orderType string) bool {
+	configured := c.Settings.Ordering
+	if configured == nil || len(configured.OrderTypes) == 0 {
+		return true
+	}
+	return containsString(configured.OrderTypes, orderType)
+}
+
+// AcceptsPaymentMethod reports whether the cafe takes payments with method
+func (c *Cafe) AcceptsPaymentMethod(method string) bool {
+	configured := c.Settings.Ordering
+	if configured == nil || len(configured.PaymentMethods) == 0 {
+		return true
+	}
+	return containsString(configured.PaymentMethods, method)
+}
+
+// unquoteJSON returns data, or the JSON inside it when data is a JSON string.
+// It returns nil for null and empty strings.
+func unquoteJSON(data []byte) ([]byte, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" || trimmed == "null" {
+		return nil, nil
+	}
+	if strings.HasPrefix(trimmed, `"`) {
+		var inner string
+		if err := json.Unmarshal(data, &inner); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(inner) == "" {
+			return nil, nil
+		}
+		return []byte(inner), nil
+	}
+	return data, nil
+}
+
+// scanText returns the text of a database value stored as JSON
+func scanText(value interface{}) ([]byte, bool) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), v != ""
+	case []byte:
+		return v, len(v) > 0
+	default:
+		return nil, false
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"testing"
+
+	"siipcoffe-api/pkg/utils"
+)
+
+func TestDeliveryFeeFor(t *testing.T) {
+	tiered := &DeliverySettings{FeeTiers: []DeliveryFeeTier{
+		{UpToKm: 10, Fee: 15000},
+		{UpToKm: 3, Fee: 5000},
+		{UpToKm: 6, Fee: 10000},
+	}}
+
+	tests := []struct {
+		name       string
+		delivery   *DeliverySettings
+		distanceKm float64
+		want       float64
+	}{
+		{"no delivery settings", nil, 4, 8000},
+		{"no tiers", &DeliverySettings{}, 4, 8000},
+		{"at the cafe", tiered, 0, 5000},
+		{"inside the first tier", tiered, 1.5, 5000},
+		{"on the first boundary", tiered, 3, 5000},
+		{"just past the first boundary", tiered, 3.01, 10000},
+		{"on the second boundary", tiered, 6, 10000},
+		{"just past the second boundary", tiered, 6.01, 15000},
+		{"on the last boundary", tiered, 10, 15000},
+		{"beyond the last tier", tiered, 25, 15000},
+		{"single tier beyond", &DeliverySettings{FeeTiers: []DeliveryFeeTier{{UpToKm: 2, Fee: 3000}}}, 5, 3000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cafe := Cafe{DeliveryFee: 8000, Settings: CafeSettings{Delivery: tt.delivery}}
+			if got := cafe.DeliveryFeeFor(tt.distanceKm); got != tt.want {
+				t.Errorf("DeliveryFeeFor(%v) = %v, want %v", tt.distanceKm, got, tt.want)
+			}
+		})
+	}
+
+	// Sorting the tiers must not reorder the stored settings
+	if tiered.FeeTiers[0].UpToKm != 10 {
+		t.Errorf("DeliveryFeeFor reordered the cafe's fee tiers: %+v", tiered.FeeTiers)
+	}
+}
+
+func TestDeliveryZoneFor(t *testing.T) {
+	center := utils.Point{Lat: -6.2, Lng: 106.8}
+	// About 1.1 km and 5.5 km north of the cafe
+	near := utils.Point{Lat: -6.19, Lng: 106.8}
+	far := utils.Point{Lat: -6.15, Lng: 106.8}
+
+	downtown := DeliveryZone{Name: "downtown", Polygon: []utils.Point{
+		{Lat: -6.16, Lng: 106.79}, {Lat: -6.14, Lng: 106.79},
+		{Lat: -6.14, Lng: 106.81}, {Lat: -6.16, Lng: 106.81},
+	}}
+	nearby := DeliveryZone{Name: "nearby", RadiusKm: 2}
+
+	tests := []struct {
+		name        string
+		maxDistance float64
+		zones       []DeliveryZone
+		point       utils.Point
+		wantZone    string
+		wantOK      bool
+	}{
+		{"no zones and no limit", 0, nil, far, "", true},
+		{"no zones within limit", 3, nil, near, "", true},
+		{"no zones beyond limit", 3, nil, far, "", false},
+		{"inside polygon", 3, []DeliveryZone{downtown}, far, "downtown", true},
+		{"outside polygon ignores radius limit", 10, []DeliveryZone{downtown}, near, "", false},
+		{"within radius zone", 0, []DeliveryZone{nearby}, near, "nearby", true},
+		{"beyond radius zone", 0, []DeliveryZone{nearby}, far, "", false},
+		{"first matching zone wins", 0, []DeliveryZone{nearby, downtown}, far, "downtown", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cafe := Cafe{
+				CoordinateLat:       center.Lat,
+				CoordinateLng:       center.Lng,
+				MaxDeliveryDistance: tt.maxDistance,
+			}
+			if tt.zones != nil {
+				cafe.Settings.Delivery = &DeliverySettings{Zones: tt.zones}
+			}
+			zone, ok := cafe.DeliveryZoneFor(tt.point)
+			if zone != tt.wantZone || ok != tt.wantOK {
+				t.Errorf("DeliveryZoneFor(%v) = %q, %v, want %q, %v", tt.point, zone, ok, tt.wantZone, tt.wantOK)
+			}
+		})
+	}
+}
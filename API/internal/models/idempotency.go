@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey stores the outcome of a request sent with an Idempotency-Key
+// header so that retries of the same request get the same response
+type IdempotencyKey struct {
+	ID           string    `json:"id" gorm:"primaryKey;type:char(36)"`
+	UserID       string    `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key          string    `json:"key" gorm:"column:idempotency_key;size:255;not null;uniqueIndex:idx_idempotency_user_key"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	RequestHash  string    `json:"request_hash" gorm:"not null"` // SHA-256 of method, path and body
+	Status       string    `json:"status" gorm:"default:'processing'"` // processing, completed
+	ResponseCode int       `json:"response_code"`
+	ResponseBody string    `json:"response_body" gorm:"type:text"`
+	ContentType  string    `json:"content_type"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Idempotency key statuses
+const (
+	IdempotencyStatusProcessing = "processing"
+	IdempotencyStatusCompleted  = "completed"
+)
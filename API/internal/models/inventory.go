@@ -40,8 +40,9 @@ type StockMovement struct {
 	Quantity     float64        `json:"quantity" gorm:"not null"`
 	UnitCost     float64        `json:"unit_cost"`
 	TotalCost    float64        `json:"total_cost"`
-	Reason       string         `json:"reason"` // purchase, sale, waste, damage, transfer, adjustment
+	Reason       string         `json:"reason"` // purchase, sale, waste, damage, transfer, adjustment, cancellation
 	ReferenceID  string         `json:"reference_id"` // Order ID, Purchase ID, etc.
+	OrderItemID  string         `json:"order_item_id,omitempty" gorm:"index"` // order item the stock was used for
 	Notes        string         `json:"notes"`
 	PerformedBy  string         `json:"performed_by"` // User ID who performed the movement
 	CreatedAt    time.Time      `json:"created_at"`
@@ -84,6 +85,7 @@ type StockMovementResponse struct {
 	TotalCost    float64   `json:"total_cost"`
 	Reason       string    `json:"reason"`
 	ReferenceID  string    `json:"reference_id"`
+	OrderItemID  string    `json:"order_item_id,omitempty"`
 	Notes        string    `json:"notes"`
 	PerformedBy  string    `json:"performed_by"`
 	CreatedAt    time.Time `json:"created_at"`
@@ -131,6 +133,7 @@ func (sm *StockMovement) ToResponse() StockMovementResponse {
 		TotalCost:     sm.TotalCost,
 		Reason:        sm.Reason,
 		ReferenceID:   sm.ReferenceID,
+		OrderItemID:   sm.OrderItemID,
 		Notes:         sm.Notes,
 		PerformedBy:   sm.PerformedBy,
 		CreatedAt:     sm.CreatedAt,
@@ -108,7 +108,7 @@ type LoyaltyTransaction struct {
 	MemberID     string         `json:"member_id" gorm:"not null;index"`
 	CafeID       string         `json:"cafe_id" gorm:"not null;index"`
 	OrderID      string         `json:"order_id"`
-	Type         string         `json:"type" gorm:"not null"` // earned, redeemed, expired, adjusted
+	Type         string         `json:"type" gorm:"not null"` // earned, redeemed, expired, adjusted, refunded
 	Points       int            `json:"points" gorm:"not null"`
 	BalanceAfter int            `json:"balance_after"`
 	Description  string         `json:"description"`
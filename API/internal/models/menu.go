@@ -8,13 +8,17 @@ import (
 
 type Menu struct {
 	ID          string         `json:"id" gorm:"primaryKey;type:char(36)"`
-	CafeID      string         `json:"cafe_id" gorm:"not null;index"`
+	CafeID      string         `json:"cafe_id" gorm:"not null;index;uniqueIndex:idx_cafe_menu_sku"`
+	SKU         *string        `json:"sku" gorm:"uniqueIndex:idx_cafe_menu_sku"` // owner's own code, used to match rows on import
 	Name        string         `json:"name" gorm:"not null"`
 	Description string         `json:"description"`
-	Category    string         `json:"category" gorm:"not null"`
+	Category    string         `json:"category" gorm:"not null"` // slug of the menu category
+	CategoryID  *string        `json:"category_id" gorm:"index"`
 	Price       float64        `json:"price" gorm:"not null"`
 	ImageURL    string         `json:"image_url"`
 	IsAvailable bool           `json:"is_available" gorm:"default:true"`
+	SoldOut     bool           `json:"sold_out" gorm:"default:false"` // set from stock when an ingredient cannot cover one more portion
+	SoldOutReason string       `json:"sold_out_reason"`
 	Ingredients string         `json:"ingredients"`
 	PrepTime    int            `json:"prep_time"` // in minutes
 	IsPopular   bool           `json:"is_popular" gorm:"default:false"`
@@ -22,6 +26,9 @@ type Menu struct {
 	Calories    int            `json:"calories"`
 	Allergens   string         `json:"allergens"` // JSON array of allergens
 	Customizable bool          `json:"customizable" gorm:"default:false"`
+	CatalogItemID *string      `json:"catalog_item_id" gorm:"index"` // brand catalogue item this menu follows
+	PriceOverridden bool       `json:"price_overridden"` // branch price instead of the catalogue price
+	AvailabilityOverridden bool `json:"availability_overridden"` // branch availability instead of the catalogue's
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
@@ -29,18 +36,61 @@ type Menu struct {
 	// Relations
 	Cafe        Cafe        `json:"cafe,omitempty" gorm:"foreignKey:CafeID"`
 	OrderItems  []OrderItem `json:"order_items,omitempty" gorm:"foreignKey:MenuID"`
-	InventoryItems []Inventory `json:"inventory_items,omitempty" gorm:"many2many:menu_inventory;"`
+	Recipe      []RecipeItem `json:"recipe,omitempty" gorm:"foreignKey:MenuID"`
+	OptionGroups []MenuOptionGroup `json:"option_groups,omitempty" gorm:"foreignKey:MenuID"`
+}
+
+// MenuOptionGroup is a set of choices for a menu item, e.g. size or milk type
+type MenuOptionGroup struct {
+	ID         string         `json:"id" gorm:"primaryKey;type:char(36)"`
+	MenuID     string         `json:"menu_id" gorm:"not null;index"`
+	Name       string         `json:"name" gorm:"not null"`
+	IsRequired bool           `json:"is_required" gorm:"default:false"`
+	MinSelect  int            `json:"min_select" gorm:"default:0"`
+	MaxSelect  int            `json:"max_select" gorm:"default:1"`
+	SortOrder  int            `json:"sort_order" gorm:"default:0"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relations
+	Options []MenuOption `json:"options,omitempty" gorm:"foreignKey:GroupID"`
+}
+
+// MenuOption is a single choice in an option group, e.g. "Oat milk"
+type MenuOption struct {
+	ID          string         `json:"id" gorm:"primaryKey;type:char(36)"`
+	GroupID     string         `json:"group_id" gorm:"not null;index"`
+	Name        string         `json:"name" gorm:"not null"`
+	PriceDelta  float64        `json:"price_delta" gorm:"default:0"` // added to the menu price, may be negative
+	IsAvailable bool           `json:"is_available"`
+	SortOrder   int            `json:"sort_order" gorm:"default:0"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// MinRequired returns the minimum number of options that must be chosen
+func (g *MenuOptionGroup) MinRequired() int {
+	if g.IsRequired && g.MinSelect < 1 {
+		return 1
+	}
+	return g.MinSelect
 }
 
 type MenuResponse struct {
 	ID            string  `json:"id"`
 	CafeID        string  `json:"cafe_id"`
+	SKU           *string `json:"sku"`
 	Name          string  `json:"name"`
 	Description   string  `json:"description"`
 	Category      string  `json:"category"`
+	CategoryID    *string `json:"category_id"`
 	Price         float64 `json:"price"`
 	ImageURL      string  `json:"image_url"`
 	IsAvailable   bool    `json:"is_available"`
+	SoldOut       bool    `json:"sold_out"`
+	SoldOutReason string  `json:"sold_out_reason,omitempty"`
 	Ingredients   string  `json:"ingredients"`
 	PrepTime      int     `json:"prep_time"`
 	IsPopular     bool    `json:"is_popular"`
@@ -48,18 +98,62 @@ type MenuResponse struct {
 	Calories      int     `json:"calories"`
 	Allergens     string  `json:"allergens"`
 	Customizable  bool    `json:"customizable"`
+	CatalogItemID *string `json:"catalog_item_id,omitempty"`
+	PriceOverridden bool  `json:"price_overridden,omitempty"`
+	AvailabilityOverridden bool `json:"availability_overridden,omitempty"`
+	OptionGroups  []MenuOptionGroupResponse `json:"option_groups,omitempty"`
+}
+
+type MenuOptionGroupResponse struct {
+	ID         string               `json:"id"`
+	Name       string               `json:"name"`
+	IsRequired bool                 `json:"is_required"`
+	MinSelect  int                  `json:"min_select"`
+	MaxSelect  int                  `json:"max_select"`
+	Options    []MenuOptionResponse `json:"options"`
+}
+
+type MenuOptionResponse struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	PriceDelta  float64 `json:"price_delta"`
+	IsAvailable bool    `json:"is_available"`
+}
+
+func (g *MenuOptionGroup) ToResponse() MenuOptionGroupResponse {
+	response := MenuOptionGroupResponse{
+		ID:         g.ID,
+		Name:       g.Name,
+		IsRequired: g.IsRequired,
+		MinSelect:  g.MinRequired(),
+		MaxSelect:  g.MaxSelect,
+		Options:    []MenuOptionResponse{},
+	}
+	for _, option := range g.Options {
+		response.Options = append(response.Options, MenuOptionResponse{
+			ID:          option.ID,
+			Name:        option.Name,
+			PriceDelta:  option.PriceDelta,
+			IsAvailable: option.IsAvailable,
+		})
+	}
+	return response
 }
 
 func (m *Menu) ToResponse() MenuResponse {
-	return MenuResponse{
+	response := MenuResponse{
 		ID:            m.ID,
 		CafeID:        m.CafeID,
+		SKU:           m.SKU,
 		Name:          m.Name,
 		Description:   m.Description,
 		Category:      m.Category,
+		CategoryID:    m.CategoryID,
 		Price:         m.Price,
 		ImageURL:      m.ImageURL,
 		IsAvailable:   m.IsAvailable,
+		SoldOut:       m.SoldOut,
+		SoldOutReason: m.SoldOutReason,
 		Ingredients:   m.Ingredients,
 		PrepTime:      m.PrepTime,
 		IsPopular:     m.IsPopular,
@@ -67,21 +161,34 @@ func (m *Menu) ToResponse() MenuResponse {
 		Calories:      m.Calories,
 		Allergens:     m.Allergens,
 		Customizable:  m.Customizable,
+		CatalogItemID: m.CatalogItemID,
+		PriceOverridden: m.PriceOverridden,
+		AvailabilityOverridden: m.AvailabilityOverridden,
 	}
+
+	for _, group := range m.OptionGroups {
+		response.OptionGroups = append(response.OptionGroups, group.ToResponse())
+	}
+
+	return response
 }
 
 type Category struct {
 	Name        string    `json:"name"`
 	DisplayName string    `json:"display_name"`
+	EnglishName string    `json:"english_name"`
+	Icon        string    `json:"icon"`
 	Description string    `json:"description"`
 }
 
+// GetDefaultCategories returns the preset categories. A cafe category with a
+// preset's name starts with its display names, see NewMenuCategory.
 func GetDefaultCategories() []Category {
 	return []Category{
-		{Name: "coffee", DisplayName: "Kopi", Description: "Berbagai jenis kopi pilihan"},
-		{Name: "tea", DisplayName: "Teh", Description: "Teh tradisional dan modern"},
-		{Name: "food", DisplayName: "Makanan", Description: "Makanan pendamping"},
-		{Name: "dessert", DisplayName: "Dessert", Description: "Aneka dessert manis"},
-		{Name: "juice", DisplayName: "Jus", Description: "Jus segar buah-buahan"},
+		{Name: "coffee", DisplayName: "Kopi", EnglishName: "Coffee", Icon: "☕", Description: "Berbagai jenis kopi pilihan"},
+		{Name: "tea", DisplayName: "Teh", EnglishName: "Tea", Icon: "🍵", Description: "Teh tradisional dan modern"},
+		{Name: "food", DisplayName: "Makanan", EnglishName: "Food", Icon: "🍽️", Description: "Makanan pendamping"},
+		{Name: "dessert", DisplayName: "Dessert", EnglishName: "Dessert", Icon: "🍰", Description: "Aneka dessert manis"},
+		{Name: "juice", DisplayName: "Jus", EnglishName: "Juice", Icon: "🧃", Description: "Jus segar buah-buahan"},
 	}
 }
\ No newline at end of file
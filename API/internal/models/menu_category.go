@@ -0,0 +1,109 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"siipcoffe-api/pkg/businesshours"
+)
+
+// MenuCategory is a section of a cafe's menu, e.g. "Manual Brew" or "Pastry".
+// Menu.Category holds the slug of the category a menu item is in.
+type MenuCategory struct {
+	ID           string                 `json:"id" gorm:"primaryKey;type:char(36)"`
+	CafeID       string                 `json:"cafe_id" gorm:"not null;uniqueIndex:idx_cafe_category_slug"`
+	Slug         string                 `json:"slug" gorm:"not null;uniqueIndex:idx_cafe_category_slug"`
+	NameID       string                 `json:"name_id" gorm:"not null"` // Indonesian display name
+	NameEN       string                 `json:"name_en"`                 // English display name, falls back to NameID
+	Icon         string                 `json:"icon"`                    // emoji or icon URL
+	SortOrder    int                    `json:"sort_order" gorm:"default:0"`
+	IsActive     bool                   `json:"is_active" gorm:"default:true"`
+	Availability businesshours.Schedule `json:"availability" gorm:"type:text"` // when its items can be ordered, empty follows the cafe's hours
+	CreatedAt    time.Time              `json:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+}
+
+type MenuCategoryResponse struct {
+	ID           string                 `json:"id"`
+	Slug         string                 `json:"slug"`
+	Name         string                 `json:"name"`
+	NameID       string                 `json:"name_id"`
+	NameEN       string                 `json:"name_en"`
+	Icon         string                 `json:"icon"`
+	SortOrder    int                    `json:"sort_order"`
+	IsActive     bool                   `json:"is_active"`
+	Availability businesshours.Schedule `json:"availability"`
+	AvailableNow bool                   `json:"available_now"`
+	MenuCount    *int64                 `json:"menu_count,omitempty"`
+}
+
+// DisplayName returns the category name in lang, "id" or "en"
+func (m *MenuCategory) DisplayName(lang string) string {
+	if lang == "en" && m.NameEN != "" {
+		return m.NameEN
+	}
+	return m.NameID
+}
+
+// IsAvailableAt reports whether the category's items can be ordered at t,
+// given in the cafe's timezone
+func (m *MenuCategory) IsAvailableAt(t time.Time) bool {
+	return m.Availability.IsOpenAt(t)
+}
+
+// ToResponse converts the category for display in lang. now must be in the
+// cafe's timezone.
+func (m *MenuCategory) ToResponse(lang string, now time.Time) MenuCategoryResponse {
+	return MenuCategoryResponse{
+		ID:           m.ID,
+		Slug:         m.Slug,
+		Name:         m.DisplayName(lang),
+		NameID:       m.NameID,
+		NameEN:       m.NameEN,
+		Icon:         m.Icon,
+		SortOrder:    m.SortOrder,
+		IsActive:     m.IsActive,
+		Availability: m.Availability,
+		AvailableNow: m.IsActive && m.IsAvailableAt(now),
+	}
+}
+
+// NewMenuCategory builds a category named name for a cafe. Names that match
+// one of the default categories get its display names and sort order.
+func NewMenuCategory(id, cafeID, name string) MenuCategory {
+	category := MenuCategory{
+		ID:       id,
+		CafeID:   cafeID,
+		Slug:     MenuCategorySlug(name),
+		NameID:   strings.TrimSpace(name),
+		IsActive: true,
+	}
+	for i, preset := range GetDefaultCategories() {
+		if preset.Name == category.Slug {
+			category.NameID = preset.DisplayName
+			category.NameEN = preset.EnglishName
+			category.Icon = preset.Icon
+			category.SortOrder = i + 1
+		}
+	}
+	return category
+}
+
+// MenuCategorySlug turns a category name into its slug, e.g. "Manual Brew"
+// becomes "manual-brew"
+func MenuCategorySlug(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
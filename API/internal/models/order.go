@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -24,9 +25,15 @@ type Order struct {
 	CustomerPhone  string         `json:"customer_phone"`
 	OrderType      string         `json:"order_type"` // "dine_in", "take_away", "delivery"
 	TableNumber    string         `json:"table_number"`
+	TableID        *string        `json:"table_id" gorm:"index"`
+	TableSessionID *string        `json:"table_session_id" gorm:"index"` // tab the order was placed on from the table's QR code
 	DeliveryAddress string        `json:"delivery_address"`
+	DeliveryLat    float64        `json:"delivery_lat"`
+	DeliveryLng    float64        `json:"delivery_lng"`
+	DeliveryDistanceKm float64    `json:"delivery_distance_km"` // from the cafe, used for the delivery fee
 	EstimatedTime  int            `json:"estimated_time"` // in minutes
 	ActualTime     int            `json:"actual_time"` // in minutes
+	ScheduledFor   *time.Time     `json:"scheduled_for" gorm:"index"` // requested pickup time, nil means as soon as possible
 	Notes          string         `json:"notes"`
 	Rating         int            `json:"rating"`
 	Review         string         `json:"review"`
@@ -40,6 +47,7 @@ type Order struct {
 	User       User        `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	OrderItems []OrderItem `json:"order_items,omitempty" gorm:"foreignKey:OrderID"`
 	Payment    Payment     `json:"payment,omitempty" gorm:"foreignKey:PaymentID"`
+	StatusHistory []OrderStatusHistory `json:"status_history,omitempty" gorm:"foreignKey:OrderID"`
 }
 
 type OrderItem struct {
@@ -50,11 +58,44 @@ type OrderItem struct {
 	UnitPrice  float64 `json:"unit_price" gorm:"not null"`
 	TotalPrice float64 `json:"total_price" gorm:"not null"`
 	Notes      string  `json:"notes"`
+	KitchenStatus string `json:"kitchen_status" gorm:"default:'queued'"` // queued, preparing, done
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 
 	// Relations
-	Menu Menu `json:"menu,omitempty" gorm:"foreignKey:MenuID"`
+	Menu    Menu              `json:"menu,omitempty" gorm:"foreignKey:MenuID"`
+	Options []OrderItemOption `json:"options,omitempty" gorm:"foreignKey:OrderItemID"`
+}
+
+// OrderItemOption is an option chosen for an order item. Names and prices are
+// copied so later menu changes do not alter past orders.
+type OrderItemOption struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:char(36)"`
+	OrderItemID string    `json:"order_item_id" gorm:"not null;index"`
+	OptionID    string    `json:"option_id" gorm:"not null;index"`
+	GroupName   string    `json:"group_name"`
+	OptionName  string    `json:"option_name"`
+	PriceDelta  float64   `json:"price_delta"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Kitchen statuses of an order item
+const (
+	KitchenStatusQueued    = "queued"
+	KitchenStatusPreparing = "preparing"
+	KitchenStatusDone      = "done"
+)
+
+// NextKitchenStatus returns the status an item moves to when it is bumped,
+// or an empty string when the item is already done
+func NextKitchenStatus(status string) string {
+	switch status {
+	case KitchenStatusQueued, "":
+		return KitchenStatusPreparing
+	case KitchenStatusPreparing:
+		return KitchenStatusDone
+	}
+	return ""
 }
 
 type Payment struct {
@@ -65,11 +106,87 @@ type Payment struct {
 	Status        string     `json:"status" gorm:"default:'pending'"`
 	TransactionID string     `json:"transaction_id"`
 	CryptoAddress string     `json:"crypto_address"`
+	ProviderReference string `json:"provider_reference"` // Virtual account, gateway reference, etc.
 	ConfirmedAt   *time.Time `json:"confirmed_at"`
+	ExpiresAt     *time.Time `json:"expires_at" gorm:"index"` // nil = never expires
+	RefundedAmount float64   `json:"refunded_amount" gorm:"default:0"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// Relations
+	Refunds []Refund `json:"refunds,omitempty" gorm:"foreignKey:PaymentID"`
+}
+
+// Refund records money returned to the customer for a payment
+type Refund struct {
+	ID                string    `json:"id" gorm:"primaryKey;type:char(36)"`
+	PaymentID         string    `json:"payment_id" gorm:"not null;index"`
+	OrderID           string    `json:"order_id" gorm:"not null;index"`
+	CafeID            string    `json:"cafe_id" gorm:"not null;index"`
+	Amount            float64   `json:"amount" gorm:"not null"`
+	Method            string    `json:"method" gorm:"not null"` // payment method the refund went through
+	Reason            string    `json:"reason"`
+	Status            string    `json:"status" gorm:"default:'completed';index"` // pending, completed, failed
+	ProviderReference string    `json:"provider_reference"` // Refund reference from the payment provider
+	PointsReversed    int       `json:"points_reversed"`
+	RefundedBy        string    `json:"refunded_by"` // User ID who issued the refund
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+
+	// Relations
+	Items []RefundItem `json:"items,omitempty" gorm:"foreignKey:RefundID"`
 }
 
+// Refund statuses. A refund is recorded as pending before the provider is
+// asked to send the money, so a crash in between never loses track of it.
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusCompleted = "completed"
+	RefundStatusFailed    = "failed"
+)
+
+// RefundItem is a single order item (or part of its quantity) covered by a refund
+type RefundItem struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:char(36)"`
+	RefundID    string    `json:"refund_id" gorm:"not null;index"`
+	OrderItemID string    `json:"order_item_id" gorm:"not null;index"`
+	Quantity    int       `json:"quantity" gorm:"not null"`
+	Amount      float64   `json:"amount" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// OrderStatusHistory records a single status transition of an order
+type OrderStatusHistory struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:char(36)"`
+	OrderID    string    `json:"order_id" gorm:"not null;index"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status" gorm:"not null"`
+	ChangedBy  string    `json:"changed_by"` // User ID, or "system" for automatic transitions
+	ActorRole  string    `json:"actor_role"` // customer, owner, system
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OrderEvent is a change to an order that is pushed to live order streams.
+// The auto-increment ID doubles as the SSE event id clients resume from.
+type OrderEvent struct {
+	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	OrderID   string    `json:"order_id" gorm:"not null;index"`
+	UserID    string    `json:"user_id" gorm:"not null;index"`
+	CafeID    string    `json:"cafe_id" gorm:"not null;index"`
+	Type      string    `json:"type" gorm:"not null"` // order_status, order_eta, payment_status, item_status
+	Data      string    `json:"data" gorm:"type:text"` // JSON payload
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// Order event types
+const (
+	OrderEventStatus  = "order_status"
+	OrderEventETA     = "order_eta"
+	OrderEventPayment = "payment_status"
+	OrderEventItem    = "item_status"
+)
+
 type OrderStatus string
 
 const (
@@ -81,6 +198,43 @@ const (
 	OrderStatusCancelled  OrderStatus = "cancelled"
 )
 
+// orderStatusTransitions lists the statuses an order may move to from each status.
+// Completed and cancelled orders are final.
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:   {OrderStatusConfirmed, OrderStatusCancelled},
+	OrderStatusConfirmed: {OrderStatusPreparing, OrderStatusCancelled},
+	OrderStatusPreparing: {OrderStatusReady},
+	OrderStatusReady:     {OrderStatusCompleted},
+	OrderStatusCompleted: {},
+	OrderStatusCancelled: {},
+}
+
+// IsValidOrderStatus reports whether s is a known order status
+func IsValidOrderStatus(s string) bool {
+	_, ok := orderStatusTransitions[OrderStatus(s)]
+	return ok
+}
+
+// NextStatuses returns the statuses an order in this status may move to
+func (s OrderStatus) NextStatuses() []OrderStatus {
+	return orderStatusTransitions[s]
+}
+
+// CanTransitionTo reports whether an order may move from s to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether no further transitions are possible
+func (s OrderStatus) IsFinal() bool {
+	return len(orderStatusTransitions[s]) == 0
+}
+
 type PaymentStatus string
 
 const (
@@ -88,8 +242,19 @@ const (
 	PaymentStatusPaid      PaymentStatus = "paid"
 	PaymentStatusFailed    PaymentStatus = "failed"
 	PaymentStatusRefunded  PaymentStatus = "refunded"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusExpired   PaymentStatus = "expired"
 )
 
+// ActivePaymentStatuses are payment statuses that block starting a new
+// payment for the same order. The database allows one such payment per order.
+var ActivePaymentStatuses = []string{
+	string(PaymentStatusPending),
+	string(PaymentStatusPaid),
+	string(PaymentStatusPartiallyRefunded),
+	string(PaymentStatusRefunded),
+}
+
 type OrderResponse struct {
 	ID               string              `json:"id"`
 	CafeID           string              `json:"cafe_id"`
@@ -106,19 +271,36 @@ type OrderResponse struct {
 	CustomerPhone    string              `json:"customer_phone"`
 	OrderType        string              `json:"order_type"`
 	TableNumber      string              `json:"table_number"`
+	TableID          *string             `json:"table_id,omitempty"`
+	TableSessionID   *string             `json:"table_session_id,omitempty"`
 	DeliveryAddress  string              `json:"delivery_address"`
+	DeliveryLat      float64             `json:"delivery_lat,omitempty"`
+	DeliveryLng      float64             `json:"delivery_lng,omitempty"`
+	DeliveryDistanceKm float64           `json:"delivery_distance_km,omitempty"`
 	EstimatedTime    int                 `json:"estimated_time"`
 	ActualTime       int                 `json:"actual_time"`
+	ScheduledFor     *time.Time          `json:"scheduled_for"`
 	Notes            string              `json:"notes"`
 	Rating           int                 `json:"rating"`
 	Review           string              `json:"review"`
 	CreatedAt        time.Time           `json:"created_at"`
 	CompletedAt      *time.Time          `json:"completed_at"`
 	OrderItems       []OrderItemResponse `json:"order_items"`
+	StatusHistory    []OrderStatusHistoryResponse `json:"status_history"`
+	AllowedStatuses  []OrderStatus       `json:"allowed_statuses"`
 	Cafe             CafeResponse        `json:"cafe,omitempty"`
 	User             UserResponse        `json:"user,omitempty"`
 }
 
+type OrderStatusHistoryResponse struct {
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ChangedBy  string    `json:"changed_by"`
+	ActorRole  string    `json:"actor_role"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
 type OrderItemResponse struct {
 	ID         string  `json:"id"`
 	MenuID     string  `json:"menu_id"`
@@ -126,9 +308,27 @@ type OrderItemResponse struct {
 	UnitPrice  float64 `json:"unit_price"`
 	TotalPrice float64 `json:"total_price"`
 	Notes      string  `json:"notes"`
+	KitchenStatus string `json:"kitchen_status"`
+	Options    []OrderItemOptionResponse `json:"options,omitempty"`
 	Menu       MenuResponse `json:"menu,omitempty"`
 }
 
+type OrderItemOptionResponse struct {
+	OptionID   string  `json:"option_id"`
+	GroupName  string  `json:"group_name"`
+	OptionName string  `json:"option_name"`
+	PriceDelta float64 `json:"price_delta"`
+}
+
+// OptionSummary lists the chosen options, e.g. "Large, Oat milk"
+func (r OrderItemResponse) OptionSummary() string {
+	names := make([]string, 0, len(r.Options))
+	for _, option := range r.Options {
+		names = append(names, option.OptionName)
+	}
+	return strings.Join(names, ", ")
+}
+
 func (o *Order) ToResponse() OrderResponse {
 	response := OrderResponse{
 		ID:              o.ID,
@@ -146,28 +346,56 @@ func (o *Order) ToResponse() OrderResponse {
 		CustomerPhone:   o.CustomerPhone,
 		OrderType:       o.OrderType,
 		TableNumber:     o.TableNumber,
+		TableID:         o.TableID,
+		TableSessionID:  o.TableSessionID,
 		DeliveryAddress: o.DeliveryAddress,
+		DeliveryLat:     o.DeliveryLat,
+		DeliveryLng:     o.DeliveryLng,
+		DeliveryDistanceKm: o.DeliveryDistanceKm,
 		EstimatedTime:   o.EstimatedTime,
 		ActualTime:      o.ActualTime,
+		ScheduledFor:    o.ScheduledFor,
 		Notes:           o.Notes,
 		Rating:          o.Rating,
 		Review:          o.Review,
 		CreatedAt:       o.CreatedAt,
 		CompletedAt:     o.CompletedAt,
+		AllowedStatuses: OrderStatus(o.Status).NextStatuses(),
 		Cafe:            o.Cafe.ToResponse(),
 		User:            o.User.ToResponse(),
 	}
 
+	for _, entry := range o.StatusHistory {
+		response.StatusHistory = append(response.StatusHistory, OrderStatusHistoryResponse{
+			FromStatus: entry.FromStatus,
+			ToStatus:   entry.ToStatus,
+			ChangedBy:  entry.ChangedBy,
+			ActorRole:  entry.ActorRole,
+			Reason:     entry.Reason,
+			CreatedAt:  entry.CreatedAt,
+		})
+	}
+
 	for _, item := range o.OrderItems {
-		response.OrderItems = append(response.OrderItems, OrderItemResponse{
+		itemResponse := OrderItemResponse{
 			ID:         item.ID,
 			MenuID:     item.MenuID,
 			Quantity:   item.Quantity,
 			UnitPrice:  item.UnitPrice,
 			TotalPrice: item.TotalPrice,
 			Notes:      item.Notes,
+			KitchenStatus: item.KitchenStatus,
 			Menu:       item.Menu.ToResponse(),
-		})
+		}
+		for _, option := range item.Options {
+			itemResponse.Options = append(itemResponse.Options, OrderItemOptionResponse{
+				OptionID:   option.OptionID,
+				GroupName:  option.GroupName,
+				OptionName: option.OptionName,
+				PriceDelta: option.PriceDelta,
+			})
+		}
+		response.OrderItems = append(response.OrderItems, itemResponse)
 	}
 
 	return response
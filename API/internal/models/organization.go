@@ -0,0 +1,158 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Organization is a brand that groups an owner's cafes as branches and holds
+// the menu catalogue they share
+type Organization struct {
+	ID          string         `json:"id" gorm:"primaryKey;type:char(36)"`
+	OwnerID     string         `json:"owner_id" gorm:"not null;uniqueIndex"`
+	Name        string         `json:"name" gorm:"not null"`
+	Description string         `json:"description"`
+	LogoURL     string         `json:"logo_url"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relations
+	Branches []Cafe        `json:"branches,omitempty" gorm:"foreignKey:OrganizationID"`
+	Catalog  []CatalogItem `json:"catalog,omitempty" gorm:"foreignKey:OrganizationID"`
+}
+
+// CatalogItem is a brand level menu item. Every branch gets a linked Menu
+// that follows the catalogue unless the branch overrides its price or
+// availability.
+type CatalogItem struct {
+	ID             string         `json:"id" gorm:"primaryKey;type:char(36)"`
+	OrganizationID string         `json:"organization_id" gorm:"not null;index"`
+	Name           string         `json:"name" gorm:"not null"`
+	Description    string         `json:"description"`
+	Category       string         `json:"category" gorm:"not null"`
+	Price          float64        `json:"price" gorm:"not null"`
+	ImageURL       string         `json:"image_url"`
+	IsAvailable    bool           `json:"is_available"`
+	Ingredients    string         `json:"ingredients"`
+	PrepTime       int            `json:"prep_time"` // in minutes
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relations
+	BranchMenus []Menu `json:"branch_menus,omitempty" gorm:"foreignKey:CatalogItemID"`
+}
+
+type OrganizationResponse struct {
+	ID          string          `json:"id"`
+	OwnerID     string          `json:"owner_id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	LogoURL     string          `json:"logo_url"`
+	Branches    []BranchSummary `json:"branches"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// BranchSummary is a short description of a branch for branch pickers
+type BranchSummary struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	City   string `json:"city"`
+	Status string `json:"status"`
+	IsOpen bool   `json:"is_open"`
+}
+
+type CatalogItemResponse struct {
+	ID          string                  `json:"id"`
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	Category    string                  `json:"category"`
+	Price       float64                 `json:"price"`
+	ImageURL    string                  `json:"image_url"`
+	IsAvailable bool                    `json:"is_available"`
+	Ingredients string                  `json:"ingredients"`
+	PrepTime    int                     `json:"prep_time"`
+	Branches    []CatalogBranchResponse `json:"branches,omitempty"`
+}
+
+// CatalogBranchResponse is how a catalogue item looks in one branch
+type CatalogBranchResponse struct {
+	CafeID                 string  `json:"cafe_id"`
+	MenuID                 string  `json:"menu_id"`
+	Price                  float64 `json:"price"`
+	IsAvailable            bool    `json:"is_available"`
+	PriceOverridden        bool    `json:"price_overridden"`
+	AvailabilityOverridden bool    `json:"availability_overridden"`
+}
+
+func (c *Cafe) ToBranchSummary() BranchSummary {
+	return BranchSummary{
+		ID:     c.ID,
+		Name:   c.Name,
+		City:   c.City,
+		Status: c.Status,
+		IsOpen: c.IsOpenAt(time.Now()),
+	}
+}
+
+func (o *Organization) ToResponse() OrganizationResponse {
+	response := OrganizationResponse{
+		ID:          o.ID,
+		OwnerID:     o.OwnerID,
+		Name:        o.Name,
+		Description: o.Description,
+		LogoURL:     o.LogoURL,
+		Branches:    []BranchSummary{},
+		CreatedAt:   o.CreatedAt,
+	}
+	for _, branch := range o.Branches {
+		response.Branches = append(response.Branches, branch.ToBranchSummary())
+	}
+	return response
+}
+
+func (i *CatalogItem) ToResponse() CatalogItemResponse {
+	response := CatalogItemResponse{
+		ID:          i.ID,
+		Name:        i.Name,
+		Description: i.Description,
+		Category:    i.Category,
+		Price:       i.Price,
+		ImageURL:    i.ImageURL,
+		IsAvailable: i.IsAvailable,
+		Ingredients: i.Ingredients,
+		PrepTime:    i.PrepTime,
+	}
+	for _, menu := range i.BranchMenus {
+		response.Branches = append(response.Branches, CatalogBranchResponse{
+			CafeID:                 menu.CafeID,
+			MenuID:                 menu.ID,
+			Price:                  menu.Price,
+			IsAvailable:            menu.IsAvailable,
+			PriceOverridden:        menu.PriceOverridden,
+			AvailabilityOverridden: menu.AvailabilityOverridden,
+		})
+	}
+	return response
+}
+
+// ApplyTo copies the catalogue item onto a branch menu. Price and
+// availability are kept when the branch has overridden them.
+func (i *CatalogItem) ApplyTo(menu *Menu) {
+	catalogItemID := i.ID
+	menu.CatalogItemID = &catalogItemID
+	menu.Name = i.Name
+	menu.Description = i.Description
+	menu.Category = i.Category
+	menu.ImageURL = i.ImageURL
+	menu.Ingredients = i.Ingredients
+	menu.PrepTime = i.PrepTime
+	if !menu.PriceOverridden {
+		menu.Price = i.Price
+	}
+	if !menu.AvailabilityOverridden {
+		menu.IsAvailable = i.IsAvailable
+	}
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// RecipeItem is the amount of an inventory item one portion of a menu item
+// uses. Lines with an OptionID only apply when that option is chosen; they may
+// be negative to take out part of the base recipe, e.g. oat milk replacing
+// fresh milk.
+type RecipeItem struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:char(36)"`
+	MenuID      string    `json:"menu_id" gorm:"not null;index"`
+	OptionID    *string   `json:"option_id" gorm:"index"`
+	InventoryID string    `json:"inventory_id" gorm:"not null;index"`
+	Quantity    float64   `json:"quantity" gorm:"not null"` // in the inventory item's unit, per portion
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Relations
+	Inventory Inventory `json:"inventory,omitempty" gorm:"foreignKey:InventoryID"`
+}
+
+type RecipeItemResponse struct {
+	ID            string  `json:"id"`
+	OptionID      *string `json:"option_id,omitempty"`
+	InventoryID   string  `json:"inventory_id"`
+	InventoryName string  `json:"inventory_name"`
+	Unit          string  `json:"unit"`
+	Quantity      float64 `json:"quantity"`
+	UnitCost      float64 `json:"unit_cost"`
+}
+
+func (r *RecipeItem) ToResponse() RecipeItemResponse {
+	return RecipeItemResponse{
+		ID:            r.ID,
+		OptionID:      r.OptionID,
+		InventoryID:   r.InventoryID,
+		InventoryName: r.Inventory.Name,
+		Unit:          r.Inventory.Unit,
+		Quantity:      r.Quantity,
+		UnitCost:      r.Inventory.UnitCost,
+	}
+}
+
+// RecipeUsage adds up what one portion of a menu item uses per inventory item
+// when the given options are chosen. An inventory item never goes below zero,
+// so option lines can only take out what the recipe puts in.
+func RecipeUsage(recipe []RecipeItem, optionIDs []string) map[string]float64 {
+	chosen := make(map[string]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		chosen[id] = true
+	}
+
+	usage := make(map[string]float64)
+	for _, line := range recipe {
+		if line.OptionID != nil && !chosen[*line.OptionID] {
+			continue
+		}
+		usage[line.InventoryID] += line.Quantity
+	}
+	for id, quantity := range usage {
+		if quantity <= 0 {
+			delete(usage, id)
+		}
+	}
+	return usage
+}
+
+// stockTolerance absorbs rounding in stock amounts built up from fractional
+// movements
+const stockTolerance = 1e-9
+
+// ShortIngredients returns the inventory items that cannot cover one more
+// portion of a menu item with the given options, by name. The recipe lines
+// need their Inventory loaded; lines of deleted inventory items are skipped.
+func ShortIngredients(recipe []RecipeItem, optionIDs []string) []Inventory {
+	inventories := make(map[string]Inventory)
+	tracked := make([]RecipeItem, 0, len(recipe))
+	for _, line := range recipe {
+		if line.Inventory.ID == "" {
+			continue
+		}
+		inventories[line.InventoryID] = line.Inventory
+		tracked = append(tracked, line)
+	}
+
+	var short []Inventory
+	for id, quantity := range RecipeUsage(tracked, optionIDs) {
+		if inventories[id].CurrentStock+stockTolerance < quantity {
+			short = append(short, inventories[id])
+		}
+	}
+	sort.Slice(short, func(i, j int) bool {
+		return short[i].Name < short[j].Name
+	})
+	return short
+}
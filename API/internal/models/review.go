@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+)
+
+// Review statuses. Hidden reviews were taken down by a platform admin and do
+// not count towards the cafe rating.
+const (
+	ReviewStatusPublished = "published"
+	ReviewStatusHidden    = "hidden"
+)
+
+// Review report reasons and statuses
+const (
+	ReportReasonSpam      = "spam"
+	ReportReasonOffensive = "offensive"
+	ReportReasonFake      = "fake"
+	ReportReasonOther     = "other"
+
+	ReportStatusOpen      = "open"
+	ReportStatusDismissed = "dismissed"
+	ReportStatusActioned  = "actioned"
+)
+
+// ReportReasons lists the reasons a review can be reported for
+var ReportReasons = []string{ReportReasonSpam, ReportReasonOffensive, ReportReasonFake, ReportReasonOther}
+
+// ReviewItemRating rates a single menu item of the reviewed order
+type ReviewItemRating struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:char(36)"`
+	ReviewID  string    `json:"review_id" gorm:"not null;index"`
+	MenuID    string    `json:"menu_id" gorm:"not null;index"`
+	Rating    int       `json:"rating" gorm:"not null;check:rating >= 1 AND rating <= 5"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relations
+	Menu Menu `json:"menu,omitempty" gorm:"foreignKey:MenuID"`
+}
+
+// ReviewReport is a user's complaint about a review, waiting in the
+// moderation queue until an admin resolves it
+type ReviewReport struct {
+	ID         string     `json:"id" gorm:"primaryKey;type:char(36)"`
+	ReviewID   string     `json:"review_id" gorm:"not null;uniqueIndex:idx_review_reporter"`
+	ReporterID string     `json:"reporter_id" gorm:"not null;uniqueIndex:idx_review_reporter"`
+	Reason     string     `json:"reason" gorm:"not null"` // spam, offensive, fake, other
+	Details    string     `json:"details"`
+	Status     string     `json:"status" gorm:"default:'open';index"` // open, dismissed, actioned
+	ResolvedBy *string    `json:"resolved_by"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	// Relations
+	Review *CafeReview `json:"review,omitempty" gorm:"foreignKey:ReviewID"`
+}
+
+type ReviewItemRatingResponse struct {
+	MenuID   string `json:"menu_id"`
+	MenuName string `json:"menu_name,omitempty"`
+	Rating   int    `json:"rating"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+func (r *ReviewItemRating) ToResponse() ReviewItemRatingResponse {
+	return ReviewItemRatingResponse{
+		MenuID:   r.MenuID,
+		MenuName: r.Menu.Name,
+		Rating:   r.Rating,
+		Comment:  r.Comment,
+	}
+}
+
+// IsValidReportReason reports whether reason is a known report reason
+func IsValidReportReason(reason string) bool {
+	for _, r := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// RatingHistogram returns how many published reviews gave each star rating
+func (c *Cafe) RatingHistogram() map[int]int {
+	return map[int]int{
+		1: c.Rating1Count,
+		2: c.Rating2Count,
+		3: c.Rating3Count,
+		4: c.Rating4Count,
+		5: c.Rating5Count,
+	}
+}
@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// Table session statuses
+const (
+	TableSessionOpen   = "open"
+	TableSessionClosed = "closed"
+)
+
+// CafeTable is a table in a cafe. Its QR code carries a signed token for the
+// table; bumping QRVersion invalidates codes that were printed before.
+type CafeTable struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:char(36)"`
+	CafeID    string    `json:"cafe_id" gorm:"not null;uniqueIndex:idx_cafe_table_number"`
+	Number    string    `json:"number" gorm:"not null;uniqueIndex:idx_cafe_table_number"` // shown on the table, e.g. "A3"
+	Label     string    `json:"label"`                                                    // e.g. "Window seat"
+	Capacity  int       `json:"capacity"`
+	IsActive  bool      `json:"is_active" gorm:"default:true"`
+	QRVersion int       `json:"qr_version" gorm:"default:1"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableSession is a tab opened by scanning a table's QR code. Every order
+// placed with the session's token is added to the tab until staff close it.
+type TableSession struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:char(36)"`
+	CafeID    string     `json:"cafe_id" gorm:"not null;index"`
+	TableID   string     `json:"table_id" gorm:"not null;index"`
+	Token     string     `json:"-" gorm:"uniqueIndex;not null"` // bearer token of the guests at the table
+	Status    string     `json:"status" gorm:"default:'open';index"`
+	OpenedAt  time.Time  `json:"opened_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+	ClosedBy  string     `json:"closed_by"` // User ID of the staff member who closed the tab
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	// Relations
+	Table  CafeTable `json:"table,omitempty" gorm:"foreignKey:TableID"`
+	Orders []Order   `json:"orders,omitempty" gorm:"foreignKey:TableSessionID"`
+}
+
+// TabResponse is a table session with its orders and what is left to pay
+type TabResponse struct {
+	ID          string          `json:"id"`
+	CafeID      string          `json:"cafe_id"`
+	Table       CafeTable       `json:"table"`
+	Status      string          `json:"status"`
+	OpenedAt    time.Time       `json:"opened_at"`
+	ClosedAt    *time.Time      `json:"closed_at"`
+	OrderCount  int             `json:"order_count"`
+	TotalAmount float64         `json:"total_amount"`
+	PaidAmount  float64         `json:"paid_amount"`
+	Balance     float64         `json:"balance"`
+	Orders      []OrderResponse `json:"orders"`
+}
+
+// ToResponse sums the tab's orders. Cancelled orders are listed but not
+// charged.
+func (s *TableSession) ToResponse() TabResponse {
+	response := TabResponse{
+		ID:       s.ID,
+		CafeID:   s.CafeID,
+		Table:    s.Table,
+		Status:   s.Status,
+		OpenedAt: s.OpenedAt,
+		ClosedAt: s.ClosedAt,
+		Orders:   make([]OrderResponse, 0, len(s.Orders)),
+	}
+
+	for i := range s.Orders {
+		order := &s.Orders[i]
+		response.Orders = append(response.Orders, order.ToResponse())
+		if OrderStatus(order.Status) == OrderStatusCancelled {
+			continue
+		}
+		response.OrderCount++
+		response.TotalAmount += order.TotalAmount
+		if order.PaymentStatus == string(PaymentStatusPaid) {
+			response.PaidAmount += order.TotalAmount
+		}
+	}
+	response.Balance = response.TotalAmount - response.PaidAmount
+
+	return response
+}
@@ -23,6 +23,14 @@ type User struct {
 	Chats  []Chat  `json:"chats,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// User roles. Admins run the platform and can only be created from the
+// server configuration.
+const (
+	RoleCustomer = "customer"
+	RoleOwner    = "owner"
+	RoleAdmin    = "admin"
+)
+
 type UserResponse struct {
 	ID      string `json:"id"`
 	Name    string `json:"name"`
@@ -0,0 +1,385 @@
+package businesshours
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	// Embed the timezone database so cafe timezones resolve on minimal images
+	_ "time/tzdata"
+)
+
+// DefaultTimezone is used for cafes that have not configured a timezone
+const DefaultTimezone = "Asia/Jakarta"
+
+// DateLayout is the format of override dates
+const DateLayout = "2006-01-02"
+
+// lookAheadDays limits how far NextChange searches for a status change
+const lookAheadDays = 8
+
+var dayNames = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// Period is an opening period within a day, as "HH:MM" wall clock times. A
+// close time at or before the open time runs past midnight.
+type Period struct {
+	Open  string `json:"open"`
+	Close string `json:"close"`
+}
+
+// Override replaces the weekly schedule on a single date, e.g. a holiday
+// closure or extended hours for an event
+type Override struct {
+	Date    string   `json:"date"` // YYYY-MM-DD in the cafe's timezone
+	Closed  bool     `json:"closed"`
+	Periods []Period `json:"periods,omitempty"`
+	Note    string   `json:"note,omitempty"`
+}
+
+// Schedule is a cafe's opening schedule. Weekly maps lowercase day names to
+// the periods the cafe is open; a day without periods is closed. A schedule
+// without weekly hours is open every day, apart from its overrides.
+type Schedule struct {
+	Weekly    map[string][]Period `json:"weekly,omitempty"`
+	Overrides []Override          `json:"overrides,omitempty"`
+}
+
+// legacyDay is the single-period day format of older cafes:
+// {"open":"07:00","close":"22:00"} or {"closed":true}
+type legacyDay struct {
+	Open   string `json:"open"`
+	Close  string `json:"close"`
+	Closed bool   `json:"closed"`
+}
+
+// UnmarshalJSON accepts the typed format, the older flat format keyed by day
+// name with a single period per day, and either of them encoded as a string.
+func (s *Schedule) UnmarshalJSON(data []byte) error {
+	*s = Schedule{}
+
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" || trimmed == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(trimmed, `"`) {
+		var inner string
+		if err := json.Unmarshal(data, &inner); err != nil {
+			return err
+		}
+		if strings.TrimSpace(inner) == "" {
+			return nil
+		}
+		return s.UnmarshalJSON([]byte(inner))
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("invalid business hours: %w", err)
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	_, hasWeekly := fields["weekly"]
+	_, hasOverrides := fields["overrides"]
+	if !hasWeekly && !hasOverrides {
+		// Older format: the days are the top-level keys
+		return s.decodeWeekly(fields)
+	}
+
+	if hasWeekly {
+		var weekly map[string]json.RawMessage
+		if err := json.Unmarshal(fields["weekly"], &weekly); err != nil {
+			return fmt.Errorf("invalid business hours: %w", err)
+		}
+		if err := s.decodeWeekly(weekly); err != nil {
+			return err
+		}
+	}
+	if hasOverrides {
+		if err := json.Unmarshal(fields["overrides"], &s.Overrides); err != nil {
+			return fmt.Errorf("invalid business hours overrides: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// decodeWeekly reads days given either as a list of periods or as a single
+// legacy day object
+func (s *Schedule) decodeWeekly(days map[string]json.RawMessage) error {
+	s.Weekly = make(map[string][]Period, len(days))
+	for name, raw := range days {
+		day := strings.ToLower(name)
+
+		if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+			var periods []Period
+			if err := json.Unmarshal(raw, &periods); err != nil {
+				return fmt.Errorf("invalid business hours for %s: %w", name, err)
+			}
+			s.Weekly[day] = periods
+			continue
+		}
+
+		var legacy legacyDay
+		if err := json.Unmarshal(raw, &legacy); err != nil {
+			return fmt.Errorf("invalid business hours for %s: %w", name, err)
+		}
+		if legacy.Closed || (legacy.Open == "" && legacy.Close == "") {
+			s.Weekly[day] = []Period{}
+			continue
+		}
+		s.Weekly[day] = []Period{{Open: legacy.Open, Close: legacy.Close}}
+	}
+	return nil
+}
+
+// Value stores the schedule as JSON text
+func (s Schedule) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a schedule stored as JSON text, including the older format.
+// Hours that cannot be read, such as free text like "07:00-22:00" saved
+// before schedules were structured, are logged and read as no schedule, so
+// one bad row does not break every query that loads cafes.
+func (s *Schedule) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("cannot scan %T into business hours", value)
+	}
+
+	if err := s.UnmarshalJSON(data); err != nil {
+		log.Printf("Ignoring unreadable business hours %q: %v", data, err)
+		*s = Schedule{}
+	}
+	return nil
+}
+
+// IsZero reports whether no hours are configured, meaning always open
+func (s Schedule) IsZero() bool {
+	return s.Weekly == nil && len(s.Overrides) == 0
+}
+
+// Validate checks day names, times, overlapping periods and override dates
+func (s Schedule) Validate() error {
+	for day, periods := range s.Weekly {
+		if dayIndex(day) < 0 {
+			return fmt.Errorf("unknown day %q", day)
+		}
+		if err := validatePeriods(periods); err != nil {
+			return fmt.Errorf("%s: %w", day, err)
+		}
+	}
+
+	seen := make(map[string]bool, len(s.Overrides))
+	for _, override := range s.Overrides {
+		if _, err := time.Parse(DateLayout, override.Date); err != nil {
+			return fmt.Errorf("override date %q must be formatted as YYYY-MM-DD", override.Date)
+		}
+		if seen[override.Date] {
+			return fmt.Errorf("override date %s is listed more than once", override.Date)
+		}
+		seen[override.Date] = true
+
+		if !override.Closed && len(override.Periods) == 0 {
+			return fmt.Errorf("override %s needs periods or closed set to true", override.Date)
+		}
+		if err := validatePeriods(override.Periods); err != nil {
+			return fmt.Errorf("override %s: %w", override.Date, err)
+		}
+	}
+
+	return nil
+}
+
+// IsOpenAt reports whether the schedule is open at t. t must already be in
+// the cafe's timezone.
+func (s Schedule) IsOpenAt(t time.Time) bool {
+	if s.IsZero() {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+
+	periods, allDay := s.periodsOn(t)
+	if allDay {
+		return true
+	}
+	for _, period := range periods {
+		open, close := period.minutes()
+		if minute >= open && (minute < close || close <= open) {
+			return true
+		}
+	}
+
+	// Periods of the previous day that run past midnight
+	yesterday, _ := s.periodsOn(t.AddDate(0, 0, -1))
+	for _, period := range yesterday {
+		open, close := period.minutes()
+		if close <= open && minute < close {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextChange returns when the schedule next opens or closes after t, or nil
+// when it does not change within the next week. t must be in the cafe's
+// timezone.
+func (s Schedule) NextChange(t time.Time) *time.Time {
+	if s.IsZero() {
+		return nil
+	}
+
+	// Status can only change at midnight or at the start or end of a period
+	var candidates []time.Time
+	for offset := -1; offset <= lookAheadDays; offset++ {
+		day := t.AddDate(0, 0, offset)
+		candidates = append(candidates, atMinute(day, 0))
+
+		periods, _ := s.periodsOn(day)
+		for _, period := range periods {
+			open, close := period.minutes()
+			candidates = append(candidates, atMinute(day, open))
+			if close <= open {
+				close += 24 * 60
+			}
+			candidates = append(candidates, atMinute(day, close))
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+
+	current := s.IsOpenAt(t)
+	for _, candidate := range candidates {
+		if candidate.After(t) && s.IsOpenAt(candidate) != current {
+			return &candidate
+		}
+	}
+	return nil
+}
+
+// periodsOn returns the periods for the date of t, using an override for
+// that date when there is one. allDay is true when the date has no hours
+// configured at all.
+func (s Schedule) periodsOn(t time.Time) (periods []Period, allDay bool) {
+	date := t.Format(DateLayout)
+	for _, override := range s.Overrides {
+		if override.Date == date {
+			if override.Closed {
+				return nil, false
+			}
+			return override.Periods, false
+		}
+	}
+
+	if s.Weekly == nil {
+		return nil, true
+	}
+	return s.Weekly[dayNames[t.Weekday()]], false
+}
+
+// Location loads a cafe timezone, falling back to DefaultTimezone when the
+// name is empty or unknown
+func Location(name string) *time.Location {
+	if name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return time.FixedZone("WIB", 7*60*60)
+	}
+	return loc
+}
+
+// ValidTimezone reports whether name is a known IANA timezone
+func ValidTimezone(name string) bool {
+	_, err := time.LoadLocation(name)
+	return name != "" && err == nil
+}
+
+func validatePeriods(periods []Period) error {
+	type span struct{ start, end int }
+	spans := make([]span, 0, len(periods))
+
+	for _, period := range periods {
+		open, err := parseClock(period.Open)
+		if err != nil {
+			return err
+		}
+		close, err := parseClock(period.Close)
+		if err != nil {
+			return err
+		}
+		if open == close {
+			return fmt.Errorf("period %s-%s opens and closes at the same time", period.Open, period.Close)
+		}
+		if close < open {
+			close += 24 * 60
+		}
+		spans = append(spans, span{open, close})
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	for i := 1; i < len(spans); i++ {
+		if spans[i].start < spans[i-1].end {
+			return fmt.Errorf("periods overlap")
+		}
+	}
+	return nil
+}
+
+func (p Period) minutes() (int, int) {
+	open, _ := parseClock(p.Open)
+	close, _ := parseClock(p.Close)
+	return open, close
+}
+
+func dayIndex(name string) int {
+	for i, day := range dayNames {
+		if day == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// atMinute returns the time minute minutes after midnight on the date of t
+func atMinute(t time.Time, minute int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, minute, 0, 0, t.Location())
+}
+
+// parseClock converts "HH:MM" to minutes after midnight. "24:00" is accepted
+// as the end of the day.
+func parseClock(value string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hour, &minute); err != nil || len(value) != 5 {
+		return 0, fmt.Errorf("time %q must be formatted as HH:MM", value)
+	}
+	if hour == 24 && minute == 0 {
+		return 24 * 60, nil
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("time %q is out of range", value)
+	}
+	return hour*60 + minute, nil
+}
@@ -0,0 +1,36 @@
+package businesshours
+
+import "testing"
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		days   int
+		isZero bool
+	}{
+		{"null", nil, 0, true},
+		{"empty", "", 0, true},
+		{"typed", `{"weekly":{"monday":[{"open":"08:00","close":"17:00"}]}}`, 1, false},
+		{"legacy by day", []byte(`{"monday":{"open":"08:00","close":"17:00"},"sunday":{"closed":true}}`), 2, false},
+		{"free text range", "07:00-22:00", 0, true},
+		{"free text days", "Mon-Fri 8-5", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Schedule
+			if err := s.Scan(tt.value); err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if len(s.Weekly) != tt.days || s.IsZero() != tt.isZero {
+				t.Errorf("Scan() = %+v, want %d days, zero %v", s, tt.days, tt.isZero)
+			}
+		})
+	}
+
+	var s Schedule
+	if err := s.Scan(42); err == nil {
+		t.Error("Scan(int) succeeded, want an error")
+	}
+}
@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"siipcoffe-api/internal/models"
+)
+
+// SignatureHeader is the request header that carries the webhook signature
+const SignatureHeader = "X-Signature"
+
+var (
+	// ErrInvalidSignature is returned when a webhook signature does not match
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrInvalidPayload is returned when a webhook body cannot be decoded
+	ErrInvalidPayload = errors.New("invalid webhook payload")
+)
+
+// Webhook event statuses
+const (
+	EventStatusPaid   = "paid"
+	EventStatusFailed = "failed"
+)
+
+// Provider handles a single payment method
+type Provider interface {
+	// Method is the payment method name used in requests and webhook URLs
+	Method() string
+	// Expiry is the default time a customer has to complete the payment.
+	// Zero means the payment never expires.
+	Expiry() time.Duration
+	// Initiate prepares the payment and returns the instructions shown to the
+	// customer. It may fill provider specific fields on the payment.
+	Initiate(payment *models.Payment, order *models.Order) (Instructions, error)
+	// Refund sends an amount of a paid payment back to the customer and
+	// returns the provider's refund reference. The reference identifies the
+	// refund; calling Refund again with the same reference must not send the
+	// money twice.
+	Refund(payment *models.Payment, amount float64, reference string) (string, error)
+	// ParseWebhook verifies the signature of a webhook call and decodes it
+	ParseWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
+
+// Instructions are returned to the customer after a payment is initiated
+type Instructions map[string]interface{}
+
+// WebhookEvent is a payment update reported by a provider
+type WebhookEvent struct {
+	PaymentID     string `json:"payment_id"`
+	TransactionID string `json:"transaction_id"`
+	Status        string `json:"status"` // paid, failed
+	Reference     string `json:"reference"`
+}
+
+// Registry holds the registered provider for each payment method
+type Registry struct {
+	providers map[string]Provider
+}
+
+// NewRegistry creates a registry with the given providers
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider)}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// Register adds a provider, replacing any provider for the same method
+func (r *Registry) Register(p Provider) {
+	r.providers[p.Method()] = p
+}
+
+// Get returns the provider for a payment method
+func (r *Registry) Get(method string) (Provider, bool) {
+	p, ok := r.providers[method]
+	return p, ok
+}
+
+// Methods returns the registered payment methods in alphabetical order
+func (r *Registry) Methods() []string {
+	methods := make([]string, 0, len(r.providers))
+	for method := range r.providers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Signer signs and verifies webhook payloads with HMAC-SHA256
+type Signer struct {
+	secret []byte
+}
+
+// NewSigner creates a signer for the given shared secret
+func NewSigner(secret string) Signer {
+	return Signer{secret: []byte(secret)}
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the payload
+func (s Signer) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a hex encoded signature in constant time
+func (s Signer) Verify(payload []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// webhookParser implements ParseWebhook for providers that post the
+// WebhookEvent JSON body signed with the shared secret.
+type webhookParser struct {
+	signer Signer
+}
+
+func (w webhookParser) ParseWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	if signature == "" || !w.signer.Verify(payload, signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	if event.PaymentID == "" && event.TransactionID == "" {
+		return nil, fmt.Errorf("%w: payment_id or transaction_id is required", ErrInvalidPayload)
+	}
+
+	if event.Status != EventStatusPaid && event.Status != EventStatusFailed {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidPayload, event.Status)
+	}
+
+	return &event, nil
+}
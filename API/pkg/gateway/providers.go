@@ -0,0 +1,190 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"siipcoffe-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// CryptoProvider accepts payments to a generated wallet address
+type CryptoProvider struct {
+	webhookParser
+}
+
+// NewCryptoProvider creates the crypto provider
+func NewCryptoProvider(webhookSecret string) *CryptoProvider {
+	return &CryptoProvider{webhookParser{signer: NewSigner(webhookSecret)}}
+}
+
+func (p *CryptoProvider) Method() string { return "crypto" }
+
+func (p *CryptoProvider) Expiry() time.Duration { return time.Hour }
+
+func (p *CryptoProvider) Initiate(payment *models.Payment, order *models.Order) (Instructions, error) {
+	// In production, generate an actual cryptocurrency address
+	walletAddress := fmt.Sprintf("bc1q%s", uuid.New().String()[:32])
+	payment.CryptoAddress = walletAddress
+
+	// Calculate crypto amount (simplified - in production, use real exchange rates)
+	cryptoAmount := payment.Amount / 100000000
+
+	return Instructions{
+		"payment_id":     payment.ID,
+		"transaction_id": payment.TransactionID,
+		"method":         "crypto",
+		"wallet_address": walletAddress,
+		"crypto_amount":  cryptoAmount,
+		"fiat_amount":    payment.Amount,
+		"currency":       "IDR",
+		"qr_code":        fmt.Sprintf("bitcoin:%s?amount=%.8f", walletAddress, cryptoAmount),
+		"status":         "awaiting_payment",
+	}, nil
+}
+
+// Refund sends the amount back to the wallet the payment came from
+func (p *CryptoProvider) Refund(payment *models.Payment, amount float64, reference string) (string, error) {
+	if payment.CryptoAddress == "" {
+		return "", fmt.Errorf("payment %s has no crypto address to refund from", payment.ID)
+	}
+	// In production, broadcast the refund transaction once per reference and
+	// return its hash
+	return fmt.Sprintf("0x%s", strings.ReplaceAll(reference, "-", "")), nil
+}
+
+// CashProvider handles payment at the cashier
+type CashProvider struct {
+	webhookParser
+}
+
+// NewCashProvider creates the cash provider
+func NewCashProvider(webhookSecret string) *CashProvider {
+	return &CashProvider{webhookParser{signer: NewSigner(webhookSecret)}}
+}
+
+func (p *CashProvider) Method() string { return "cash" }
+
+// Cash payments are settled at the counter and never expire
+func (p *CashProvider) Expiry() time.Duration { return 0 }
+
+func (p *CashProvider) Initiate(payment *models.Payment, order *models.Order) (Instructions, error) {
+	return Instructions{
+		"payment_id":     payment.ID,
+		"transaction_id": payment.TransactionID,
+		"method":         "cash",
+		"amount":         payment.Amount,
+		"currency":       "IDR",
+		"status":         "pending_confirmation",
+		"instructions":   "Silakan bayar di kasir saat menerima pesanan",
+	}, nil
+}
+
+// Refund is handed over in cash at the counter, so only a receipt number is issued
+func (p *CashProvider) Refund(payment *models.Payment, amount float64, reference string) (string, error) {
+	return fmt.Sprintf("CASH-REFUND-%s", shortReference(reference)), nil
+}
+
+// BankTransferProvider accepts payments to a virtual account
+type BankTransferProvider struct {
+	webhookParser
+}
+
+// NewBankTransferProvider creates the bank transfer provider
+func NewBankTransferProvider(webhookSecret string) *BankTransferProvider {
+	return &BankTransferProvider{webhookParser{signer: NewSigner(webhookSecret)}}
+}
+
+func (p *BankTransferProvider) Method() string { return "transfer" }
+
+func (p *BankTransferProvider) Expiry() time.Duration { return 24 * time.Hour }
+
+func (p *BankTransferProvider) Initiate(payment *models.Payment, order *models.Order) (Instructions, error) {
+	virtualAccount := fmt.Sprintf("8808%08d", uuid.New().ID())
+	payment.ProviderReference = virtualAccount
+
+	return Instructions{
+		"payment_id":      payment.ID,
+		"transaction_id":  payment.TransactionID,
+		"method":          "bank_transfer",
+		"virtual_account": virtualAccount,
+		"amount":          payment.Amount,
+		"currency":        "IDR",
+		"status":          "pending_transfer",
+		"bank_name":       "Bank SiipCoffee",
+		"account_name":    "SiipCoffee Cafe",
+	}, nil
+}
+
+// Refund transfers the amount back to the customer's bank account
+func (p *BankTransferProvider) Refund(payment *models.Payment, amount float64, reference string) (string, error) {
+	return fmt.Sprintf("RFT-%s", shortReference(reference)), nil
+}
+
+// FakeProvider is a local provider for development and tests. Its
+// instructions include a ready-to-send signed webhook that marks the payment
+// as paid, so the whole flow can be exercised without a real gateway.
+type FakeProvider struct {
+	webhookParser
+}
+
+// NewFakeProvider creates the fake provider
+func NewFakeProvider(webhookSecret string) *FakeProvider {
+	return &FakeProvider{webhookParser{signer: NewSigner(webhookSecret)}}
+}
+
+func (p *FakeProvider) Method() string { return "fake" }
+
+func (p *FakeProvider) Expiry() time.Duration { return 15 * time.Minute }
+
+func (p *FakeProvider) Initiate(payment *models.Payment, order *models.Order) (Instructions, error) {
+	payment.ProviderReference = "FAKE-" + payment.TransactionID
+
+	payload, signature, err := p.SignedEvent(WebhookEvent{
+		PaymentID: payment.ID,
+		Status:    EventStatusPaid,
+		Reference: payment.ProviderReference,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return Instructions{
+		"payment_id":     payment.ID,
+		"transaction_id": payment.TransactionID,
+		"method":         "fake",
+		"amount":         payment.Amount,
+		"currency":       "IDR",
+		"status":         "awaiting_webhook",
+		"webhook": Instructions{
+			"url":       "/api/v1/payment/webhook/fake",
+			"header":    SignatureHeader,
+			"signature": signature,
+			"payload":   json.RawMessage(payload),
+		},
+	}, nil
+}
+
+func (p *FakeProvider) Refund(payment *models.Payment, amount float64, reference string) (string, error) {
+	return "FAKE-REFUND-" + shortReference(reference), nil
+}
+
+// SignedEvent encodes an event and signs it the way a real gateway would
+func (p *FakeProvider) SignedEvent(event WebhookEvent) ([]byte, string, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, p.signer.Sign(payload), nil
+}
+
+// shortReference shortens a refund reference for receipt numbers
+func shortReference(reference string) string {
+	if len(reference) > 8 {
+		return reference[:8]
+	}
+	return reference
+}
@@ -149,12 +149,70 @@ Respons yang user lihat harus natural, bukan JSON.`, systemPrompt, conversationC
 	return &chatResponse, nil
 }
 
+// formatOptionGroups renders a menu's option groups as indented prompt lines,
+// e.g. "  * Ukuran (wajib, pilih 1): Regular, Large (+Rp 5000)"
+func formatOptionGroups(value interface{}) string {
+	groups, ok := value.([]map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	text := ""
+	for _, group := range groups {
+		options, _ := group["options"].([]map[string]interface{})
+		if len(options) == 0 {
+			continue
+		}
+
+		var names []string
+		for _, option := range options {
+			name := fmt.Sprintf("%v", option["name"])
+			if delta, _ := option["price_delta"].(float64); delta > 0 {
+				name += fmt.Sprintf(" (+Rp %.0f)", delta)
+			} else if delta < 0 {
+				name += fmt.Sprintf(" (-Rp %.0f)", -delta)
+			}
+			names = append(names, name)
+		}
+
+		rule := "opsional"
+		if required, _ := group["required"].(bool); required {
+			rule = "wajib"
+		}
+		text += fmt.Sprintf("  * %s (%s, maks %v): %s\n", group["name"], rule, group["max_select"], strings.Join(names, ", "))
+	}
+	return text
+}
+
+// closedNote marks menu items of a cafe that is currently closed
+func closedNote(menu map[string]interface{}) string {
+	if open, ok := menu["cafe_open"].(bool); !ok || open {
+		return ""
+	}
+	if opensAt, _ := menu["cafe_opens_at"].(string); opensAt != "" {
+		return fmt.Sprintf(" [TUTUP, buka %s]", opensAt)
+	}
+	return " [TUTUP]"
+}
+
+// soldOutNote marks menu items that have run out of an ingredient
+func soldOutNote(menu map[string]interface{}) string {
+	if soldOut, _ := menu["sold_out"].(bool); !soldOut {
+		return ""
+	}
+	if reason, _ := menu["sold_out_reason"].(string); reason != "" {
+		return fmt.Sprintf(" [HABIS, %s]", reason)
+	}
+	return " [HABIS]"
+}
+
 func (c *Client) buildSystemPrompt(menus []map[string]interface{}) string {
 	menuText := "MENU:\n"
 	for i, menu := range menus {
 		if i < 10 { // Batasi untuk Flash model efficiency
-			menuText += fmt.Sprintf("- %s (Rp %.0f): %s\n",
-				menu["name"], menu["price"], menu["description"])
+			menuText += fmt.Sprintf("- %s (Rp %.0f): %s%s%s\n",
+				menu["name"], menu["price"], menu["description"], soldOutNote(menu), closedNote(menu))
+			menuText += formatOptionGroups(menu["option_groups"])
 		}
 	}
 
@@ -166,6 +224,8 @@ ATURAN:
 - Respons singkat, jelas, ramah
 - Fokus pada pemesanan dan rekomendasi
 - Konfirmasi sebelum buat order
+- Menu bertanda [TUTUP] hanya bisa dipesan sebagai pre-order untuk jam buka berikutnya
+- Menu bertanda [HABIS] tidak bisa dipesan, tawarkan menu lain
 - Bahasa Indonesia alami
 
 JENIS PESANAN:
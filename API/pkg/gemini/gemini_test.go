@@ -0,0 +1,25 @@
+package gemini
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildSystemPromptMarksSoldOut(t *testing.T) {
+	c := &Client{cafeInfo: CafeInfo{Name: "Kopi Test"}}
+	prompt := c.buildSystemPrompt([]map[string]interface{}{
+		{"name": "Latte", "price": 25000.0, "description": "Susu", "sold_out": true, "sold_out_reason": "Out of Milk"},
+		{"name": "Americano", "price": 20000.0, "description": "Hitam", "sold_out": true},
+		{"name": "Espresso", "price": 18000.0, "description": "Pekat", "sold_out": false},
+	})
+
+	for _, want := range []string{
+		"- Latte (Rp 25000): Susu [HABIS, Out of Milk]\n",
+		"- Americano (Rp 20000): Hitam [HABIS]\n",
+		"- Espresso (Rp 18000): Pekat\n",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt has no line %q:\n%s", want, prompt)
+		}
+	}
+}
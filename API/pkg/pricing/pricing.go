@@ -0,0 +1,73 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"siipcoffe-api/internal/models"
+)
+
+// ErrBelowMinimumOrder is returned when the cart subtotal does not reach the
+// cafe's minimum order amount.
+var ErrBelowMinimumOrder = errors.New("order subtotal is below the cafe minimum order amount")
+
+// Breakdown is the full price breakdown of a cart or order
+type Breakdown struct {
+	Subtotal      float64 `json:"subtotal_amount"`
+	TaxAmount     float64 `json:"tax_amount"`
+	ServiceCharge float64 `json:"service_charge"`
+	DeliveryFee   float64 `json:"delivery_fee"`
+	Total         float64 `json:"total_amount"`
+
+	TaxPercentage           float64 `json:"tax_percentage"`
+	ServiceChargePercentage float64 `json:"service_charge_percentage"`
+	MinOrderAmount          float64 `json:"min_order_amount"`
+	DeliveryDistanceKm      float64 `json:"delivery_distance_km,omitempty"`
+}
+
+// Round rounds an amount to whole Rupiah. Every component of a breakdown is
+// rounded on its own so the parts always add up to the total.
+func Round(amount float64) float64 {
+	return math.Round(amount)
+}
+
+// Calculate builds the price breakdown for a subtotal using the cafe's
+// pricing settings. Service charge is applied to the subtotal, tax is applied
+// to the subtotal plus service charge, and the delivery fee only applies to
+// delivery orders. The delivery fee follows the cafe's fee tiers for
+// deliveryDistanceKm; pass 0 when the delivery address is not known yet.
+// A subtotal below the cafe's minimum returns ErrBelowMinimumOrder along with
+// the full breakdown, so a quote can still show it.
+func Calculate(cafe *models.Cafe, subtotal float64, orderType string, deliveryDistanceKm float64) (Breakdown, error) {
+	breakdown := Breakdown{
+		Subtotal:                Round(subtotal),
+		TaxPercentage:           cafe.TaxPercentage,
+		ServiceChargePercentage: cafe.ServiceChargePercentage,
+		MinOrderAmount:          cafe.MinOrderAmount,
+	}
+
+	breakdown.ServiceCharge = Round(breakdown.Subtotal * cafe.ServiceChargePercentage / 100)
+	breakdown.TaxAmount = Round((breakdown.Subtotal + breakdown.ServiceCharge) * cafe.TaxPercentage / 100)
+
+	if orderType == "delivery" {
+		breakdown.DeliveryDistanceKm = deliveryDistanceKm
+		breakdown.DeliveryFee = Round(cafe.DeliveryFeeFor(deliveryDistanceKm))
+	}
+
+	breakdown.Total = breakdown.Subtotal + breakdown.ServiceCharge + breakdown.TaxAmount + breakdown.DeliveryFee
+
+	if cafe.MinOrderAmount > 0 && breakdown.Subtotal < cafe.MinOrderAmount {
+		return breakdown, fmt.Errorf("%w (minimum Rp %.0f)", ErrBelowMinimumOrder, cafe.MinOrderAmount)
+	}
+	return breakdown, nil
+}
+
+// Apply copies the breakdown amounts onto an order
+func (b Breakdown) Apply(order *models.Order) {
+	order.SubtotalAmount = b.Subtotal
+	order.TaxAmount = b.TaxAmount
+	order.ServiceCharge = b.ServiceCharge
+	order.DeliveryFee = b.DeliveryFee
+	order.TotalAmount = b.Total
+}
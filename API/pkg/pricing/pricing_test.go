@@ -0,0 +1,51 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+
+	"siipcoffe-api/internal/models"
+)
+
+func TestCalculate(t *testing.T) {
+	tiers := &models.DeliverySettings{FeeTiers: []models.DeliveryFeeTier{
+		{UpToKm: 3, Fee: 8000},
+		{UpToKm: 7, Fee: 15000},
+	}}
+	flat := &models.Cafe{TaxPercentage: 10, ServiceChargePercentage: 5, DeliveryFee: 10000, MinOrderAmount: 25000}
+	tiered := &models.Cafe{TaxPercentage: 10, ServiceChargePercentage: 5, DeliveryFee: 10000, MinOrderAmount: 25000, Settings: models.CafeSettings{Delivery: tiers}}
+
+	tests := []struct {
+		name      string
+		cafe      *models.Cafe
+		subtotal  float64
+		orderType string
+		distance  float64
+		want      Breakdown
+		belowMin  bool
+	}{
+		{"take away", flat, 50000, "take_away", 0, Breakdown{Subtotal: 50000, ServiceCharge: 2500, TaxAmount: 5250, Total: 57750}, false},
+		{"flat delivery fee", flat, 50000, "delivery", 4, Breakdown{Subtotal: 50000, ServiceCharge: 2500, TaxAmount: 5250, DeliveryFee: 10000, Total: 67750, DeliveryDistanceKm: 4}, false},
+		{"first fee tier", tiered, 50000, "delivery", 2.5, Breakdown{Subtotal: 50000, ServiceCharge: 2500, TaxAmount: 5250, DeliveryFee: 8000, Total: 65750, DeliveryDistanceKm: 2.5}, false},
+		{"second fee tier", tiered, 50000, "delivery", 5, Breakdown{Subtotal: 50000, ServiceCharge: 2500, TaxAmount: 5250, DeliveryFee: 15000, Total: 72750, DeliveryDistanceKm: 5}, false},
+		{"rounded to Rupiah", flat, 33333, "dine_in", 0, Breakdown{Subtotal: 33333, ServiceCharge: 1667, TaxAmount: 3500, Total: 38500}, false},
+		{"below minimum", flat, 20000, "take_away", 0, Breakdown{Subtotal: 20000, ServiceCharge: 1000, TaxAmount: 2100, Total: 23100}, true},
+		{"below minimum delivery", tiered, 20000, "delivery", 5, Breakdown{Subtotal: 20000, ServiceCharge: 1000, TaxAmount: 2100, DeliveryFee: 15000, Total: 38100, DeliveryDistanceKm: 5}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Calculate(tt.cafe, tt.subtotal, tt.orderType, tt.distance)
+			if errors.Is(err, ErrBelowMinimumOrder) != tt.belowMin || (err != nil && !tt.belowMin) {
+				t.Fatalf("Calculate() error = %v, below minimum %v", err, tt.belowMin)
+			}
+
+			tt.want.TaxPercentage = tt.cafe.TaxPercentage
+			tt.want.ServiceChargePercentage = tt.cafe.ServiceChargePercentage
+			tt.want.MinOrderAmount = tt.cafe.MinOrderAmount
+			if got != tt.want {
+				t.Errorf("Calculate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -77,6 +77,8 @@ func (g *Generator) GenerateJSONReceipt(order models.OrderResponse) (map[string]
 			"order_type":       order.OrderType,
 			"table_number":     order.TableNumber,
 			"delivery_address": order.DeliveryAddress,
+			"delivery_distance_km": order.DeliveryDistanceKm,
+			"delivery_fee":     order.DeliveryFee,
 			"notes":            order.Notes,
 			"items":            order.OrderItems,
 			"total_amount":     order.TotalAmount,
@@ -110,7 +112,8 @@ const receiptTemplate = `
 ║                         DETAIL PESANAN                       ║
 ╠══════════════════════════════════════════════════════════════╣
 {{range .Order.OrderItems}}║ {{printf "%-3s %-30s %3d x %8.0f" .Menu.Name .Menu.Name .Quantity .UnitPrice}} ║
-║ {{printf "%46s %8.0f" " " .TotalPrice}}                    ║
+{{if .Options}}║   {{printf "%-58s" .OptionSummary}} ║
+{{end}}║ {{printf "%46s %8.0f" " " .TotalPrice}}                    ║
 {{end}}╠══════════════════════════════════════════════════════════════╣
 ║ {{printf "TOTAL: %55s" (printf "Rp %,.0f" .Order.TotalAmount)}}║
 ╠══════════════════════════════════════════════════════════════╣
@@ -122,7 +125,9 @@ const receiptTemplate = `
 ║                     INFORMASI PELANGGAN                       ║
 ╠══════════════════════════════════════════════════════════════╣
 ║ Nama        : {{.Order.CustomerName}}                         ║
-{{if .Order.CustomerPhone}}║ Telepon     : {{.Order.CustomerPhone}}                        ║{{end}}{{if eq .Order.OrderType "dine_in"}}║ Meja        : {{.Order.TableNumber}}                             ║{{else if eq .Order.OrderType "delivery"}}║ Alamat      : {{.Order.DeliveryAddress}}                     ║{{else if eq .Order.OrderType "take_away"}}║ Jenis       : Bawa Pulang                                   ║{{end}}╠══════════════════════════════════════════════════════════════╣
+{{if .Order.CustomerPhone}}║ Telepon     : {{.Order.CustomerPhone}}                        ║{{end}}{{if eq .Order.OrderType "dine_in"}}║ Meja        : {{.Order.TableNumber}}                             ║{{else if eq .Order.OrderType "delivery"}}║ Alamat      : {{.Order.DeliveryAddress}}                     ║
+║ Jarak       : {{printf "%.1f km (ongkir Rp %.0f)" .Order.DeliveryDistanceKm .Order.DeliveryFee}}                  ║
+{{else if eq .Order.OrderType "take_away"}}║ Jenis       : Bawa Pulang                                   ║{{end}}╠══════════════════════════════════════════════════════════════╣
 ║                          CATATAN                             ║
 ╠══════════════════════════════════════════════════════════════╣
 ║ {{printf "%-60s" .Order.Notes}}                              ║
@@ -160,8 +165,10 @@ const miniReceiptTemplate = `
 No: {{.Order.OrderNumber}}
 
 {{range .Order.OrderItems}}- {{.Menu.Name}} ({{.Quantity}}x) = Rp {{.TotalPrice | printf "%.0f"}}
-{{end}}---
-**Total: Rp {{.Order.TotalAmount | printf "%.0f"}}**
+{{if .Options}}  {{.OptionSummary}}
+{{end}}{{end}}---
+{{if eq .Order.OrderType "delivery"}}Antar: {{printf "%.1f" .Order.DeliveryDistanceKm}} km, ongkir Rp {{printf "%.0f" .Order.DeliveryFee}}
+{{end}}**Total: Rp {{.Order.TotalAmount | printf "%.0f"}}**
 
 Metode: {{.Order.PaymentMethod}}
 Status: {{.Order.PaymentStatus}}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"math"
 	"path/filepath"
 	"strings"
 	"time"
@@ -56,13 +57,80 @@ func GenerateReferenceID() string {
 	return uuid.New().String()
 }
 
-// CalculateDistance calculates distance between two coordinates (simplified)
+// EarthRadiusKm is the mean radius of the Earth
+const EarthRadiusKm = 6371.0
+
+// CalculateDistance returns the great-circle distance in km between two
+// coordinates using the Haversine formula
 func CalculateDistance(lat1, lng1, lat2, lng2 float64) float64 {
-	// This is a simplified calculation
-	// For production, use the Haversine formula
-	latDiff := lat2 - lat1
-	lngDiff := lng2 - lng1
-	return (latDiff*latDiff + lngDiff*lngDiff) * 111 * 111 // Approximate km
+	dLat := toRadians(lat2 - lat1)
+	dLng := toRadians(lng2 - lng1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return EarthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// BoundingBox returns the latitude and longitude range that contains every
+// point within radiusKm of the given coordinate. It is meant as a cheap
+// prefilter before CalculateDistance. Near the poles or the antimeridian the
+// longitude range covers the whole globe.
+func BoundingBox(lat, lng, radiusKm float64) (minLat, maxLat, minLng, maxLng float64) {
+	latDelta := radiusKm / EarthRadiusKm * 180 / math.Pi
+	minLat = math.Max(lat-latDelta, -90)
+	maxLat = math.Min(lat+latDelta, 90)
+
+	if minLat == -90 || maxLat == 90 {
+		return minLat, maxLat, -180, 180
+	}
+
+	// Widest longitude reached by the circle, which is more than the latitude
+	// delta scaled by the latitude's circumference
+	ratio := math.Sin(radiusKm/EarthRadiusKm) / math.Cos(toRadians(lat))
+	if ratio >= 1 {
+		return minLat, maxLat, -180, 180
+	}
+	lngDelta := math.Asin(ratio) * 180 / math.Pi
+	minLng = lng - lngDelta
+	maxLng = lng + lngDelta
+	if minLng < -180 || maxLng > 180 {
+		return minLat, maxLat, -180, 180
+	}
+	return minLat, maxLat, minLng, maxLng
+}
+
+// Point is a geographic coordinate in degrees
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// PointInPolygon reports whether p lies inside the polygon given by its
+// vertices, using ray casting. The polygon does not need to be closed and is
+// treated as planar, which is accurate enough for city-sized delivery zones.
+// Points on the southern or western edges count as inside and points on the
+// northern or eastern edges do not, so two zones sharing an edge never both
+// claim a point on it.
+func PointInPolygon(p Point, polygon []Point) bool {
+	if len(polygon) < 3 {
+		return false
+	}
+
+	inside := false
+	j := len(polygon) - 1
+	for i := range polygon {
+		a, b := polygon[i], polygon[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lng < (b.Lng-a.Lng)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			inside = !inside
+		}
+		j = i
+	}
+	return inside
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
 }
 
 // TimeAgo formats time as "X time ago"
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalculateDistance(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		wantKm                 float64
+	}{
+		{"same point", -6.2088, 106.8456, -6.2088, 106.8456, 0},
+		{"Jakarta to Bandung", -6.2088, 106.8456, -6.9175, 107.6191, 116.2},
+		{"Jakarta to Surabaya", -6.2088, 106.8456, -7.2575, 112.7521, 662.6},
+		{"London to Paris", 51.5074, -0.1278, 48.8566, 2.3522, 343.6},
+		{"New York to Los Angeles", 40.7128, -74.0060, 34.0522, -118.2437, 3935.7},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, 111.2},
+		{"one degree of latitude", 0, 0, 1, 0, 111.2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalculateDistance(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+			if math.Abs(got-tt.wantKm) > 0.1 {
+				t.Errorf("CalculateDistance() = %.2f km, want %.1f km", got, tt.wantKm)
+			}
+			if back := CalculateDistance(tt.lat2, tt.lng2, tt.lat1, tt.lng1); math.Abs(back-got) > 1e-9 {
+				t.Errorf("distance back = %.4f km, want %.4f km", back, got)
+			}
+		})
+	}
+}
+
+func TestBoundingBoxContainsRadius(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lng float64
+		radiusKm float64
+	}{
+		{"Jakarta", -6.2088, 106.8456, 5},
+		{"equator", 0, 0, 50},
+		{"Oslo", 59.9139, 10.7522, 20},
+		{"far north", 78.2232, 15.6267, 100},
+		{"near the antimeridian", -17.7134, 179.9, 30},
+		{"near the pole", 89.95, 0, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minLat, maxLat, minLng, maxLng := BoundingBox(tt.lat, tt.lng, tt.radiusKm)
+			if minLat > tt.lat || maxLat < tt.lat || minLng > tt.lng || maxLng < tt.lng {
+				t.Fatalf("box %v..%v, %v..%v does not contain the center", minLat, maxLat, minLng, maxLng)
+			}
+
+			// Every point on the circle must be inside the box
+			for bearing := 0.0; bearing < 360; bearing += 5 {
+				lat, lng := destination(tt.lat, tt.lng, bearing, tt.radiusKm)
+				if d := CalculateDistance(tt.lat, tt.lng, lat, lng); math.Abs(d-tt.radiusKm) > 1e-6 {
+					t.Fatalf("test point at %.0f° is %.6f km away, want %.0f km", bearing, d, tt.radiusKm)
+				}
+				const slack = 1e-9 // rounding on the edge of the box
+				if lat < minLat-slack || lat > maxLat+slack || lng < minLng-slack || lng > maxLng+slack {
+					t.Errorf("point %.6f,%.6f at %.0f° is outside the box %v..%v, %v..%v", lat, lng, bearing, minLat, maxLat, minLng, maxLng)
+				}
+			}
+		})
+	}
+}
+
+// destination returns the point distanceKm away from lat, lng in the
+// direction of bearing, in degrees from north
+func destination(lat, lng, bearing, distanceKm float64) (float64, float64) {
+	d := distanceKm / EarthRadiusKm
+	φ, λ, θ := toRadians(lat), toRadians(lng), toRadians(bearing)
+
+	φ2 := math.Asin(math.Sin(φ)*math.Cos(d) + math.Cos(φ)*math.Sin(d)*math.Cos(θ))
+	λ2 := λ + math.Atan2(math.Sin(θ)*math.Sin(d)*math.Cos(φ), math.Cos(d)-math.Sin(φ)*math.Sin(φ2))
+
+	lng2 := math.Mod(λ2*180/math.Pi+540, 360) - 180 // back into -180..180
+	return φ2 * 180 / math.Pi, lng2
+}
+
+func TestPointInPolygon(t *testing.T) {
+	square := []Point{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+	// A U open to the north: the notch between lng 1 and 2 above lat 1 is outside
+	u := []Point{{0, 0}, {0, 3}, {3, 3}, {3, 2}, {1, 2}, {1, 1}, {3, 1}, {3, 0}}
+	// The same square given clockwise and closed with its first vertex
+	closed := []Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}
+
+	tests := []struct {
+		name    string
+		p       Point
+		polygon []Point
+		want    bool
+	}{
+		{"inside", Point{0.5, 0.5}, square, true},
+		{"outside east", Point{0.5, 1.5}, square, false},
+		{"outside north", Point{1.5, 0.5}, square, false},
+		{"outside diagonal", Point{-0.1, -0.1}, square, false},
+		{"inside closed clockwise", Point{0.5, 0.5}, closed, true},
+		{"on western edge", Point{0.5, 0}, square, true},
+		{"on southern edge", Point{0, 0.5}, square, true},
+		{"on eastern edge", Point{0.5, 1}, square, false},
+		{"on northern edge", Point{1, 0.5}, square, false},
+		{"south west corner", Point{0, 0}, square, true},
+		{"north east corner", Point{1, 1}, square, false},
+		{"concave left arm", Point{1.5, 0.5}, u, true},
+		{"concave right arm", Point{1.5, 2.5}, u, true},
+		{"concave base", Point{0.5, 1.5}, u, true},
+		{"concave notch", Point{1.5, 1.5}, u, false},
+		{"concave notch beside the arms", Point{2.5, 1.5}, u, false},
+		{"concave outside", Point{3.5, 1.5}, u, false},
+		{"real coordinates", Point{-6.2, 106.82}, []Point{{-6.25, 106.78}, {-6.15, 106.78}, {-6.15, 106.88}, {-6.25, 106.88}}, true},
+		{"empty polygon", Point{0, 0}, nil, false},
+		{"two points", Point{0.5, 0.5}, []Point{{0, 0}, {1, 1}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PointInPolygon(tt.p, tt.polygon); got != tt.want {
+				t.Errorf("PointInPolygon(%v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPointInPolygonSharedEdge(t *testing.T) {
+	west := []Point{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+	east := []Point{{0, 1}, {0, 2}, {1, 2}, {1, 1}}
+	north := []Point{{1, 0}, {1, 1}, {2, 1}, {2, 0}}
+
+	for _, p := range []Point{{0.5, 1}, {0, 1}, {0.25, 1}} {
+		if PointInPolygon(p, west) == PointInPolygon(p, east) {
+			t.Errorf("point %v on the west/east edge claimed by both or neither zone", p)
+		}
+	}
+	for _, p := range []Point{{1, 0.5}, {1, 0}, {1, 0.75}} {
+		if PointInPolygon(p, west) == PointInPolygon(p, north) {
+			t.Errorf("point %v on the south/north edge claimed by both or neither zone", p)
+		}
+	}
+}
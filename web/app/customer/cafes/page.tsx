@@ -12,6 +12,28 @@ import { toast } from "sonner"
 import { useAuthStore } from "@/lib/store"
 import { cafeApi } from "@/lib/api"
 
+interface BusinessPeriod {
+  open: string
+  close: string
+}
+
+interface BusinessHours {
+  weekly?: Record<string, BusinessPeriod[]>
+  overrides?: {
+    date: string
+    closed: boolean
+    periods?: BusinessPeriod[]
+    note?: string
+  }[]
+}
+
+// Feature keys come from the API, e.g. "outdoor_seating" -> "Outdoor Seating"
+const featureLabel = (feature: string) =>
+  feature
+    .split('_')
+    .map((word) => (word === 'ac' ? 'AC' : word.charAt(0).toUpperCase() + word.slice(1)))
+    .join(' ')
+
 interface Cafe {
   id: string
   name: string
@@ -25,8 +47,8 @@ interface Cafe {
   rating_average: number
   rating_count: number
   is_open: boolean
-  business_hours?: string
-  features?: string
+  business_hours?: BusinessHours
+  features?: string[]
   delivery_fee?: number
   min_order_amount?: number
   owner?: {
@@ -186,13 +208,13 @@ export default function CafesPage() {
                 </div>
 
                 {/* Features */}
-                {cafe.features && (
+                {cafe.features && cafe.features.length > 0 && (
                   <div className="space-y-3">
                     <p className="text-sm font-medium text-gray-700">✨ Features:</p>
                     <div className="grid grid-cols-2 gap-2">
-                      {cafe.features.split(',').slice(0, 4).map((feature, index) => (
-                        <div key={index} className="bg-pink-50 rounded-lg p-2 text-center">
-                          <span className="text-xs text-pink-700 font-medium">🤖 {feature.trim()}</span>
+                      {cafe.features.slice(0, 4).map((feature) => (
+                        <div key={feature} className="bg-pink-50 rounded-lg p-2 text-center">
+                          <span className="text-xs text-pink-700 font-medium">🤖 {featureLabel(feature)}</span>
                         </div>
                       ))}
                     </div>